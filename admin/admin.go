@@ -0,0 +1,61 @@
+// Copyright 2019 The Ninep Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package admin serves a Listener's insides over HTTP, for the people
+// running it. Nothing here starts by itself: a command that wants it
+// calls Serve, typically when given an -admin flag.
+//
+// The endpoints are
+//
+//	/debug/pprof/   the runtime profiles, as net/http/pprof serves them
+//	/metrics        the Listener's Metrics, in the Prometheus text format
+//	/healthz        200 while the process is up
+//	/readyz         200 while the Listener is serving, 503 otherwise
+//	/conns          the live connections, as JSON
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/pprof"
+
+	"sevki.org/q9p/protocol"
+)
+
+// Handler returns a handler for l's admin endpoints. If l has no Metrics,
+// /metrics is not found.
+func Handler(l *protocol.Listener) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	if l.Metrics != nil {
+		mux.Handle("/metrics", l.Metrics)
+	}
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok\n"))
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		if !l.Serving() {
+			http.Error(w, "not serving", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok\n"))
+	})
+	mux.HandleFunc("/conns", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		e := json.NewEncoder(w)
+		e.SetIndent("", "\t")
+		e.Encode(l.Conns())
+	})
+	return mux
+}
+
+// Serve serves l's admin endpoints on addr. Like http.ListenAndServe, it
+// only returns on error.
+func Serve(addr string, l *protocol.Listener) error {
+	return http.ListenAndServe(addr, Handler(l))
+}
@@ -0,0 +1,81 @@
+// Copyright 2019 The Ninep Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package admin
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"sevki.org/q9p/protocol"
+)
+
+type attacher struct {
+	protocol.BaseServer
+}
+
+func TestHandler(t *testing.T) {
+	l, err := protocol.NewListener(func() protocol.NineServer { return attacher{} }, func(l *protocol.Listener) error {
+		l.FidTable = true
+		l.Metrics = protocol.NewMetrics("q9p_server")
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("NewListener: want nil, got %v", err)
+	}
+	p, p2 := net.Pipe()
+	defer p.Close()
+	if err := l.Accept(p2); err != nil {
+		t.Fatalf("Accept: want nil, got %v", err)
+	}
+	c, err := protocol.NewClient(func(c *protocol.Client) error {
+		c.FromNet, c.ToNet = p, p
+		c.Trace = func(string, ...interface{}) {}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.CallTattach(0, protocol.NOFID, "glenda", ""); err != nil {
+		t.Fatalf("CallTattach: want nil, got %v", err)
+	}
+
+	h := Handler(l)
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return w
+	}
+	for _, v := range []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/healthz", http.StatusOK, "ok"},
+		{"/readyz", http.StatusServiceUnavailable, "not serving"},
+		{"/metrics", http.StatusOK, "q9p_server_connections 1\n"},
+		{"/debug/pprof/", http.StatusOK, "goroutine"},
+		{"/conns", http.StatusOK, `"uname": "glenda"`},
+	} {
+		w := get(v.path)
+		if w.Code != v.code || !strings.Contains(w.Body.String(), v.body) {
+			t.Errorf("GET %v: got %d %q, want %d and %q in it", v.path, w.Code, w.Body, v.code, v.body)
+		}
+	}
+
+	var conns []protocol.ConnInfo
+	if err := json.Unmarshal(get("/conns").Body.Bytes(), &conns); err != nil {
+		t.Fatalf("/conns: %v", err)
+	}
+	if len(conns) != 1 || conns[0].Fids != 1 || conns[0].Remote != "pipe" {
+		t.Errorf("/conns: got %+v, want one conn from pipe with one fid", conns)
+	}
+	if s := l.String(); s != "Listener on [], 1 conns" {
+		t.Errorf("String: got %q, want %q", s, "Listener on [], 1 conns")
+	}
+}
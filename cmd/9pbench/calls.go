@@ -0,0 +1,149 @@
+// Copyright 2019 The Ninep Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"time"
+
+	"sevki.org/q9p/protocol"
+)
+
+// The calls a worker makes, each timed into its recorder. They return an
+// error for answers that are not errors but are not what was asked for,
+// like a walk that does not get all the way.
+
+func (w *worker) walk(fid, newfid protocol.FID, names ...string) error {
+	defer w.rec.call("Twalk", time.Now())
+	q, err := w.c.CallTwalk(fid, newfid, names)
+	if err == nil && len(q) != len(names) {
+		err = fmt.Errorf("walk %s: got to %d of %d names", path.Join(names...), len(q), len(names))
+	}
+	return err
+}
+
+func (w *worker) open(fid protocol.FID, mode protocol.Mode) error {
+	defer w.rec.call("Topen", time.Now())
+	_, _, err := w.c.CallTopen(fid, mode)
+	return err
+}
+
+func (w *worker) create(fid protocol.FID, name string, perm protocol.Perm, mode protocol.Mode) error {
+	defer w.rec.call("Tcreate", time.Now())
+	_, _, err := w.c.CallTcreate(fid, name, perm, mode)
+	return err
+}
+
+func (w *worker) read(fid protocol.FID, off int64, n int64) ([]byte, error) {
+	defer w.rec.call("Tread", time.Now())
+	b, err := w.c.CallTread(fid, protocol.Offset(off), protocol.Count(n))
+	w.rec.bytes += int64(len(b))
+	return b, err
+}
+
+func (w *worker) write(fid protocol.FID, off int64, b []byte) error {
+	defer w.rec.call("Twrite", time.Now())
+	n, err := w.c.CallTwrite(fid, protocol.Offset(off), b)
+	if err == nil && int(n) != len(b) {
+		err = fmt.Errorf("wrote %d bytes of %d", n, len(b))
+	}
+	w.rec.bytes += int64(n)
+	return err
+}
+
+func (w *worker) stat(fid protocol.FID) error {
+	defer w.rec.call("Tstat", time.Now())
+	_, err := w.c.CallTstat(fid)
+	return err
+}
+
+func (w *worker) clunk(fid protocol.FID) error {
+	defer w.rec.call("Tclunk", time.Now())
+	return w.c.CallTclunk(fid)
+}
+
+func (w *worker) remove(fid protocol.FID) error {
+	defer w.rec.call("Tremove", time.Now())
+	return w.c.CallTremove(fid)
+}
+
+// mkdir makes the directory name in dir, leaving dir as it was.
+func (w *worker) mkdir(dir protocol.FID, name string) error {
+	f := w.c.GetFID()
+	if err := w.walk(dir, f); err != nil {
+		return err
+	}
+	if err := w.create(f, name, protocol.Perm(protocol.DMDIR|0755), protocol.OREAD); err != nil {
+		w.clunk(f)
+		return err
+	}
+	return w.clunk(f)
+}
+
+// readDir reads the entries of dir, which is not open, leaving it so.
+func (w *worker) readDir(dir protocol.FID) ([]protocol.Dir, error) {
+	f := w.c.GetFID()
+	if err := w.walk(dir, f); err != nil {
+		return nil, err
+	}
+	defer w.clunk(f)
+	if err := w.open(f, protocol.OREAD); err != nil {
+		return nil, err
+	}
+	var ds []protocol.Dir
+	var off int64
+	for {
+		b, err := w.read(f, off, int64(w.iounit))
+		if err != nil {
+			return nil, err
+		}
+		if len(b) == 0 {
+			return ds, nil
+		}
+		off += int64(len(b))
+		for buf := bytes.NewBuffer(b); buf.Len() > 0; {
+			d, err := protocol.Unmarshaldir(buf)
+			if err != nil {
+				return nil, err
+			}
+			ds = append(ds, d)
+		}
+	}
+}
+
+// removeAll removes fid, and all in it if it is a directory.
+func (w *worker) removeAll(fid protocol.FID, dir bool) error {
+	if dir {
+		ds, err := w.readDir(fid)
+		if err != nil {
+			return err
+		}
+		for _, d := range ds {
+			f := w.c.GetFID()
+			if err := w.walk(fid, f, d.Name); err != nil {
+				return err
+			}
+			if err := w.removeAll(f, d.QID.Type&protocol.QTDIR != 0); err != nil {
+				return err
+			}
+		}
+	}
+	return w.remove(fid)
+}
+
+// fill writes n bytes of b, over and over, to fid from its start.
+func (w *worker) fill(fid protocol.FID, n int64, b []byte) error {
+	for off := int64(0); off < n; off += int64(len(b)) {
+		if rest := n - off; rest < int64(len(b)) {
+			b = b[:rest]
+		}
+		if err := w.write(fid, off, b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
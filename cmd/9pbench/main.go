@@ -0,0 +1,252 @@
+// Copyright 2019 The Ninep Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// 9pbench is a load generator for 9P servers. It runs a number of
+// clients, each on a connection of its own, making the calls of a mix
+// for a while, then reports the throughput and, for each kind of call,
+// the latency percentiles, as text or as JSON to keep for comparison.
+//
+// The mixes are
+//
+//	meta	create, stat, walk to and remove small files
+//	seq	write a large file from start to end, then read it back
+//	rand	read and write a large file at random offsets
+//	list	walk and read every directory of a deep tree
+//
+// Each client works in a directory of its own, made in -dir on the
+// server and removed, with all in it, at the end. The mixes named in
+// -mix are run one after another, for -duration each.
+//
+// For example, to compare two builds of ufs over tcp and unix sockets:
+//
+//	9pbench -addr localhost:5640 -json > old.json
+//	9pbench -net unix -addr /tmp/ufs.sock -mix meta,list -clients 16
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"sevki.org/q9p/protocol"
+)
+
+// The flags are 9pbench's own, not the flag package's, so they cannot
+// clash with those of packages linked with it, as its test links the
+// file server.
+var (
+	flags    = flag.NewFlagSet("9pbench", flag.ExitOnError)
+	ntype    = flags.String("net", "tcp", "Network to dial the server on: tcp, tcp4, tcp6, unix or quic")
+	naddr    = flags.String("addr", "localhost:5640", "Network address of the server")
+	uname    = flags.String("user", "harvey", "User to attach as")
+	aname    = flags.String("aname", "", "File tree to attach to")
+	dir      = flags.String("dir", "tmp", "Directory, from the root of the tree, to work in")
+	clients  = flags.Int("clients", 4, "Number of clients, each with its own connection")
+	duration = flags.Duration("duration", 10*time.Second, "How long to run each mix for")
+	mixNames = flags.String("mix", "meta,seq,rand,list", "Comma-separated mixes to run, in order")
+	msize    = flags.Uint("msize", 64<<10+protocol.IOHDRSZ, "Message size to ask the server for")
+	size     = flags.Int64("size", 16<<20, "Size of the file seq and rand work on")
+	iosize   = flags.Int("iosize", 4096, "Bytes read or written by each call in rand; seq uses as many as fit in a message")
+	depth    = flags.Int("depth", 3, "Levels of directories in list's tree")
+	width    = flags.Int("width", 4, "Directories and files in each directory of list's tree")
+	jsonOut  = flags.Bool("json", false, "Write the results as JSON, not text")
+)
+
+func main() {
+	flags.Parse(os.Args[1:])
+	if *clients < 1 || *size < 1 || *iosize < 1 || int64(*iosize) > *size || *width < 1 || *depth < 0 {
+		log.Fatal("-clients, -size, -iosize and -width must be positive, -iosize no more than -size and -depth not negative")
+	}
+
+	var ms []mix
+	for _, n := range strings.Split(*mixNames, ",") {
+		m, ok := mixes[n]
+		if !ok {
+			log.Fatalf("unknown mix %q", n)
+		}
+		ms = append(ms, m)
+	}
+
+	var rs []*result
+	for _, m := range ms {
+		r, err := run(m)
+		if err != nil {
+			log.Fatalf("%s: %v", m.name, err)
+		}
+		if !*jsonOut {
+			r.WriteText(os.Stdout)
+		}
+		rs = append(rs, r)
+	}
+	if *jsonOut {
+		e := json.NewEncoder(os.Stdout)
+		e.SetIndent("", "\t")
+		if err := e.Encode(rs); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// dial connects to the server, over quic if that is the network.
+func dial() (io.ReadWriteCloser, error) {
+	if *ntype == "quic" {
+		return dialQUIC(*naddr)
+	}
+	return net.Dial(*ntype, *naddr)
+}
+
+// run runs m with the flags' number of clients for the flags' duration.
+// All the clients are set up before the clock starts, and torn down
+// after it stops.
+func run(m mix) (*result, error) {
+	ws := make([]*worker, *clients)
+	for i := range ws {
+		w, err := newWorker(m, i)
+		if err != nil {
+			for _, w := range ws[:i] {
+				w.close()
+			}
+			return nil, err
+		}
+		ws[i] = w
+	}
+
+	var wg sync.WaitGroup
+	start := time.Now()
+	stop := start.Add(*duration)
+	for _, w := range ws {
+		wg.Add(1)
+		go func(w *worker) {
+			defer wg.Done()
+			for time.Now().Before(stop) {
+				if err := w.rec.op(m.op(w)); errors.Is(err, protocol.ErrClientDead) {
+					w.lost = true
+					return
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	rec := newRecorder()
+	for _, w := range ws {
+		rec.merge(w.rec)
+		w.close()
+	}
+	return rec.result(m.name, elapsed), nil
+}
+
+// A worker is a client and what its mix has made for it on the server.
+type worker struct {
+	c    *protocol.Client
+	rw   io.ReadWriteCloser
+	rec  *recorder
+	lost bool // whether the connection is gone
+
+	dir    protocol.FID // the worker's directory, not open
+	name   string       // its name, in -dir
+	iounit protocol.Count
+	n      int // for names of files made
+	rand   *rand.Rand
+
+	// For seq and rand: file is open for reading and writing, and off
+	// is where seq is in it, and whether it is writing or reading.
+	file    protocol.FID
+	off     int64
+	reading bool
+	buf     []byte
+}
+
+// newWorker dials the server, makes the worker's directory, and sets it
+// up for m. The calls it makes are not counted.
+func newWorker(m mix, i int) (*worker, error) {
+	rw, err := dial()
+	if err != nil {
+		return nil, err
+	}
+	c, err := protocol.NewClient(func(c *protocol.Client) error {
+		c.FromNet, c.ToNet = rw, rw
+		c.Msize = uint32(*msize)
+		// Why a client died is in the error of the op it failed, and
+		// closing them at the end should not be logged.
+		c.Observer = protocol.ObserverFunc(func(protocol.Event) {})
+		return nil
+	})
+	if err != nil {
+		rw.Close()
+		return nil, err
+	}
+	w := &worker{
+		c:    c,
+		rw:   rw,
+		rec:  newRecorder(),
+		name: fmt.Sprintf("9pbench.%d.%d", os.Getpid(), i),
+		rand: rand.New(rand.NewSource(int64(i))),
+	}
+	if err := w.setup(m); err != nil {
+		w.close()
+		return nil, fmt.Errorf("client %d: %v", i, err)
+	}
+	w.rec = newRecorder()
+	return w, nil
+}
+
+func (w *worker) setup(m mix) error {
+	ms, _, err := w.c.CallTversion(protocol.MaxSize(*msize), "9P2000")
+	if err != nil {
+		return err
+	}
+	w.iounit = protocol.Count(ms - protocol.IOHDRSZ)
+
+	root := w.c.GetFID()
+	if _, err := w.c.CallTattach(root, protocol.NOFID, *uname, *aname); err != nil {
+		return err
+	}
+	var names []string
+	for _, n := range strings.Split(*dir, "/") {
+		if n != "" {
+			names = append(names, n)
+		}
+	}
+	parent := w.c.GetFID()
+	if err := w.walk(root, parent, names...); err != nil {
+		return fmt.Errorf("walking to %s: %v", *dir, err)
+	}
+	if err := w.mkdir(parent, w.name); err != nil {
+		return err
+	}
+	// Only now is there a directory for close to remove.
+	dir := w.c.GetFID()
+	if err := w.walk(parent, dir, w.name); err != nil {
+		return err
+	}
+	w.dir = dir
+	if m.setup != nil {
+		return m.setup(w)
+	}
+	return nil
+}
+
+// close removes the worker's directory and closes its connection.
+func (w *worker) close() {
+	if w.dir != 0 && !w.lost {
+		if w.file != 0 {
+			w.clunk(w.file)
+		}
+		if err := w.removeAll(w.dir, true); err != nil {
+			log.Printf("removing %s: %v", w.name, err)
+		}
+	}
+	w.rw.Close()
+}
@@ -0,0 +1,58 @@
+// Copyright 2019 The Ninep Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"sevki.org/q9p/filesystem"
+)
+
+// TestRun runs each mix, briefly, against a FileServer on a unix socket,
+// and checks that it did something and nothing failed.
+func TestRun(t *testing.T) {
+	d, err := ioutil.TempDir("", "9pbench")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(d)
+	root := filepath.Join(d, "root")
+	if err := os.MkdirAll(filepath.Join(root, "tmp"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	sock := filepath.Join(d, "sock")
+	ln, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Skipf("no unix sockets: %v", err)
+	}
+	l, err := filesystem.Newfilesystem(filesystem.Root(root))
+	if err != nil {
+		t.Fatal(err)
+	}
+	go l.Serve(ln)
+	defer l.Shutdown()
+
+	*ntype, *naddr, *dir = "unix", sock, "tmp"
+	*clients, *duration = 2, 50*time.Millisecond
+	*size, *iosize, *depth, *width = 64<<10, 4096, 2, 2
+	for _, n := range []string{"meta", "seq", "rand", "list"} {
+		r, err := run(mixes[n])
+		if err != nil {
+			t.Fatalf("%v: want nil, got %v", n, err)
+		}
+		if r.Ops == 0 || r.Errors != 0 {
+			t.Errorf("%v: got %d ops, %d errors (%v), want some ops and no errors", n, r.Ops, r.Errors, r.FirstError)
+		}
+	}
+	// Each client removes what it made.
+	if fs, err := ioutil.ReadDir(filepath.Join(root, "tmp")); err != nil || len(fs) != 0 {
+		t.Errorf("tmp: got %d files, %v, want none and nil", len(fs), err)
+	}
+}
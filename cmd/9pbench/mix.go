@@ -0,0 +1,169 @@
+// Copyright 2019 The Ninep Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+
+	"sevki.org/q9p/protocol"
+)
+
+// A mix is a kind of load. setup, if not nil, makes what op needs in the
+// worker's directory; op makes the calls of one unit of the load, which
+// is what is counted as an op. Neither cleans up: the worker's directory
+// is removed, with all in it, at the end.
+type mix struct {
+	name  string
+	setup func(w *worker) error
+	op    func(w *worker) error
+}
+
+var mixes = map[string]mix{
+	"meta": {name: "meta", op: meta},
+	"seq":  {name: "seq", setup: makeFile, op: seq},
+	"rand": {name: "rand", setup: makeFile, op: randIO},
+	"list": {name: "list", setup: makeTree, op: list},
+}
+
+// meta makes a file, walks to it, stats it and removes it.
+func meta(w *worker) error {
+	name := fmt.Sprintf("f%d", w.n)
+	w.n++
+	f := w.c.GetFID()
+	if err := w.walk(w.dir, f); err != nil {
+		return err
+	}
+	if err := w.create(f, name, 0644, protocol.OWRITE); err != nil {
+		w.clunk(f)
+		return err
+	}
+	if err := w.clunk(f); err != nil {
+		return err
+	}
+	if err := w.walk(w.dir, f, name); err != nil {
+		return err
+	}
+	if err := w.stat(f); err != nil {
+		w.clunk(f)
+		return err
+	}
+	return w.remove(f)
+}
+
+// makeFile makes the -size byte file seq and rand work on, and leaves it
+// open in w.file.
+func makeFile(w *worker) error {
+	w.buf = make([]byte, w.iounit)
+	w.rand.Read(w.buf)
+	f := w.c.GetFID()
+	if err := w.walk(w.dir, f); err != nil {
+		return err
+	}
+	if err := w.create(f, "data", 0644, protocol.ORDWR); err != nil {
+		w.clunk(f)
+		return err
+	}
+	w.file = f
+	return w.fill(f, *size, w.buf)
+}
+
+// seq writes or reads the next part of the file, as much as fits in a
+// message. It writes all of the file, then reads all of it, and so on.
+func seq(w *worker) error {
+	n := int64(len(w.buf))
+	if rest := *size - w.off; rest < n {
+		n = rest
+	}
+	var err error
+	if w.reading {
+		_, err = w.read(w.file, w.off, n)
+	} else {
+		err = w.write(w.file, w.off, w.buf[:n])
+	}
+	if w.off += n; w.off >= *size {
+		w.off, w.reading = 0, !w.reading
+	}
+	return err
+}
+
+// randIO reads or writes -iosize bytes of the file, at a random offset
+// that is a multiple of it.
+func randIO(w *worker) error {
+	n := int64(*iosize)
+	if n > int64(len(w.buf)) {
+		n = int64(len(w.buf))
+	}
+	off := w.rand.Int63n(*size/n) * n
+	if w.rand.Intn(2) == 0 {
+		_, err := w.read(w.file, off, n)
+		return err
+	}
+	return w.write(w.file, off, w.buf[:n])
+}
+
+// makeTree makes the tree list reads: -width files in each directory,
+// and -width directories in each, but those -depth levels down.
+func makeTree(w *worker) error {
+	return w.tree(w.dir, *depth)
+}
+
+func (w *worker) tree(dir protocol.FID, depth int) error {
+	for i := 0; i < *width; i++ {
+		f := w.c.GetFID()
+		if err := w.walk(dir, f); err != nil {
+			return err
+		}
+		if err := w.create(f, fmt.Sprintf("f%d", i), 0644, protocol.OREAD); err != nil {
+			w.clunk(f)
+			return err
+		}
+		if err := w.clunk(f); err != nil {
+			return err
+		}
+		if depth == 0 {
+			continue
+		}
+		name := fmt.Sprintf("d%d", i)
+		if err := w.mkdir(dir, name); err != nil {
+			return err
+		}
+		if err := w.walk(dir, f, name); err != nil {
+			return err
+		}
+		err := w.tree(f, depth-1)
+		w.clunk(f)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// list reads every directory in the tree.
+func list(w *worker) error {
+	return w.list(w.dir)
+}
+
+func (w *worker) list(dir protocol.FID) error {
+	ds, err := w.readDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, d := range ds {
+		if d.QID.Type&protocol.QTDIR == 0 {
+			continue
+		}
+		f := w.c.GetFID()
+		if err := w.walk(dir, f, d.Name); err != nil {
+			return err
+		}
+		err := w.list(f)
+		w.clunk(f)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+// Copyright 2019 The Ninep Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+
+	"github.com/quic-go/quic-go"
+)
+
+// quicProto is the ALPN protocol q9pfs speaks over quic.
+const quicProto = "9p2000"
+
+// dialQUIC dials addr over quic and opens the stream to talk 9P on. The
+// server's certificate is not checked: q9pfs makes a new one each time it
+// starts.
+func dialQUIC(addr string) (io.ReadWriteCloser, error) {
+	tc := &tls.Config{InsecureSkipVerify: true, NextProtos: []string{quicProto}}
+	qc, err := quic.DialAddr(context.Background(), addr, tc, nil)
+	if err != nil {
+		return nil, err
+	}
+	st, err := qc.OpenStreamSync(context.Background())
+	if err != nil {
+		qc.CloseWithError(0, "")
+		return nil, err
+	}
+	return &quicConn{Stream: st, qc: qc}, nil
+}
+
+// A quicConn is a stream that closes its quic connection with it.
+type quicConn struct {
+	*quic.Stream
+	qc *quic.Conn
+}
+
+func (c *quicConn) Close() error {
+	c.Stream.Close()
+	return c.qc.CloseWithError(0, "")
+}
@@ -0,0 +1,162 @@
+// Copyright 2019 The Ninep Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+// A recorder keeps what a worker did: how long each of its calls took,
+// by message type, and how many ops, bytes and errors it had. Each worker
+// has its own, so recording takes no lock; they are merged at the end.
+type recorder struct {
+	calls map[string][]time.Duration
+	ops   int64
+	bytes int64 // read and written
+	errs  int64 // ops that failed
+	err   error // the first to
+}
+
+func newRecorder() *recorder {
+	return &recorder{calls: make(map[string][]time.Duration)}
+}
+
+// call records a call of message type t, made at start.
+func (r *recorder) call(t string, start time.Time) {
+	r.calls[t] = append(r.calls[t], time.Since(start))
+}
+
+// op records an op that ended with err, and returns err.
+func (r *recorder) op(err error) error {
+	r.ops++
+	if err != nil {
+		r.errs++
+		if r.err == nil {
+			r.err = err
+		}
+	}
+	return err
+}
+
+func (r *recorder) merge(o *recorder) {
+	for t, ds := range o.calls {
+		r.calls[t] = append(r.calls[t], ds...)
+	}
+	r.ops += o.ops
+	r.bytes += o.bytes
+	r.errs += o.errs
+	if r.err == nil {
+		r.err = o.err
+	}
+}
+
+// result is what a run of a mix did, in the form it is written as JSON.
+type result struct {
+	Mix        string              `json:"mix"`
+	Net        string              `json:"net"`
+	Clients    int                 `json:"clients"`
+	Seconds    float64             `json:"seconds"`
+	Ops        int64               `json:"ops"`
+	OpsPerSec  float64             `json:"ops_per_sec"`
+	Bytes      int64               `json:"bytes"`
+	MBPerSec   float64             `json:"mb_per_sec"`
+	Errors     int64               `json:"errors"`
+	FirstError string              `json:"first_error,omitempty"`
+	Calls      map[string]*latency `json:"calls"`
+}
+
+// latency is how many calls of a message type there were, and how long
+// they took, in microseconds.
+type latency struct {
+	Count  int     `json:"count"`
+	PerSec float64 `json:"per_sec"`
+	Mean   float64 `json:"mean_us"`
+	P50    float64 `json:"p50_us"`
+	P90    float64 `json:"p90_us"`
+	P99    float64 `json:"p99_us"`
+	Max    float64 `json:"max_us"`
+}
+
+// result sums up what r recorded in a run of mix that took elapsed.
+func (r *recorder) result(mix string, elapsed time.Duration) *result {
+	s := elapsed.Seconds()
+	res := &result{
+		Mix:       mix,
+		Net:       *ntype,
+		Clients:   *clients,
+		Seconds:   s,
+		Ops:       r.ops,
+		OpsPerSec: float64(r.ops) / s,
+		Bytes:     r.bytes,
+		MBPerSec:  float64(r.bytes) / s / (1 << 20),
+		Errors:    r.errs,
+		Calls:     make(map[string]*latency),
+	}
+	if r.err != nil {
+		res.FirstError = r.err.Error()
+	}
+	for t, ds := range r.calls {
+		sort.Slice(ds, func(i, j int) bool { return ds[i] < ds[j] })
+		var sum time.Duration
+		for _, d := range ds {
+			sum += d
+		}
+		// The nearest rank: the smallest that at least q of them are
+		// no more than.
+		at := func(q float64) float64 {
+			i := int(q*float64(len(ds))+0.999999) - 1
+			if i < 0 {
+				i = 0
+			}
+			return us(ds[i])
+		}
+		res.Calls[t] = &latency{
+			Count:  len(ds),
+			PerSec: float64(len(ds)) / s,
+			Mean:   us(sum / time.Duration(len(ds))),
+			P50:    at(0.5),
+			P90:    at(0.9),
+			P99:    at(0.99),
+			Max:    us(ds[len(ds)-1]),
+		}
+	}
+	return res
+}
+
+func us(d time.Duration) float64 {
+	return float64(d) / float64(time.Microsecond)
+}
+
+// WriteText writes r for people to read.
+func (r *result) WriteText(w io.Writer) {
+	fmt.Fprintf(w, "%s: %d clients over %s for %.1fs: %d ops, %.1f ops/s, %.2f MB/s, %d errors\n",
+		r.Mix, r.Clients, r.Net, r.Seconds, r.Ops, r.OpsPerSec, r.MBPerSec, r.Errors)
+	if r.FirstError != "" {
+		fmt.Fprintf(w, "first error: %s\n", r.FirstError)
+	}
+	var ts []string
+	for t := range r.Calls {
+		ts = append(ts, t)
+	}
+	sort.Strings(ts)
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "\tcalls\tper s\tmean\tp50\tp90\tp99\tmax\t\n")
+	for _, t := range ts {
+		l := r.Calls[t]
+		fmt.Fprintf(tw, "%s\t%d\t%.1f\t%v\t%v\t%v\t%v\t%v\t\n", t, l.Count, l.PerSec,
+			dur(l.Mean), dur(l.P50), dur(l.P90), dur(l.P99), dur(l.Max))
+	}
+	tw.Flush()
+	fmt.Fprintln(w)
+}
+
+// dur turns microseconds back into a Duration, for printing.
+func dur(us float64) time.Duration {
+	return time.Duration(us * float64(time.Microsecond)).Round(time.Microsecond)
+}
@@ -10,25 +10,44 @@ import (
 	"log"
 	"math/big"
 
-	quic "github.com/lucas-clemente/quic-go"
+	"sevki.org/q9p/admin"
 	"sevki.org/q9p/filesystem"
 	"sevki.org/q9p/protocol"
 )
 
 const addr = "localhost:4242"
 
+var (
+	aaddr = flag.String("admin", "", "Network address for the admin HTTP server (pprof, metrics, health, conns); none if empty")
+
+	options = filesystem.Flags(flag.CommandLine)
+)
+
 func main() {
 	flag.Parse()
 
-	listener, err := quic.ListenAddr(addr, generateTLSConfig(), nil)
+	listener, err := listenQUIC(addr, generateTLSConfig())
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	filesystemlistener, err := filesystem.Newfilesystem(func(l *protocol.Listener) error {
-		l.Trace = nil // log.Printf
-		return nil
-	})
+	opts, err := options()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *aaddr != "" {
+		opts = append(opts, filesystem.Metrics(protocol.NewMetrics("q9p_server")))
+	}
+	filesystemlistener, err := filesystem.Newfilesystem(opts...)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *aaddr != "" {
+		go func() {
+			log.Fatal(admin.Serve(*aaddr, filesystemlistener))
+		}()
+	}
 
 	if err := filesystemlistener.Serve(listener); err != nil {
 		log.Fatal(err)
@@ -54,4 +73,4 @@ func generateTLSConfig() *tls.Config {
 		panic(err)
 	}
 	return &tls.Config{Certificates: []tls.Certificate{tlsCert}}
-}
\ No newline at end of file
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+
+	"github.com/quic-go/quic-go"
+)
+
+// quicProto is the ALPN protocol q9pfs speaks over quic; 9pbench asks
+// for the same.
+const quicProto = "9p2000"
+
+// listenQUIC listens on addr over quic. Each connection's first stream is
+// a connection to Serve; the quic connection is closed with it.
+func listenQUIC(addr string, tc *tls.Config) (net.Listener, error) {
+	tc = tc.Clone()
+	tc.NextProtos = []string{quicProto}
+	ln, err := quic.ListenAddr(addr, tc, nil)
+	if err != nil {
+		return nil, err
+	}
+	l := &quicListener{
+		ln:    ln,
+		conns: make(chan net.Conn),
+		done:  make(chan struct{}),
+	}
+	go l.accept()
+	return l, nil
+}
+
+// A quicListener is a quic.Listener that is a net.Listener.
+type quicListener struct {
+	ln    *quic.Listener
+	conns chan net.Conn
+	done  chan struct{} // closed, with err set, when accept stops
+	err   error
+}
+
+// accept accepts connections, and waits for the stream of each in a
+// goroutine of its own, so one slow client does not hold up the rest.
+func (l *quicListener) accept() {
+	for {
+		qc, err := l.ln.Accept(context.Background())
+		if err != nil {
+			l.err = err
+			close(l.done)
+			return
+		}
+		go func() {
+			st, err := qc.AcceptStream(context.Background())
+			if err != nil {
+				qc.CloseWithError(0, "")
+				return
+			}
+			c := &quicConn{Stream: st, qc: qc}
+			select {
+			case l.conns <- c:
+			case <-l.done:
+				c.Close()
+			}
+		}()
+	}
+}
+
+func (l *quicListener) Accept() (net.Conn, error) {
+	select {
+	case c := <-l.conns:
+		return c, nil
+	case <-l.done:
+		return nil, l.err
+	}
+}
+
+func (l *quicListener) Close() error   { return l.ln.Close() }
+func (l *quicListener) Addr() net.Addr { return l.ln.Addr() }
+
+// A quicConn is a stream that is a net.Conn, and closes its quic
+// connection with it.
+type quicConn struct {
+	*quic.Stream
+	qc *quic.Conn
+}
+
+func (c *quicConn) LocalAddr() net.Addr  { return c.qc.LocalAddr() }
+func (c *quicConn) RemoteAddr() net.Addr { return c.qc.RemoteAddr() }
+
+func (c *quicConn) Close() error {
+	c.Stream.Close()
+	return c.qc.CloseWithError(0, "")
+}
@@ -9,6 +9,7 @@ import (
 	"log"
 	"net"
 
+	"sevki.org/q9p/admin"
 	filesystem "sevki.org/q9p/filesystem"
 	"sevki.org/q9p/protocol"
 )
@@ -16,6 +17,9 @@ import (
 var (
 	ntype = flag.String("ntype", "tcp4", "Default network type")
 	naddr = flag.String("addr", ":5640", "Network address")
+	aaddr = flag.String("admin", "", "Network address for the admin HTTP server (pprof, metrics, health, conns); none if empty")
+
+	options = filesystem.Flags(flag.CommandLine)
 )
 
 func main() {
@@ -26,10 +30,23 @@ func main() {
 		log.Fatalf("Listen failed: %v", err)
 	}
 
-	filesystemlistener, err := filesystem.Newfilesystem(func(l *protocol.Listener) error {
-		l.Trace = nil // log.Printf
-		return nil
-	})
+	opts, err := options()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *aaddr != "" {
+		opts = append(opts, filesystem.Metrics(protocol.NewMetrics("q9p_server")))
+	}
+	filesystemlistener, err := filesystem.Newfilesystem(opts...)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *aaddr != "" {
+		go func() {
+			log.Fatal(admin.Serve(*aaddr, filesystemlistener))
+		}()
+	}
 
 	if err := filesystemlistener.Serve(ln); err != nil {
 		log.Fatal(err)
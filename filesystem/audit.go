@@ -0,0 +1,226 @@
+// Copyright 2019 The Ninep Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package filesystem
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"sevki.org/q9p/protocol"
+)
+
+// An AuditRecord says who changed what. Op is one of
+//
+//	create     Tcreate of Path, with Mode the 9P perm
+//	write      the Twrites to Path between its open and clunk
+//	rename     Twstat of Path's name to NewPath
+//	chmod      Twstat of Path's mode to Mode
+//	truncate   Twstat of Path's length to Length, or Topen of it with OTRUNC
+//	remove     Tremove of Path
+//
+// Err is empty if the operation worked. For a write it is the first
+// Twrite that failed, if any.
+type AuditRecord struct {
+	Time    time.Time `json:"time"`
+	Op      string    `json:"op"`
+	Path    string    `json:"path"`
+	NewPath string    `json:"newpath,omitempty"`
+	Mode    string    `json:"mode,omitempty"`   // in octal, e.g. 0644
+	Length  *int64    `json:"length,omitempty"` // for truncate
+	Writes  int       `json:"writes,omitempty"`
+	Bytes   int64     `json:"bytes,omitempty"`
+	User    string    `json:"user"`
+	Remote  string    `json:"remote"`
+	Err     string    `json:"error,omitempty"`
+}
+
+// An AuditSink keeps AuditRecords. Record is called by each connection's
+// FileServer as the operations are answered, from many goroutines. If it
+// fails the FileServer logs it; the operation has happened regardless.
+type AuditSink interface {
+	Record(r *AuditRecord) error
+}
+
+// AuditLog is an AuditSink that appends each record to a file as a line
+// of JSON, and syncs the file before Record returns. Once the file is
+// bigger than MaxSize it is renamed Path.1, Path.1 is renamed Path.2 and
+// so on up to Path.Keep, and a new one is started.
+type AuditLog struct {
+	Path    string
+	MaxSize int64 // zero means no limit
+	Keep    int   // old files kept; zero means none
+
+	mu     sync.Mutex
+	f      *os.File // nil if closed, or if it could not be reopened
+	size   int64
+	closed bool
+}
+
+// NewAuditLog returns an AuditLog appending to path, which it creates if
+// need be.
+func NewAuditLog(path string, maxSize int64, keep int) (*AuditLog, error) {
+	l := &AuditLog{Path: path, MaxSize: maxSize, Keep: keep}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *AuditLog) open() error {
+	f, err := os.OpenFile(l.Path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	st, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	l.f, l.size = f, st.Size()
+	return syncDir(l.Path)
+}
+
+// rotate closes the file, shifts it and the old ones along, and opens a
+// new one. If that fails l.f is nil, for Record to reopen.
+func (l *AuditLog) rotate() error {
+	err := l.f.Close()
+	l.f = nil
+	if err != nil {
+		return err
+	}
+	if l.Keep <= 0 {
+		os.Remove(l.Path)
+	} else {
+		for i := l.Keep - 1; i >= 1; i-- {
+			os.Rename(fmt.Sprintf("%v.%d", l.Path, i), fmt.Sprintf("%v.%d", l.Path, i+1))
+		}
+		if err := os.Rename(l.Path, l.Path+".1"); err != nil {
+			return err
+		}
+	}
+	return l.open()
+}
+
+// syncDir syncs the directory holding path, so that a file created or
+// renamed in it stays that way.
+func syncDir(path string) error {
+	d, err := os.Open(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	if err := d.Sync(); err != nil && !os.IsPermission(err) {
+		return err
+	}
+	return nil
+}
+
+// Record implements AuditSink.
+func (l *AuditLog) Record(r *AuditRecord) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return os.ErrClosed
+	}
+	// A failed rotation is retried on the next record; until then the
+	// records go on being appended to Path, so that none is lost.
+	var rerr error
+	if l.f != nil && l.MaxSize > 0 && l.size > 0 && l.size+int64(len(b)) > l.MaxSize {
+		if err := l.rotate(); err != nil {
+			rerr = fmt.Errorf("rotating %v: %v", l.Path, err)
+		}
+	}
+	if l.f == nil {
+		if err := l.open(); err != nil {
+			if rerr != nil {
+				return rerr
+			}
+			return err
+		}
+	}
+	n, err := l.f.Write(b)
+	l.size += int64(n)
+	if err != nil {
+		return err
+	}
+	if err := l.f.Sync(); err != nil {
+		return err
+	}
+	return rerr
+}
+
+// Close closes the file.
+func (l *AuditLog) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.closed = true
+	if l.f == nil {
+		return nil
+	}
+	err := l.f.Close()
+	l.f = nil
+	return err
+}
+
+// writes adds up the Twrites to an open file, for its write record.
+type writes struct {
+	n     int
+	bytes int64
+	err   error // the first
+}
+
+// audit records op on f, with its result err, if the FileServer has an
+// AuditSink. r has the details of the op; audit fills in the rest.
+func (e *FileServer) audit(f *file, r AuditRecord, err error) {
+	if e.Audit == nil {
+		return
+	}
+	r.Time = time.Now()
+	if r.Path == "" {
+		r.Path = f.fullName
+	}
+	r.User, r.Remote = f.uname, f.remote
+	if err != nil {
+		r.Err = err.Error()
+	}
+	if err := e.Audit.Record(&r); err != nil {
+		log.Printf("audit: %v %v: %v", r.Op, r.Path, err)
+	}
+}
+
+// wrote notes a Twrite to f.
+func (f *file) wrote(n int, err error) {
+	if f.writes == nil {
+		f.writes = &writes{}
+	}
+	f.writes.n++
+	f.writes.bytes += int64(n)
+	if f.writes.err == nil {
+		f.writes.err = err
+	}
+}
+
+// auditWrites records the Twrites to f, if there were any.
+func (e *FileServer) auditWrites(f *file) {
+	if w := f.writes; w != nil {
+		f.writes = nil
+		e.audit(f, AuditRecord{Op: "write", Writes: w.n, Bytes: w.bytes}, w.err)
+	}
+}
+
+// permString formats a 9P perm or mode for an AuditRecord.
+func permString(p protocol.Perm) string {
+	return fmt.Sprintf("%#o", uint32(p))
+}
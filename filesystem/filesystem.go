@@ -6,14 +6,16 @@ package filesystem
 
 import (
 	"bytes"
-	"flag"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"os"
 	"path"
 	"path/filepath"
+	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"sevki.org/q9p/protocol"
@@ -27,31 +29,53 @@ type file struct {
 	// At that point it might be too big. We save it here if that happens,
 	// and on the next directory read we start with that.
 	oflow []byte
+	// dirOff is where the next directory read must be, if not at 0.
+	dirOff protocol.Offset
+	// uname is the user that attached, and remote where from, for the
+	// audit records; writes adds up the Twrites since it was opened.
+	uname  string
+	remote string
+	writes *writes
 }
 
 type FileServer struct {
 	root      *file
 	rootPath  string
+	user      string // who owns the files
+	readOnly  bool
 	Versioned bool
 	IOunit    protocol.MaxSize
 
+	// Audit, if not nil, is told of each change made to the files.
+	Audit AuditSink
+
+	// fids is the connection's FidTable, which knows where it is from.
+	fids *protocol.FidTable
+
+	// span is the span of the message being answered, if the Listener
+	// keeps spans. The connection answers one message at a time.
+	span *protocol.Span
+
 	// mu guards below
 	mu    sync.Mutex
 	files map[protocol.FID]*file
 }
 
-var (
-	debug = flag.Int("debug", 0, "print debug messages")
-	root  = flag.String("root", "/", "Set the root for all attaches")
-)
+// errShortDirRead is the error for a directory read with too small a
+// count for the next entry.
+var errShortDirRead = errors.New("read count too small for directory entry")
+
+// errDirOffset is the error for a directory read that neither starts over
+// at 0 nor carries on where the last one ended.
+var errDirOffset = errors.New("bad offset in directory read")
 
-func stat(s string) (*protocol.Dir, protocol.QID, error) {
+func (e *FileServer) stat(s string) (*protocol.Dir, protocol.QID, error) {
 	var q protocol.QID
 	st, err := os.Lstat(s)
 	if err != nil {
-		return nil, q, fmt.Errorf("does not exist")
+		return nil, q, err
 	}
-	d, err := dirTo9p2000Dir(st)
+	d, err := dirTo9p2000Dir(st, e.user)
 	if err != nil {
 		return nil, q, nil
 	}
@@ -59,7 +83,9 @@ func stat(s string) (*protocol.Dir, protocol.QID, error) {
 	return d, q, nil
 }
 
+// Rversion starts a new session, which clunks every fid of the old one.
 func (e *FileServer) Rversion(msize protocol.MaxSize, version string) (protocol.MaxSize, string, error) {
+	e.Close()
 	if version != "9P2000" {
 		return 0, "", fmt.Errorf("%v not supported; only 9P2000", version)
 	}
@@ -67,12 +93,22 @@ func (e *FileServer) Rversion(msize protocol.MaxSize, version string) (protocol.
 	return msize, version, nil
 }
 
+// SetFidTable implements protocol.FidTableSetter.
+func (e *FileServer) SetFidTable(t *protocol.FidTable) {
+	e.fids = t
+}
+
+// SetSpan implements protocol.SpanSetter.
+func (e *FileServer) SetSpan(s *protocol.Span) {
+	e.span = s
+}
+
 func (e *FileServer) getFile(fid protocol.FID) (*file, error) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 	f, ok := e.files[fid]
 	if !ok {
-		return nil, fmt.Errorf("does not exist")
+		return nil, protocol.ErrUnknownFID
 	}
 
 	return f, nil
@@ -89,8 +125,18 @@ func (e *FileServer) Rattach(fid protocol.FID, afid protocol.FID, uname string,
 	if err != nil {
 		return protocol.QID{}, err
 	}
-	r := &file{fullName: aname}
+	r := &file{fullName: aname, uname: uname}
+	if e.fids != nil {
+		if f, ok := e.fids.Get(fid); ok {
+			r.remote = f.Remote
+		}
+	}
 	r.QID = fileInfoToQID(st)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, ok := e.files[fid]; ok {
+		return protocol.QID{}, protocol.ErrDupFID
+	}
 	e.files[fid] = r
 	e.root = r
 	return r.QID, nil
@@ -105,16 +151,25 @@ func (e *FileServer) Rwalk(fid protocol.FID, newfid protocol.FID, paths []string
 	f, ok := e.files[fid]
 	e.mu.Unlock()
 	if !ok {
-		return nil, fmt.Errorf("does not exist")
+		return nil, protocol.ErrUnknownFID
+	}
+	if f.file != nil {
+		return nil, protocol.ErrCloneOpen
+	}
+	if len(paths) > protocol.MAXWELEM {
+		return nil, protocol.ErrTooManyNames
 	}
 	if len(paths) == 0 {
+		if fid == newfid {
+			return []protocol.QID{}, nil
+		}
 		e.mu.Lock()
 		defer e.mu.Unlock()
 		_, ok := e.files[newfid]
 		if ok {
-			return nil, fmt.Errorf("FID in use: clone walk, fid %d newfid %d", fid, newfid)
+			return nil, protocol.ErrDupFID
 		}
-		nf := *f
+		nf := file{fullName: f.fullName, QID: f.QID, uname: f.uname, remote: f.remote}
 		e.files[newfid] = &nf
 		return []protocol.QID{}, nil
 	}
@@ -123,7 +178,11 @@ func (e *FileServer) Rwalk(fid protocol.FID, newfid protocol.FID, paths []string
 
 	var i int
 	for i = range paths {
-		p = path.Join(p, paths[i])
+		// Only a directory can be walked from.
+		if i > 0 && q[i-1].Type&protocol.QTDIR == 0 {
+			return q[:i], nil
+		}
+		p = e.inRoot(path.Join(p, paths[i]))
 		st, err := os.Lstat(p)
 		if err != nil {
 			// From the RFC: If the first element cannot be walked for any
@@ -140,7 +199,7 @@ func (e *FileServer) Rwalk(fid protocol.FID, newfid protocol.FID, paths []string
 			// to sum up: if any walks have succeeded, you return the QIDS for
 			// one more than the last successful walk
 			if i == 0 {
-				return nil, fmt.Errorf("file does not exist")
+				return nil, err
 			}
 			// we only get here if i is > 0 and less than nwname,
 			// so the i should be safe.
@@ -153,27 +212,70 @@ func (e *FileServer) Rwalk(fid protocol.FID, newfid protocol.FID, paths []string
 	// this is quite unlikely, which is why we don't bother checking for it first.
 	if fid != newfid {
 		if _, ok := e.files[newfid]; ok {
-			return nil, fmt.Errorf("FID in use: walk to %v, fid %v, newfid %v", paths, fid, newfid)
+			return nil, protocol.ErrDupFID
 		}
 	}
-	e.files[newfid] = &file{fullName: p, QID: q[i]}
+	e.files[newfid] = &file{fullName: p, QID: q[i], uname: f.uname, remote: f.remote}
 	return q, nil
 }
 
+// isRoot reports whether p is the root, which may be neither removed nor
+// renamed.
+func (e *FileServer) isRoot(p string) bool {
+	return p == path.Clean(e.rootPath)
+}
+
+// checkWrite returns the error for op, a change to the file p, if the
+// server is read only.
+func (e *FileServer) checkWrite(op, p string) error {
+	if e.readOnly {
+		return &os.PathError{Op: op, Path: p, Err: syscall.EROFS}
+	}
+	return nil
+}
+
+// inRoot returns p, or the root if p is outside it, so .. at the root is
+// the root.
+func (e *FileServer) inRoot(p string) string {
+	r := path.Clean(e.rootPath)
+	if r != "/" && p != r && !strings.HasPrefix(p, r+"/") {
+		return r
+	}
+	return p
+}
+
 func (e *FileServer) Ropen(fid protocol.FID, mode protocol.Mode) (protocol.QID, protocol.MaxSize, error) {
 	e.mu.Lock()
 	f, ok := e.files[fid]
 	e.mu.Unlock()
 	if !ok {
-		return protocol.QID{}, 0, fmt.Errorf("does not exist")
+		return protocol.QID{}, 0, protocol.ErrUnknownFID
+	}
+	if f.file != nil {
+		return protocol.QID{}, 0, protocol.ErrFIDOpen
 	}
 
-	var err error
-	f.file, err = os.OpenFile(f.fullName, modeToUnixFlags(mode), 0)
+	if m := mode & 3; (m != protocol.OREAD && m != protocol.OEXEC) || mode&(protocol.OTRUNC|protocol.ORCLOSE) != 0 {
+		if err := e.checkWrite("open", f.fullName); err != nil {
+			return protocol.QID{}, 0, err
+		}
+	}
+	o, err := os.OpenFile(f.fullName, modeToUnixFlags(mode), 0)
+	if mode&protocol.OTRUNC != 0 {
+		var zero int64
+		e.audit(f, AuditRecord{Op: "truncate", Length: &zero}, err)
+	}
 	if err != nil {
 		return protocol.QID{}, 0, err
 	}
-
+	// The file at f's name may not be the one walked to, if that has
+	// been renamed, so the QID is the opened file's.
+	st, err := o.Stat()
+	if err != nil {
+		o.Close()
+		return protocol.QID{}, 0, err
+	}
+	f.file, f.QID = o, fileInfoToQID(st)
 	return f.QID, e.IOunit, nil
 }
 func (e *FileServer) Rcreate(fid protocol.FID, name string, perm protocol.Perm, mode protocol.Mode) (protocol.QID, protocol.MaxSize, error) {
@@ -182,13 +284,31 @@ func (e *FileServer) Rcreate(fid protocol.FID, name string, perm protocol.Perm,
 		return protocol.QID{}, 0, err
 	}
 	if f.file != nil {
-		return protocol.QID{}, 0, fmt.Errorf("FID already open")
+		return protocol.QID{}, 0, protocol.ErrFIDOpen
 	}
 	n := path.Join(f.fullName, name)
+	q, iounit, err := e.create(f, n, perm, mode)
+	e.audit(f, AuditRecord{Op: "create", Path: n, Mode: permString(perm)}, err)
+	return q, iounit, err
+}
+
+// create creates the file n in f's directory, and makes f it, open. It
+// is an error if n is there already.
+func (e *FileServer) create(f *file, n string, perm protocol.Perm, mode protocol.Mode) (protocol.QID, protocol.MaxSize, error) {
+	// Join has cleaned n, so a name of ., .. or with a / in it is not
+	// in f's directory.
+	if path.Dir(n) != f.fullName {
+		return protocol.QID{}, 0, &os.PathError{Op: "create", Path: n, Err: os.ErrInvalid}
+	}
+	if err := e.checkWrite("create", n); err != nil {
+		return protocol.QID{}, 0, err
+	}
 	if perm&protocol.Perm(protocol.DMDIR) != 0 {
 		p := os.FileMode(int(perm) & 0777)
-		err := os.Mkdir(n, p)
-		_, q, err := stat(n)
+		if err := os.Mkdir(n, p); err != nil {
+			return protocol.QID{}, 0, err
+		}
+		_, q, err := e.stat(n)
 		if err != nil {
 			return protocol.QID{}, 0, err
 		}
@@ -198,23 +318,23 @@ func (e *FileServer) Rcreate(fid protocol.FID, name string, perm protocol.Perm,
 		}
 		f.fullName = n
 		f.QID = q
-		return q, 8000, err
+		return q, e.IOunit, err
 	}
 
-	m := modeToUnixFlags(mode) | os.O_CREATE | os.O_TRUNC
+	m := modeToUnixFlags(mode) | os.O_CREATE | os.O_EXCL
 	p := os.FileMode(perm) & 0777
 	of, err := os.OpenFile(n, m, p)
 	if err != nil {
 		return protocol.QID{}, 0, err
 	}
-	_, q, err := stat(n)
+	_, q, err := e.stat(n)
 	if err != nil {
 		return protocol.QID{}, 0, err
 	}
 	f.fullName = n
 	f.QID = q
 	f.file = of
-	return q, 8000, err
+	return q, e.IOunit, err
 }
 func (e *FileServer) Rclunk(fid protocol.FID) error {
 	_, err := e.clunk(fid)
@@ -228,9 +348,9 @@ func (e *FileServer) Rstat(fid protocol.FID) ([]byte, error) {
 	}
 	st, err := os.Lstat(f.fullName)
 	if err != nil {
-		return []byte{}, fmt.Errorf("ENOENT")
+		return []byte{}, err
 	}
-	d, err := dirTo9p2000Dir(st)
+	d, err := dirTo9p2000Dir(st, e.user)
 	if err != nil {
 		return []byte{}, nil
 	}
@@ -239,7 +359,6 @@ func (e *FileServer) Rstat(fid protocol.FID) ([]byte, error) {
 	return b.Bytes(), nil
 }
 func (e *FileServer) Rwstat(fid protocol.FID, b []byte) error {
-	var changed bool
 	f, err := e.getFile(fid)
 	if err != nil {
 		return err
@@ -248,18 +367,30 @@ func (e *FileServer) Rwstat(fid protocol.FID, b []byte) error {
 	if err != nil {
 		return err
 	}
+	// A wstat that changes nothing asks for the file to be synced, which
+	// even a read only server may do.
+	if dir.Mode == ^uint32(0) && dir.Name == "" && dir.User == "" && dir.Group == "" &&
+		dir.Length == ^uint64(0) && dir.Mtime == ^uint32(0) && dir.Atime == ^uint32(0) {
+		if f.file != nil {
+			f.file.Sync()
+		}
+		return nil
+	}
+	if err := e.checkWrite("wstat", f.fullName); err != nil {
+		return err
+	}
 	if dir.Mode != 0xFFFFFFFF {
-		changed = true
 		mode := dir.Mode & 0777
-		if err := os.Chmod(f.fullName, os.FileMode(mode)); err != nil {
+		err := os.Chmod(f.fullName, os.FileMode(mode))
+		e.audit(f, AuditRecord{Op: "chmod", Mode: permString(protocol.Perm(mode))}, err)
+		if err != nil {
 			return err
 		}
 	}
 
 	// Try to find local uid, gid by name.
 	if dir.User != "" || dir.Group != "" {
-		return fmt.Errorf("Permission denied")
-		changed = true
+		return os.ErrPermission
 	}
 
 	/*
@@ -274,7 +405,6 @@ func (e *FileServer) Rwstat(fid protocol.FID, b []byte) error {
 	*/
 
 	if dir.Name != "" {
-		changed = true
 		// If we path.Join dir.Name to / before adding it to
 		// the fid path, that ensures nobody gets to walk out of the
 		// root of this server.
@@ -288,22 +418,30 @@ func (e *FileServer) Rwstat(fid protocol.FID, b []byte) error {
 			newname = path.Join(e.rootPath, dir.Name)
 		}
 
-		// If to exists, and to is a directory, we can't do the
-		// rename, since os.Rename will move from into to.
-
-		st, err := os.Stat(newname)
-		if err == nil && st.IsDir() {
-			return fmt.Errorf("is a directory")
-		}
-		if err := os.Rename(f.fullName, newname); err != nil {
-			return err
+		// It is an error to rename the root, or to take the name of an
+		// existing file, which os.Rename would replace; taking its own
+		// name changes nothing.
+		if newname != f.fullName || e.isRoot(f.fullName) {
+			if e.isRoot(f.fullName) {
+				err = &os.PathError{Op: "rename", Path: f.fullName, Err: os.ErrPermission}
+			} else if _, err = os.Lstat(newname); err == nil {
+				err = &os.PathError{Op: "rename", Path: newname, Err: os.ErrExist}
+			} else {
+				err = os.Rename(f.fullName, newname)
+			}
+			e.audit(f, AuditRecord{Op: "rename", NewPath: newname}, err)
+			if err != nil {
+				return err
+			}
 		}
 		f.fullName = newname
 	}
 
 	if dir.Length != 0xFFFFFFFFFFFFFFFF {
-		changed = true
-		if err := os.Truncate(f.fullName, int64(dir.Length)); err != nil {
+		length := int64(dir.Length)
+		err := os.Truncate(f.fullName, length)
+		e.audit(f, AuditRecord{Op: "truncate", Length: &length}, err)
+		if err != nil {
 			return err
 		}
 	}
@@ -311,7 +449,6 @@ func (e *FileServer) Rwstat(fid protocol.FID, b []byte) error {
 	// If either mtime or atime need to be changed, then
 	// we must change both.
 	if dir.Mtime != ^uint32(0) || dir.Atime != ^uint32(0) {
-		changed = true
 		mt, at := time.Unix(int64(dir.Mtime), 0), time.Unix(int64(dir.Atime), 0)
 		if cmt, cat := (dir.Mtime == ^uint32(0)), (dir.Atime == ^uint32(0)); cmt || cat {
 			st, err := os.Stat(f.fullName)
@@ -329,10 +466,6 @@ func (e *FileServer) Rwstat(fid protocol.FID, b []byte) error {
 			return err
 		}
 	}
-
-	if !changed && f.file != nil {
-		f.file.Sync()
-	}
 	return nil
 }
 
@@ -341,9 +474,10 @@ func (e *FileServer) clunk(fid protocol.FID) (*file, error) {
 	defer e.mu.Unlock()
 	f, ok := e.files[fid]
 	if !ok {
-		return nil, fmt.Errorf("does not exist")
+		return nil, protocol.ErrUnknownFID
 	}
 	delete(e.files, fid)
+	e.auditWrites(f)
 	// What do we do if we can't close it?
 	// All I can think of is to log it.
 	if f.file != nil {
@@ -361,7 +495,31 @@ func (e *FileServer) Rremove(fid protocol.FID) error {
 	if err != nil {
 		return err
 	}
-	return os.Remove(f.fullName)
+	switch {
+	case e.isRoot(f.fullName):
+		err = &os.PathError{Op: "remove", Path: f.fullName, Err: os.ErrPermission}
+	case e.readOnly:
+		err = e.checkWrite("remove", f.fullName)
+	default:
+		err = os.Remove(f.fullName)
+	}
+	e.audit(f, AuditRecord{Op: "remove"}, err)
+	return err
+}
+
+// Close clunks the fids left when the connection goes, closing their
+// files and recording their writes.
+func (e *FileServer) Close() error {
+	e.mu.Lock()
+	fids := make([]protocol.FID, 0, len(e.files))
+	for fid := range e.files {
+		fids = append(fids, fid)
+	}
+	e.mu.Unlock()
+	for _, fid := range fids {
+		e.clunk(fid)
+	}
+	return nil
 }
 
 func (e *FileServer) Rread(fid protocol.FID, o protocol.Offset, c protocol.Count) ([]byte, error) {
@@ -370,7 +528,7 @@ func (e *FileServer) Rread(fid protocol.FID, o protocol.Offset, c protocol.Count
 		return nil, err
 	}
 	if f.file == nil {
-		return nil, fmt.Errorf("FID not open")
+		return nil, protocol.ErrFIDNotOpen
 	}
 	if f.QID.Type&protocol.QTDIR != 0 {
 		if o == 0 {
@@ -378,54 +536,53 @@ func (e *FileServer) Rread(fid protocol.FID, o protocol.Offset, c protocol.Count
 			if err := resetDir(f); err != nil {
 				return nil, err
 			}
+			f.dirOff = 0
+		}
+		if o != f.dirOff {
+			return nil, errDirOffset
 		}
 
-		// We make the assumption that they can always fit at least one
-		// directory entry into a read. If that assumption does not hold
-		// so many things are broken that we can't fix them here.
-		// But we'll drop out of the loop below having returned nothing
-		// anyway.
-		b := bytes.NewBuffer(f.oflow)
-		f.oflow = nil
-		pos := 0
-
+		// Entries are returned whole, as many as fit in c. The one that
+		// does not fit is kept in oflow for the next read.
+		var b []byte
+		var d bytes.Buffer
 		for {
-			if b.Len() > int(c) {
-				f.oflow = b.Bytes()[pos:]
-				return b.Bytes()[:pos], nil
-			}
-			pos += b.Len()
-			st, err := f.file.Readdir(1)
-			if err == io.EOF {
-				return b.Bytes(), nil
-			}
-			if err != nil {
-				return nil, err
+			if f.oflow == nil {
+				st, err := f.file.Readdir(1)
+				// A directory removed while open is empty, though
+				// Linux says it does not exist.
+				if err == io.EOF || os.IsNotExist(err) {
+					break
+				}
+				if err != nil {
+					return nil, err
+				}
+				d9p, err := dirTo9p2000Dir(st[0], e.user)
+				if err != nil {
+					return nil, err
+				}
+				protocol.Marshaldir(&d, *d9p)
+				f.oflow = append([]byte(nil), d.Bytes()...)
 			}
-
-			d9p, err := dirTo9p2000Dir(st[0])
-			if err != nil {
-				return nil, err
+			if len(b)+len(f.oflow) > int(c) {
+				if len(b) == 0 {
+					return nil, errShortDirRead
+				}
+				break
 			}
-			protocol.Marshaldir(b, *d9p)
-			// Seen on linux clients: sometimes the math is wrong and
-			// they end up asking for the last element with not enough data.
-			// Linux bug or bug with this server? Not sure yet.
-			if b.Len() > int(c) {
-				log.Printf("Warning: Server bug? %v, need %d bytes;count is %d: skipping", d9p, b.Len(), c)
-				return nil, nil
-			}
-			// We're not quite doing the array right.
-			// What does work is returning one thing so, for now, do that.
-			return b.Bytes(), nil
+			b = append(b, f.oflow...)
+			f.oflow = nil
 		}
-		return b.Bytes(), nil
+		f.dirOff += protocol.Offset(len(b))
+		return b, nil
 	}
 
 	// N.B. even if they ask for 0 bytes on some file systems it is important to pass
 	// through a zero byte read (not Unix, of course).
 	b := make([]byte, c)
+	s := e.span.Child("ReadAt")
 	n, err := f.file.ReadAt(b, int64(o))
+	s.End()
 	if err != nil && err != io.EOF {
 		return nil, err
 	}
@@ -438,32 +595,37 @@ func (e *FileServer) Rwrite(fid protocol.FID, o protocol.Offset, b []byte) (prot
 		return -1, err
 	}
 	if f.file == nil {
-		return -1, fmt.Errorf("FID not open")
+		return -1, protocol.ErrFIDNotOpen
 	}
 
 	// N.B. even if they ask for 0 bytes on some file systems it is important to pass
 	// through a zero byte write (not Unix, of course). Also, let the underlying file system
 	// manage the error if the open mode was wrong. No need to duplicate the logic.
 
+	s := e.span.Child("WriteAt")
 	n, err := f.file.WriteAt(b, int64(o))
+	s.End()
+	f.wrote(n, err)
 	return protocol.Count(n), err
 }
 
-func Newfilesystem(opts ...protocol.ListenerOpt) (*protocol.Listener, error) {
-	nsCreator := func() protocol.NineServer {
-		f := &FileServer{}
-		f.files = make(map[protocol.FID]*file)
-		f.rootPath = *root // for now.
-		f.IOunit = 8192
-		// any opts for the filesystem layer can be added here too ...
-		var d protocol.NineServer = f
-		if *debug != 0 {
-			d = &debugFileServer{f}
+// Newfilesystem returns a Listener that makes a FileServer for each
+// connection, as DefaultOptions changed by opts say.
+func Newfilesystem(opts ...Option) (*protocol.Listener, error) {
+	o := DefaultOptions()
+	for _, opt := range opts {
+		if err := opt(&o); err != nil {
+			return nil, err
 		}
-		return d
 	}
 
-	l, err := protocol.NewListener(nsCreator, opts...)
+	// The fid table keeps walks from open fids, I/O on unopened ones and
+	// the like from getting as far as the OS.
+	lopts := append([]protocol.ListenerOpt{func(l *protocol.Listener) error {
+		l.FidTable = true
+		return nil
+	}}, o.Listener...)
+	l, err := protocol.NewListener(o.NewServer, lopts...)
 	if err != nil {
 		return nil, err
 	}
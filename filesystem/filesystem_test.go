@@ -2,15 +2,22 @@ package filesystem
 
 import (
 	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"io/ioutil"
 	"net"
 	"os"
 	"path"
+	"reflect"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"sevki.org/q9p/protocol"
+	"sevki.org/q9p/protocol/protocoltest"
 )
 
 func print(f string, args ...interface{}) {
@@ -77,12 +84,11 @@ func TestMount(t *testing.T) {
 	if err != nil {
 		t.Fatalf("%v", err)
 	}
-	t.Logf("Client is %v", c.String())
 
-	n, err := Newfilesystem(func(s *protocol.Server) error {
-		s.Trace = print //t.Logf
+	n, err := Newfilesystem(ListenerOpts(func(l *protocol.Listener) error {
+		l.Trace = print //t.Logf
 		return nil
-	})
+	}))
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -111,6 +117,9 @@ func TestMount(t *testing.T) {
 	if err == nil {
 		t.Fatalf("CallTwalk(0,1,[\"hi\", \"there\"]): want err, got nil")
 	}
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("CallTwalk(0,1,[\"hi\", \"there\"]): want os.ErrNotExist, got %v", err)
+	}
 	if len(w) > 0 {
 		t.Fatalf("CallTwalk(0,1,[\"hi\", \"there\"]): want 0 QIDs, got %v", w)
 	}
@@ -127,9 +136,14 @@ func TestMount(t *testing.T) {
 	if err == nil {
 		t.Fatalf("CallTopen(22, protocol.OREAD): want err, got nil")
 	}
-	of, _, err = c.CallTopen(1, protocol.OWRITE)
-	if err == nil {
-		t.Fatalf("CallTopen(0, protocol.OWRITE): want err, got nil")
+	if !errors.Is(err, protocol.ErrUnknownFID) {
+		t.Fatalf("CallTopen(22, protocol.OREAD): want protocol.ErrUnknownFID, got %v", err)
+	}
+	// The file is read only, but not to root.
+	if os.Geteuid() != 0 {
+		if _, _, err = c.CallTopen(1, protocol.OWRITE); err == nil {
+			t.Fatalf("CallTopen(0, protocol.OWRITE): want err, got nil")
+		}
 	}
 	of, _, err = c.CallTopen(1, protocol.OREAD)
 	if err != nil {
@@ -315,3 +329,423 @@ func TestMount(t *testing.T) {
 		t.Fatalf("After remove(%v); stat returns nil, not err", yyy)
 	}
 }
+
+// nochange returns a Dir that changes nothing in a Twstat.
+func nochange() protocol.Dir {
+	return protocol.Dir{
+		Type:   ^uint16(0),
+		Dev:    ^uint32(0),
+		QID:    protocol.QID{Type: ^uint8(0), Version: ^uint32(0), Path: ^uint64(0)},
+		Mode:   ^uint32(0),
+		Atime:  ^uint32(0),
+		Mtime:  ^uint32(0),
+		Length: ^uint64(0),
+	}
+}
+
+func TestAudit(t *testing.T) {
+	tmpdir, err := ioutil.TempDir(os.TempDir(), "audit.dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpdir)
+	logdir, err := ioutil.TempDir(os.TempDir(), "audit.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(logdir)
+	logfile := path.Join(logdir, "audit")
+	a, err := NewAuditLog(logfile, 64<<20, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+
+	if err := ioutil.WriteFile(path.Join(tmpdir, "taken"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	n, err := Newfilesystem(Root(tmpdir), Audit(a))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, p2 := net.Pipe()
+	if err := n.Accept(p2); err != nil {
+		t.Fatalf("Accept: want nil, got %v", err)
+	}
+	c, err := protocol.NewClient(func(c *protocol.Client) error {
+		c.FromNet, c.ToNet = p, p
+		c.Trace = func(string, ...interface{}) {}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := c.CallTversion(8000, "9P2000"); err != nil {
+		t.Fatalf("CallTversion: want nil, got %v", err)
+	}
+	if _, err := c.CallTattach(0, protocol.NOFID, "glenda", ""); err != nil {
+		t.Fatalf("CallTattach: want nil, got %v", err)
+	}
+	walk := func(fid protocol.FID, names ...string) {
+		if _, err := c.CallTwalk(0, fid, names); err != nil {
+			t.Fatalf("CallTwalk(0, %d, %v): want nil, got %v", fid, names, err)
+		}
+	}
+	wstat := func(fid protocol.FID, d protocol.Dir) {
+		var b bytes.Buffer
+		protocol.Marshaldir(&b, d)
+		if err := c.CallTwstat(fid, b.Bytes()); err != nil {
+			t.Fatalf("CallTwstat(%d, %v): want nil, got %v", fid, d, err)
+		}
+	}
+
+	walk(1)
+	if _, _, err := c.CallTcreate(1, "new", 0644, protocol.ORDWR); err != nil {
+		t.Fatalf("CallTcreate: want nil, got %v", err)
+	}
+	for _, s := range []string{"hello, ", "world\n"} {
+		if _, err := c.CallTwrite(1, 0, []byte(s)); err != nil {
+			t.Fatalf("CallTwrite: want nil, got %v", err)
+		}
+	}
+	if err := c.CallTclunk(1); err != nil {
+		t.Fatalf("CallTclunk: want nil, got %v", err)
+	}
+	walk(2)
+	if _, _, err := c.CallTcreate(2, "no/such", 0644, protocol.ORDWR); err == nil {
+		t.Fatalf("CallTcreate(no/such): want err, got nil")
+	}
+	walk(3, "new")
+	d := nochange()
+	d.Mode = 0600
+	wstat(3, d)
+	d = nochange()
+	d.Name = "renamed"
+	wstat(3, d)
+	// Refused renames are recorded too.
+	for _, fid := range []protocol.FID{3, 0} {
+		var b bytes.Buffer
+		d = nochange()
+		d.Name = "taken"
+		protocol.Marshaldir(&b, d)
+		if err := c.CallTwstat(fid, b.Bytes()); err == nil {
+			t.Fatalf("CallTwstat(%d, %v): want err, got nil", fid, d)
+		}
+	}
+	d = nochange()
+	d.Length = 1
+	wstat(3, d)
+	walk(5, "renamed")
+	if _, _, err := c.CallTopen(5, protocol.OWRITE|protocol.OTRUNC); err != nil {
+		t.Fatalf("CallTopen(OTRUNC): want nil, got %v", err)
+	}
+	if err := c.CallTclunk(5); err != nil {
+		t.Fatalf("CallTclunk: want nil, got %v", err)
+	}
+	if err := c.CallTremove(3); err != nil {
+		t.Fatalf("CallTremove: want nil, got %v", err)
+	}
+	// Writes to a fid that is never clunked are recorded when the
+	// connection goes.
+	walk(4)
+	if _, _, err := c.CallTcreate(4, "left", 0600, protocol.OWRITE); err != nil {
+		t.Fatalf("CallTcreate: want nil, got %v", err)
+	}
+	if _, err := c.CallTwrite(4, 0, []byte("x")); err != nil {
+		t.Fatalf("CallTwrite: want nil, got %v", err)
+	}
+	p.Close()
+
+	var recs []AuditRecord
+	for i := 0; len(recs) < 12; i++ {
+		if i == 100 {
+			t.Fatalf("got %d audit records, want 12: %+v", len(recs), recs)
+		}
+		time.Sleep(10 * time.Millisecond)
+		b, err := ioutil.ReadFile(logfile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		recs = nil
+		for _, l := range strings.Split(strings.TrimSpace(string(b)), "\n") {
+			var r AuditRecord
+			if l != "" && json.Unmarshal([]byte(l), &r) == nil {
+				recs = append(recs, r)
+			}
+		}
+	}
+
+	nw, rn := path.Join(tmpdir, "new"), path.Join(tmpdir, "renamed")
+	zero, one := int64(0), int64(1)
+	want := []AuditRecord{
+		{Op: "create", Path: nw, Mode: "0644"},
+		{Op: "write", Path: nw, Writes: 2, Bytes: 13},
+		{Op: "create", Path: path.Join(tmpdir, "no/such"), Mode: "0644", Err: "x"},
+		{Op: "chmod", Path: nw, Mode: "0600"},
+		{Op: "rename", Path: nw, NewPath: rn},
+		{Op: "rename", Path: rn, NewPath: path.Join(tmpdir, "taken"), Err: "x"},
+		{Op: "rename", Path: tmpdir, NewPath: path.Join(path.Dir(tmpdir), "taken"), Err: "x"},
+		{Op: "truncate", Path: rn, Length: &one},
+		{Op: "truncate", Path: rn, Length: &zero},
+		{Op: "remove", Path: rn},
+		{Op: "create", Path: path.Join(tmpdir, "left"), Mode: "0600"},
+		{Op: "write", Path: path.Join(tmpdir, "left"), Writes: 1, Bytes: 1},
+	}
+	for i, r := range recs {
+		w := want[i]
+		if r.Time.IsZero() || r.User != "glenda" || r.Remote != "pipe" || (r.Err != "") != (w.Err != "") {
+			t.Errorf("record %d: got %+v, want one at a time, by glenda from pipe, failed %v", i, r, w.Err != "")
+		}
+		r.Time, r.User, r.Remote, r.Err, w.Err = time.Time{}, "", "", "", ""
+		if !reflect.DeepEqual(r, w) {
+			t.Errorf("record %d: got %+v, want %+v", i, r, w)
+		}
+	}
+	if _, err := os.Stat(rn); !os.IsNotExist(err) {
+		t.Errorf("%v: want it removed, got %v", rn, err)
+	}
+
+	// A full log is rotated, and an old one appended to.
+	l, err := NewAuditLog(logfile, 200, 1)
+	if err != nil {
+		t.Fatalf("NewAuditLog: want nil, got %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := l.Record(&AuditRecord{Op: "remove", Path: fmt.Sprintf("/%d", i)}); err != nil {
+			t.Fatalf("Record: want nil, got %v", err)
+		}
+	}
+	if err := l.Close(); err != nil {
+		t.Fatalf("Close: want nil, got %v", err)
+	}
+	if err := l.Record(&AuditRecord{}); err == nil {
+		t.Errorf("Record after Close: want err, got nil")
+	}
+	for _, f := range []string{logfile, logfile + ".1"} {
+		if st, err := os.Stat(f); err != nil || st.Size() == 0 || st.Size() > 200 && f == logfile {
+			t.Errorf("%v: got %v, want some records and, for the current one, no more than 200 bytes", f, err)
+		}
+	}
+	if _, err := os.Stat(logfile + ".2"); err == nil {
+		t.Errorf("%v.2: want only one old file kept", logfile)
+	}
+
+	// A log that cannot be rotated goes on appending, and rotates once it
+	// can.
+	if err := os.Remove(logfile + ".1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(path.Join(logfile+".1", "busy"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	l, err = NewAuditLog(logfile, 200, 1)
+	if err != nil {
+		t.Fatalf("NewAuditLog: want nil, got %v", err)
+	}
+	defer l.Close()
+	for i := 3; ; i++ {
+		st, err := os.Stat(logfile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if st.Size() > 200 {
+			t.Fatalf("%v: got %d bytes, want a failed rotation", logfile, st.Size())
+		}
+		err = l.Record(&AuditRecord{Op: "remove", Path: fmt.Sprintf("/%d", i)})
+		if st2, err := os.Stat(logfile); err != nil || st2.Size() <= st.Size() {
+			t.Fatalf("%v: got %v, want the record appended", logfile, err)
+		}
+		if err != nil {
+			break
+		}
+	}
+	if err := os.RemoveAll(logfile + ".1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Record(&AuditRecord{Op: "remove", Path: "/0"}); err != nil {
+		t.Errorf("Record: want nil, got %v", err)
+	}
+	if st, err := os.Stat(logfile + ".1"); err != nil || !st.Mode().IsRegular() {
+		t.Errorf("%v.1: got %v, want the old records", logfile, err)
+	}
+}
+
+func TestConformance(t *testing.T) {
+	tmpdir, err := ioutil.TempDir(os.TempDir(), "conformance.dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpdir)
+	o := &Options{Root: tmpdir, User: "harvey", IOunit: 8192}
+	// The suite is run with no FidTable, to check the FileServer's own
+	// handling of fids, and with the one its Listener always has.
+	protocoltest.RunConformance(t, o.NewServer)
+	t.Run("FidTable", func(t *testing.T) {
+		protocoltest.RunConformance(t, o.NewServer, protocoltest.FidTable)
+	})
+}
+
+func TestScripts(t *testing.T) {
+	tmpdir, err := ioutil.TempDir(os.TempDir(), "scripts.dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpdir)
+	o := &Options{Root: tmpdir, User: "harvey", IOunit: 8192}
+	protocoltest.RunScripts(t, o.NewServer, "testdata/*.txt")
+}
+
+func TestReadOnly(t *testing.T) {
+	tmpdir, err := ioutil.TempDir(os.TempDir(), "readonly.dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpdir)
+	if err := ioutil.WriteFile(path.Join(tmpdir, "f"), []byte("hi"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	o := DefaultOptions()
+	for _, opt := range []Option{Root(tmpdir), User("glenda"), ReadOnly()} {
+		if err := opt(&o); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c := protocoltest.Dial(t, o.NewServer)
+	defer c.Close()
+	c.Attach(0)
+	if d, err := c.Stat(0); err != nil || d.User != "glenda" || d.Group != "glenda" {
+		t.Errorf("Stat(root): want user and group glenda, got %v, %v", d, err)
+	}
+	readOnly := func(what string, err error) {
+		t.Helper()
+		if err == nil || !strings.Contains(err.Error(), "read only file system") {
+			t.Errorf("%s: want read only file system, got %v", what, err)
+		}
+	}
+
+	c.WalkAll(0, 1, "f")
+	for _, m := range []protocol.Mode{protocol.OWRITE, protocol.ORDWR, protocol.OREAD | protocol.OTRUNC, protocol.OREAD | protocol.ORCLOSE} {
+		_, err := c.Open(1, m)
+		readOnly(fmt.Sprintf("Open(%#x)", m), err)
+	}
+	if _, err := c.Open(1, protocol.OREAD); err != nil {
+		t.Fatalf("Open(OREAD): want nil, got %v", err)
+	}
+	if b, err := c.Read(1, 0, 10); err != nil || string(b) != "hi" {
+		t.Errorf("Read: want hi, got %q, %v", b, err)
+	}
+	// A wstat that changes nothing is a sync, which changes nothing.
+	if err := c.Wstat(1, protocoltest.NoChange()); err != nil {
+		t.Errorf("Wstat(no change): want nil, got %v", err)
+	}
+	c.WalkAll(0, 4, "f")
+	if _, err := c.Open(4, protocol.OEXEC); err != nil {
+		t.Errorf("Open(OEXEC): want nil, got %v", err)
+	}
+
+	c.WalkAll(0, 2)
+	_, err = c.Create(2, "new", 0644, protocol.OWRITE)
+	readOnly("Create", err)
+	c.WalkAll(0, 3, "f")
+	d := protocoltest.NoChange()
+	d.Mode = 0600
+	readOnly("Wstat", c.Wstat(3, d))
+	readOnly("Remove", c.Remove(3))
+
+	if _, err := os.Stat(path.Join(tmpdir, "f")); err != nil {
+		t.Errorf("f: want it there, got %v", err)
+	}
+	if _, err := os.Stat(path.Join(tmpdir, "new")); err == nil {
+		t.Errorf("new: want it not made, but it was")
+	}
+}
+
+// records is an AuditSink that keeps its records.
+type records struct {
+	mu sync.Mutex
+	r  []AuditRecord
+}
+
+func (s *records) Record(r *AuditRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.r = append(s.r, *r)
+	return nil
+}
+
+func TestVersionClunks(t *testing.T) {
+	tmpdir, err := ioutil.TempDir(os.TempDir(), "version.dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpdir)
+	recs := &records{}
+	o := DefaultOptions()
+	for _, opt := range []Option{Root(tmpdir), Audit(recs)} {
+		if err := opt(&o); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c := protocoltest.Dial(t, o.NewServer)
+	defer c.Close()
+	c.Attach(0)
+	c.WalkAll(0, 1)
+	if _, err := c.Create(1, "f", 0644, protocol.OWRITE); err != nil {
+		t.Fatalf("Create: want nil, got %v", err)
+	}
+	if _, err := c.Write(1, 0, []byte("hello")); err != nil {
+		t.Fatalf("Write: want nil, got %v", err)
+	}
+
+	// The Tversion ends fid 1, so the write is recorded, and the fid is
+	// free to be attached again.
+	c.RPC(protocol.AppendTversion(nil, protocol.NOTAG, protocoltest.Msize, "9P2000"))
+	recs.mu.Lock()
+	var writes []AuditRecord
+	for _, r := range recs.r {
+		if r.Op == "write" {
+			writes = append(writes, r)
+		}
+	}
+	recs.mu.Unlock()
+	if len(writes) != 1 || writes[0].Writes != 1 || writes[0].Bytes != 5 {
+		t.Errorf("after Tversion: got write records %+v, want one of 1 write of 5 bytes", writes)
+	}
+	c.Attach(1)
+	if d, err := c.Stat(1); err != nil || d.QID.Type&protocol.QTDIR == 0 {
+		t.Errorf("Stat(1) after attaching it again: got %v, %v, want the root", d, err)
+	}
+}
+
+func TestOptions(t *testing.T) {
+	for _, opt := range []Option{Root(""), User("")} {
+		if _, err := Newfilesystem(opt); err == nil {
+			t.Errorf("Newfilesystem: want err, got nil")
+		}
+	}
+	n, err := Newfilesystem(Root("."), User("glenda"), IOunit(0), Debug())
+	if err != nil {
+		t.Fatalf("Newfilesystem: want nil, got %v", err)
+	}
+	t.Logf("n is %v", n)
+
+	fs := flag.NewFlagSet("ufs", flag.ContinueOnError)
+	options := Flags(fs)
+	if err := fs.Parse([]string{"-root", "/tmp", "-user", "glenda", "-iounit", "0", "-readonly"}); err != nil {
+		t.Fatalf("Parse: want nil, got %v", err)
+	}
+	opts, err := options()
+	if err != nil {
+		t.Fatalf("options: want nil, got %v", err)
+	}
+	o := DefaultOptions()
+	for _, opt := range opts {
+		if err := opt(&o); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := Options{Root: "/tmp", User: "glenda", IOunit: 0, ReadOnly: true}
+	if !reflect.DeepEqual(o, want) {
+		t.Errorf("Flags: got %+v, want %+v", o, want)
+	}
+}
@@ -0,0 +1,709 @@
+// Copyright 2019 The Ninep Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package filesystem
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"testing"
+
+	"sevki.org/q9p/protocol"
+	"sevki.org/q9p/protocol/protocoltest"
+)
+
+// TestModel runs random sequences of calls against a FileServer and
+// against memfs, a model of what it should do, and fails on the first
+// call they answer differently. The sequence is shrunk first, to the
+// fewest calls that still go wrong, so that is what is reported.
+func TestModel(t *testing.T) {
+	runs, calls := 300, 40
+	if testing.Short() {
+		runs = 50
+	}
+	umask := umask(t)
+	for seed := int64(1); seed <= int64(runs); seed++ {
+		ops := genOps(t, rand.New(rand.NewSource(seed)), calls, umask)
+		fails := func(ops []op) (int, string, string) { return runModel(t, umask, ops) }
+		if i, _, _ := fails(ops); i < 0 {
+			continue
+		}
+		ops = shrink(ops, fails)
+		i, got, want := fails(ops)
+		var b strings.Builder
+		for _, o := range ops[:i+1] {
+			fmt.Fprintf(&b, "\t%v\n", o)
+		}
+		t.Fatalf("seed %d, shrunk to %d calls:\n%sFileServer answers the last with\n\t%s\nbut the model with\n\t%s", seed, i+1, b.String(), got, want)
+	}
+}
+
+// umask finds the umask the servers create files under.
+func umask(t *testing.T) uint32 {
+	f, err := ioutil.TempFile("", "umask")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.Close()
+	if err := os.Remove(f.Name()); err != nil {
+		t.Fatal(err)
+	}
+	f, err = os.OpenFile(f.Name(), os.O_CREATE|os.O_EXCL|os.O_RDONLY, 0777)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return 0777 &^ uint32(st.Mode().Perm())
+}
+
+// runModel runs ops against a FileServer in a new directory and against
+// a new memfs. It returns the index of the first op they answer
+// differently, and the two answers; -1 if there is none.
+func runModel(t *testing.T, umask uint32, ops []op) (int, string, string) {
+	dir, err := ioutil.TempDir("", "model")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	st, err := os.Stat(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := &Options{Root: dir, User: modelUser, IOunit: 8192}
+	// Both have a FidTable, so that they answer bad fids alike, and
+	// differ only in what they do with the tree.
+	fs := &runner{c: protocoltest.Dial(t, o.NewServer, protocoltest.FidTable), dirs: make(map[protocol.FID]bool)}
+	defer fs.c.Close()
+	model := &runner{c: protocoltest.Dial(t, func() protocol.NineServer {
+		return newMemfs(filepath.Base(dir), uint32(st.Mode().Perm()), umask)
+	}, protocoltest.FidTable), dirs: make(map[protocol.FID]bool)}
+	defer model.c.Close()
+	fs.c.Attach(0)
+	model.c.Attach(0)
+	for i, o := range ops {
+		got, want := fs.run(o), model.run(o)
+		if got != want {
+			return i, got, want
+		}
+	}
+	return -1, "", ""
+}
+
+// shrink returns the shortest sequence it can find by dropping calls from
+// ops that still fails. fails returns the index of the call that goes
+// wrong, or -1.
+func shrink(ops []op, fails func([]op) (int, string, string)) []op {
+	i, _, _ := fails(ops)
+	ops = ops[:i+1]
+	for i := 0; i < len(ops); {
+		try := append(append([]op(nil), ops[:i]...), ops[i+1:]...)
+		if j, _, _ := fails(try); j >= 0 {
+			ops = try[:j+1]
+			continue
+		}
+		i++
+	}
+	return ops
+}
+
+// An op is a call the test makes.
+type op struct {
+	call        string // walk, open, create, read, write, wstat, remove, clunk or stat
+	fid, newfid protocol.FID
+	names       []string // for walk; create has one
+	mode        protocol.Mode
+	perm        protocol.Perm
+	off         protocol.Offset
+	count       protocol.Count
+	data        string
+	dir         protocol.Dir // for wstat
+}
+
+func (o op) String() string {
+	switch o.call {
+	case "walk":
+		return fmt.Sprintf("walk %d %d %s", o.fid, o.newfid, strings.Join(o.names, " "))
+	case "open":
+		return fmt.Sprintf("open %d %s", o.fid, modeString(o.mode))
+	case "create":
+		return fmt.Sprintf("create %d %s %#o %s", o.fid, o.names[0], uint32(o.perm), modeString(o.mode))
+	case "read":
+		return fmt.Sprintf("read %d %d %d", o.fid, o.off, o.count)
+	case "write":
+		return fmt.Sprintf("write %d %d %q", o.fid, o.off, o.data)
+	case "wstat":
+		s := fmt.Sprintf("wstat %d", o.fid)
+		if o.dir.Name != "" {
+			s += " name " + o.dir.Name
+		}
+		if o.dir.Mode != ^uint32(0) {
+			s += fmt.Sprintf(" mode %#o", o.dir.Mode)
+		}
+		if o.dir.Length != ^uint64(0) {
+			s += fmt.Sprintf(" length %d", o.dir.Length)
+		}
+		return s
+	}
+	return fmt.Sprintf("%s %d", o.call, o.fid)
+}
+
+func modeString(m protocol.Mode) string {
+	s := [...]string{"OREAD", "OWRITE", "ORDWR", "OEXEC"}[m&3]
+	if m&protocol.OTRUNC != 0 {
+		s += "|OTRUNC"
+	}
+	return s
+}
+
+// genOps makes n random calls. Fid 0 is the root, attached already; the
+// calls that would change it leave it alone, so it can always be walked
+// from. The calls are run against a memfs as they are made, so each can
+// be on a fid that is likely to make it get somewhere; and the names are
+// few, so calls often meet the files others made.
+func genOps(t *testing.T, r *rand.Rand, n int, umask uint32) []op {
+	m := newMemfs("model", 0700, umask).(*memfs)
+	c := &runner{c: protocoltest.Dial(t, func() protocol.NineServer { return m }, protocoltest.FidTable), dirs: make(map[protocol.FID]bool)}
+	defer c.c.Close()
+	c.c.Attach(0)
+	const (
+		unset = iota
+		walked
+		open
+	)
+	state := func(fid protocol.FID) int {
+		f, ok := m.fids[fid]
+		switch {
+		case !ok:
+			return unset
+		case f.node == nil:
+			return walked
+		}
+		return open
+	}
+	// fid picks a fid, usually one in one of the states wanted.
+	fid := func(want ...int) protocol.FID {
+		var fids []protocol.FID
+		for f := protocol.FID(1); f <= 4; f++ {
+			for _, w := range want {
+				if state(f) == w {
+					fids = append(fids, f)
+				}
+			}
+		}
+		if len(fids) == 0 || r.Intn(10) == 0 {
+			return protocol.FID(1 + r.Intn(4))
+		}
+		return fids[r.Intn(len(fids))]
+	}
+	names := []string{"a", "b", "c", "a", "b", "c", ".."}
+	pick := func(s []string) string { return s[r.Intn(len(s))] }
+	var ops []op
+	for len(ops) < n {
+		var o op
+		k := r.Intn(17)
+		if k > 3 && len(m.fids) == 1 && r.Intn(10) != 0 {
+			k = 0 // there is nothing but the root to call on yet
+		}
+		switch k {
+		case 0, 1, 2, 3:
+			o = op{call: "walk", fid: 0, newfid: fid(unset)}
+			if f := fid(walked); r.Intn(2) == 0 && state(f) == walked {
+				o.fid = f
+			}
+			for i := []int{0, 0, 1, 1, 2}[r.Intn(5)]; i > 0; i-- {
+				o.names = append(o.names, pick(names))
+			}
+		case 4, 5:
+			modes := []protocol.Mode{protocol.OREAD, protocol.OWRITE, protocol.ORDWR,
+				protocol.OWRITE | protocol.OTRUNC, protocol.ORDWR | protocol.OTRUNC}
+			o = op{call: "open", fid: fid(walked), mode: modes[r.Intn(len(modes))]}
+		case 6, 7, 8:
+			o = op{call: "create", fid: fid(walked), names: []string{pick(names[:len(names)-1])}}
+			if r.Intn(10) == 0 {
+				o.names[0] = ".."
+			}
+			switch r.Intn(4) {
+			case 0:
+				o.perm = 0644
+			case 1:
+				o.perm = 0600
+			case 2:
+				o.perm = protocol.Perm(protocol.DMDIR | 0755)
+			case 3:
+				o.perm = protocol.Perm(protocol.DMDIR | 0700)
+			}
+			if o.perm&protocol.Perm(protocol.DMDIR) == 0 {
+				o.mode = protocol.Mode(r.Intn(3))
+			}
+		case 9, 10:
+			counts := []protocol.Count{0, 4, 16, 70, 150, 8192}
+			o = op{call: "read", fid: fid(open), off: protocol.Offset(r.Intn(8)), count: counts[r.Intn(len(counts))]}
+			if r.Intn(4) == 0 {
+				o.fid = 0
+			}
+		case 11, 12:
+			o = op{call: "write", fid: fid(open), off: protocol.Offset(r.Intn(8)), data: "abcdef"[:1+r.Intn(6)]}
+		case 13:
+			o = op{call: "wstat", fid: fid(walked, open), dir: protocoltest.NoChange()}
+			switch r.Intn(4) {
+			case 0:
+				o.dir.Name = pick(names[:3])
+			case 1:
+				o.dir.Mode = []uint32{0700, 0755, 0777}[r.Intn(3)]
+			case 2:
+				o.dir.Length = uint64(r.Intn(10))
+			case 3:
+				o.dir.Name, o.dir.Length = pick(names[:3]), uint64(r.Intn(10))
+			}
+		case 14:
+			o = op{call: "remove", fid: fid(walked, open)}
+		case 15:
+			o = op{call: "clunk", fid: fid(walked, open)}
+		case 16:
+			o = op{call: "stat", fid: fid(walked, open)}
+			if r.Intn(4) == 0 {
+				o.fid = 0
+			}
+		}
+		c.run(o)
+		ops = append(ops, o)
+	}
+	return ops
+}
+
+// A runner makes calls on a connection. It keeps track of which fids are
+// open directories, so it can read them whole: the order of the entries
+// is the server's business, so it is only the whole that is compared.
+type runner struct {
+	c    *protocoltest.Conn
+	dirs map[protocol.FID]bool
+}
+
+// run makes the call o, and returns what it got as a string to compare.
+func (r *runner) run(o op) string {
+	switch o.call {
+	case "walk":
+		q, err := r.c.Walk(o.fid, o.newfid, o.names...)
+		if err == nil && len(q) == len(o.names) {
+			delete(r.dirs, o.newfid)
+		}
+		return answer(err, qidTypes(q...))
+	case "open":
+		q, err := r.c.Open(o.fid, o.mode)
+		if err == nil {
+			r.dirs[o.fid] = q.Type&protocol.QTDIR != 0
+		}
+		return answer(err, qidTypes(q))
+	case "create":
+		q, err := r.c.Create(o.fid, o.names[0], o.perm, o.mode)
+		if err == nil {
+			r.dirs[o.fid] = q.Type&protocol.QTDIR != 0
+		}
+		return answer(err, qidTypes(q))
+	case "read":
+		if r.dirs[o.fid] {
+			return r.readDir(o.fid, o.count)
+		}
+		b, err := r.c.Read(o.fid, o.off, o.count)
+		return answer(err, fmt.Sprintf("%q", b))
+	case "write":
+		n, err := r.c.Write(o.fid, o.off, []byte(o.data))
+		return answer(err, fmt.Sprint(n))
+	case "wstat":
+		return answer(r.c.Wstat(o.fid, o.dir), "")
+	case "remove":
+		delete(r.dirs, o.fid)
+		return answer(r.c.Remove(o.fid), "")
+	case "clunk":
+		delete(r.dirs, o.fid)
+		return answer(r.c.Clunk(o.fid), "")
+	case "stat":
+		d, err := r.c.Stat(o.fid)
+		return answer(err, dirString(d))
+	}
+	panic("unknown call " + o.call)
+}
+
+// readDir reads the directory fid from the start, count bytes at a time,
+// and returns its entries, sorted.
+func (r *runner) readDir(fid protocol.FID, count protocol.Count) string {
+	var ents []string
+	var off protocol.Offset
+	for i := 0; i < 100; i++ {
+		b, err := r.c.Read(fid, off, count)
+		if err != nil {
+			return answer(err, "")
+		}
+		if len(b) == 0 {
+			sort.Strings(ents)
+			return answer(nil, strings.Join(ents, ", "))
+		}
+		off += protocol.Offset(len(b))
+		for bb := bytes.NewBuffer(b); bb.Len() > 0; {
+			d, err := protocol.Unmarshaldir(bb)
+			if err != nil {
+				return fmt.Sprintf("bad entries %x: %v", b, err)
+			}
+			ents = append(ents, dirString(d))
+		}
+	}
+	return "no end to the directory"
+}
+
+func answer(err error, s string) string {
+	if err != nil {
+		return "error: " + err.Error()
+	}
+	return "ok " + s
+}
+
+func qidTypes(q ...protocol.QID) string {
+	var s []string
+	for _, q := range q {
+		if q.Type&protocol.QTDIR != 0 {
+			s = append(s, "d")
+		} else {
+			s = append(s, "-")
+		}
+	}
+	return strings.Join(s, " ")
+}
+
+// dirString is the parts of d the servers should agree on.
+func dirString(d protocol.Dir) string {
+	return fmt.Sprintf("%s %#o %d %s", d.Name, d.Mode, d.Length, qidTypes(d.QID))
+}
+
+// memfs is the model FileServer is checked against: a tree in memory.
+// Like FileServer's, its fids name paths, which are looked up again for
+// each call, so a fid sees the renames and removes made through others,
+// and may find its file gone; but an open fid holds its file, as an open
+// os.File does. It answers with the errors the OS gives FileServer.
+// modelUser owns the files, in memfs and the FileServers it is checked
+// against.
+const modelUser = "glenda"
+
+type memfs struct {
+	root  *memNode
+	umask uint32
+	fids  map[protocol.FID]*memFid
+	path  uint64 // the last QID path given out
+}
+
+type memNode struct {
+	name string
+	mode uint32 // permissions, and DMDIR for a directory
+	data []byte
+	kids map[string]*memNode // nil for a file
+	path uint64
+}
+
+type memFid struct {
+	name string          // the path from the root, e.g. /a/b
+	node *memNode        // the file, once open
+	ents [][]byte        // a directory's entries not yet read
+	off  protocol.Offset // and where the next read of them is
+}
+
+func newMemfs(name string, perm, umask uint32) protocol.NineServer {
+	m := &memfs{umask: umask, fids: make(map[protocol.FID]*memFid)}
+	m.root = m.node(name, protocol.DMDIR|perm)
+	return m
+}
+
+func (m *memfs) node(name string, mode uint32) *memNode {
+	m.path++
+	n := &memNode{name: name, mode: mode, path: m.path}
+	if mode&protocol.DMDIR != 0 {
+		n.kids = make(map[string]*memNode)
+	}
+	return n
+}
+
+// lookup finds the file at name, as Lstat would.
+func (m *memfs) lookup(name string) (*memNode, error) {
+	n := m.root
+	for _, e := range strings.Split(name, "/")[1:] {
+		if e == "" {
+			continue
+		}
+		if n.kids == nil {
+			return nil, &os.PathError{Op: "lstat", Path: name, Err: syscall.ENOTDIR}
+		}
+		if n = n.kids[e]; n == nil {
+			return nil, &os.PathError{Op: "lstat", Path: name, Err: os.ErrNotExist}
+		}
+	}
+	return n, nil
+}
+
+func (m *memfs) fid(fid protocol.FID) (*memFid, error) {
+	f, ok := m.fids[fid]
+	if !ok {
+		return nil, protocol.ErrUnknownFID
+	}
+	return f, nil
+}
+
+func (n *memNode) qid() protocol.QID {
+	q := protocol.QID{Path: n.path}
+	if n.kids != nil {
+		q.Type = protocol.QTDIR
+	}
+	return q
+}
+
+func (n *memNode) dir() protocol.Dir {
+	return protocol.Dir{QID: n.qid(), Mode: n.mode, Length: uint64(len(n.data)), Name: n.name, User: modelUser, Group: modelUser}
+}
+
+func (m *memfs) Rversion(msize protocol.MaxSize, version string) (protocol.MaxSize, string, error) {
+	if version != "9P2000" {
+		return 0, "", fmt.Errorf("%v not supported; only 9P2000", version)
+	}
+	return msize, version, nil
+}
+
+func (m *memfs) Rattach(fid, afid protocol.FID, uname, aname string) (protocol.QID, error) {
+	m.fids[fid] = &memFid{name: "/"}
+	return m.root.qid(), nil
+}
+
+func (m *memfs) Rflush(o protocol.Tag) error {
+	return nil
+}
+
+func (m *memfs) Rwalk(fid, newfid protocol.FID, names []string) ([]protocol.QID, error) {
+	f, err := m.fid(fid)
+	if err != nil {
+		return nil, err
+	}
+	name := f.name
+	q := []protocol.QID{}
+	for i, e := range names {
+		if i > 0 && q[i-1].Type&protocol.QTDIR == 0 {
+			break
+		}
+		name = path.Join(name, e)
+		n, err := m.lookup(name)
+		if err != nil && i == 0 {
+			return nil, err
+		}
+		if err != nil {
+			break
+		}
+		q = append(q, n.qid())
+	}
+	if len(q) == len(names) {
+		m.fids[newfid] = &memFid{name: name}
+	}
+	return q, nil
+}
+
+func (m *memfs) Ropen(fid protocol.FID, mode protocol.Mode) (protocol.QID, protocol.MaxSize, error) {
+	f, err := m.fid(fid)
+	if err != nil {
+		return protocol.QID{}, 0, err
+	}
+	n, err := m.lookup(f.name)
+	if err != nil {
+		return protocol.QID{}, 0, err
+	}
+	if n.kids != nil && (mode&3 == protocol.OWRITE || mode&3 == protocol.ORDWR) {
+		return protocol.QID{}, 0, &os.PathError{Op: "open", Path: f.name, Err: syscall.EISDIR}
+	}
+	if mode&protocol.OTRUNC != 0 {
+		n.data = nil
+	}
+	f.node = n
+	return n.qid(), 8192, nil
+}
+
+func (m *memfs) Rcreate(fid protocol.FID, name string, perm protocol.Perm, mode protocol.Mode) (protocol.QID, protocol.MaxSize, error) {
+	f, err := m.fid(fid)
+	if err != nil {
+		return protocol.QID{}, 0, err
+	}
+	full := path.Join(f.name, name)
+	if full == f.name || path.Dir(full) != f.name {
+		return protocol.QID{}, 0, &os.PathError{Op: "create", Path: full, Err: os.ErrInvalid}
+	}
+	d, err := m.lookup(f.name)
+	if err != nil {
+		return protocol.QID{}, 0, err
+	}
+	if d.kids == nil {
+		return protocol.QID{}, 0, &os.PathError{Op: "create", Path: full, Err: syscall.ENOTDIR}
+	}
+	if d.kids[name] != nil {
+		return protocol.QID{}, 0, &os.PathError{Op: "create", Path: full, Err: os.ErrExist}
+	}
+	n := m.node(name, uint32(perm)&(protocol.DMDIR|0777&^m.umask))
+	d.kids[name] = n
+	f.name, f.node = full, n
+	return n.qid(), 8000, nil
+}
+
+func (m *memfs) Rread(fid protocol.FID, o protocol.Offset, c protocol.Count) ([]byte, error) {
+	f, err := m.fid(fid)
+	if err != nil {
+		return nil, err
+	}
+	n := f.node
+	if n.kids == nil {
+		if o >= protocol.Offset(len(n.data)) {
+			return []byte{}, nil
+		}
+		b := n.data[o:]
+		if len(b) > int(c) {
+			b = b[:c]
+		}
+		return b, nil
+	}
+	if o == 0 {
+		f.ents = nil
+		for _, k := range n.kids {
+			var b bytes.Buffer
+			protocol.Marshaldir(&b, k.dir())
+			f.ents = append(f.ents, b.Bytes())
+		}
+		f.off = 0
+	}
+	if o != f.off {
+		return nil, errDirOffset
+	}
+	var b []byte
+	for len(f.ents) > 0 && len(b)+len(f.ents[0]) <= int(c) {
+		b, f.ents = append(b, f.ents[0]...), f.ents[1:]
+	}
+	if len(b) == 0 && len(f.ents) > 0 {
+		return nil, errShortDirRead
+	}
+	f.off += protocol.Offset(len(b))
+	return b, nil
+}
+
+func (m *memfs) Rwrite(fid protocol.FID, o protocol.Offset, b []byte) (protocol.Count, error) {
+	f, err := m.fid(fid)
+	if err != nil {
+		return -1, err
+	}
+	n := f.node
+	if end := int(o) + len(b); end > len(n.data) {
+		n.data = append(n.data, make([]byte, end-len(n.data))...)
+	}
+	copy(n.data[o:], b)
+	return protocol.Count(len(b)), nil
+}
+
+func (m *memfs) Rclunk(fid protocol.FID) error {
+	delete(m.fids, fid)
+	return nil
+}
+
+func (m *memfs) Rremove(fid protocol.FID) error {
+	f, err := m.fid(fid)
+	if err != nil {
+		return err
+	}
+	delete(m.fids, fid)
+	if f.name == "/" {
+		return &os.PathError{Op: "remove", Path: f.name, Err: os.ErrPermission}
+	}
+	n, err := m.lookup(f.name)
+	if err != nil {
+		return err
+	}
+	if len(n.kids) > 0 {
+		return &os.PathError{Op: "remove", Path: f.name, Err: syscall.ENOTEMPTY}
+	}
+	d, _ := m.lookup(path.Dir(f.name))
+	delete(d.kids, n.name)
+	return nil
+}
+
+func (m *memfs) Rstat(fid protocol.FID) ([]byte, error) {
+	f, err := m.fid(fid)
+	if err != nil {
+		return nil, err
+	}
+	n, err := m.lookup(f.name)
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	protocol.Marshaldir(&b, n.dir())
+	return b.Bytes(), nil
+}
+
+// Rwstat makes the changes in the order FileServer does, so that one
+// that fails leaves those before it made.
+func (m *memfs) Rwstat(fid protocol.FID, b []byte) error {
+	f, err := m.fid(fid)
+	if err != nil {
+		return err
+	}
+	d, err := protocol.Unmarshaldir(bytes.NewBuffer(b))
+	if err != nil {
+		return err
+	}
+	if d.Mode != ^uint32(0) {
+		n, err := m.lookup(f.name)
+		if err != nil {
+			return err
+		}
+		n.mode = n.mode&protocol.DMDIR | d.Mode&0777
+	}
+	if d.User != "" || d.Group != "" {
+		return os.ErrPermission
+	}
+	if d.Name != "" {
+		if f.name == "/" {
+			return &os.PathError{Op: "rename", Path: f.name, Err: os.ErrPermission}
+		}
+		newname := path.Join(path.Dir(f.name), path.Join("/", d.Name))
+		if newname != f.name {
+			if _, err := m.lookup(newname); err == nil {
+				return &os.PathError{Op: "rename", Path: newname, Err: os.ErrExist}
+			}
+			n, err := m.lookup(f.name)
+			if err != nil {
+				return err
+			}
+			p, _ := m.lookup(path.Dir(f.name))
+			delete(p.kids, n.name)
+			n.name = path.Base(newname)
+			p.kids[n.name] = n
+		}
+		f.name = newname
+	}
+	if d.Length != ^uint64(0) {
+		n, err := m.lookup(f.name)
+		if err != nil {
+			return err
+		}
+		if n.kids != nil {
+			return &os.PathError{Op: "truncate", Path: f.name, Err: syscall.EISDIR}
+		}
+		if int(d.Length) < len(n.data) {
+			n.data = n.data[:d.Length]
+		} else {
+			n.data = append(n.data, make([]byte, int(d.Length)-len(n.data))...)
+		}
+	}
+	return nil
+}
@@ -7,16 +7,11 @@
 package filesystem
 
 import (
-	"flag"
 	"os"
 
 	"sevki.org/q9p/protocol"
 )
 
-var (
-	user = flag.String("user", "harvey", "Default user name")
-)
-
 func modeToUnixFlags(mode protocol.Mode) int {
 	ret := int(0)
 	switch mode & 3 {
@@ -65,17 +60,20 @@ func dirTo9p2000Mode(d os.FileInfo) uint32 {
 	return ret
 }
 
-func dirTo9p2000Dir(fi os.FileInfo) (*protocol.Dir, error) {
+func dirTo9p2000Dir(fi os.FileInfo, user string) (*protocol.Dir, error) {
 	d := &protocol.Dir{}
 	d.QID = fileInfoToQID(fi)
 	d.Mode = dirTo9p2000Mode(fi)
 	// TODO: use info on systems that have it.
 	d.Atime = uint32(fi.ModTime().Unix()) // uint32(atime(sysMode).Unix())
 	d.Mtime = uint32(fi.ModTime().Unix())
-	d.Length = uint64(fi.Size())
+	// A directory's length is 0; its entries are not bytes of it.
+	if !fi.IsDir() {
+		d.Length = uint64(fi.Size())
+	}
 	d.Name = fi.Name()
-	d.User = *user
-	d.Group = *user
+	d.User = user
+	d.Group = user
 
 	return d, nil
 }
@@ -0,0 +1,189 @@
+// Copyright 2019 The Ninep Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package filesystem
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+
+	"sevki.org/q9p/protocol"
+)
+
+// Options say what a file server exports and how. Newfilesystem starts
+// from DefaultOptions and changes them as its Options say.
+type Options struct {
+	// Root is the directory attaches are made in: an aname is a path
+	// in it, and nothing outside it can be reached.
+	Root string
+
+	// User is the user and group all the files are said to belong to.
+	User string
+
+	// IOunit is what Ropen and Rcreate tell the client is the most it
+	// can read or write at once.
+	IOunit protocol.MaxSize
+
+	// ReadOnly, if set, makes all calls that would change the files
+	// fail with a read only file system error.
+	ReadOnly bool
+
+	// Debug, if set, logs each call and what it returns.
+	Debug bool
+
+	// Audit, if not nil, is told of each change made to the files.
+	Audit AuditSink
+
+	// Listener is what Newfilesystem makes its Listener with, after the
+	// FidTable it always has.
+	Listener []protocol.ListenerOpt
+}
+
+// DefaultOptions returns the Options a file server has unless it is told
+// otherwise: all of / exported, owned by harvey.
+func DefaultOptions() Options {
+	return Options{Root: "/", User: "harvey", IOunit: 8192}
+}
+
+// An Option changes the Options, or returns an error if it cannot.
+type Option func(*Options) error
+
+// Root exports dir rather than /.
+func Root(dir string) Option {
+	return func(o *Options) error {
+		if dir == "" {
+			return fmt.Errorf("Root: empty directory")
+		}
+		d, err := filepath.Abs(dir)
+		if err != nil {
+			return err
+		}
+		o.Root = d
+		return nil
+	}
+}
+
+// User makes name the owner of all the files, rather than harvey.
+func User(name string) Option {
+	return func(o *Options) error {
+		if name == "" {
+			return fmt.Errorf("User: empty name")
+		}
+		o.User = name
+		return nil
+	}
+}
+
+// IOunit sets the IOunit; zero tells clients the message size is the
+// limit.
+func IOunit(n protocol.MaxSize) Option {
+	return func(o *Options) error {
+		o.IOunit = n
+		return nil
+	}
+}
+
+// ReadOnly refuses all changes to the files.
+func ReadOnly() Option {
+	return func(o *Options) error {
+		o.ReadOnly = true
+		return nil
+	}
+}
+
+// Debug logs each call and what it returns.
+func Debug() Option {
+	return func(o *Options) error {
+		o.Debug = true
+		return nil
+	}
+}
+
+// Audit tells s of each change made to the files.
+func Audit(s AuditSink) Option {
+	return func(o *Options) error {
+		o.Audit = s
+		return nil
+	}
+}
+
+// Metrics counts the Listener's messages in m.
+func Metrics(m *protocol.Metrics) Option {
+	return ListenerOpts(func(l *protocol.Listener) error {
+		l.Metrics = m
+		return nil
+	})
+}
+
+// ListenerOpts makes the Listener with opts, too.
+func ListenerOpts(opts ...protocol.ListenerOpt) Option {
+	return func(o *Options) error {
+		o.Listener = append(o.Listener, opts...)
+		return nil
+	}
+}
+
+// NewServer returns a FileServer for one connection, as o says; it is
+// what a Listener for o is made with. The Options must not be changed
+// once it has been called.
+func (o *Options) NewServer() protocol.NineServer {
+	f := &FileServer{
+		files:    make(map[protocol.FID]*file),
+		rootPath: o.Root,
+		user:     o.User,
+		readOnly: o.ReadOnly,
+		IOunit:   o.IOunit,
+		Audit:    o.Audit,
+	}
+	if o.Debug {
+		return debugLog(f)
+	}
+	return f
+}
+
+// Flags defines in fs the flags a file server command has for its
+// Options, and returns a function that, once fs has been parsed, returns
+// the Options they ask for.
+func Flags(fs *flag.FlagSet) func() ([]Option, error) {
+	root := fs.String("root", "/", "Set the root for all attaches")
+	user := fs.String("user", "harvey", "Default user name")
+	iounit := fs.Uint("iounit", 8192, "Most a client may read or write at once; 0 for as much as fits in a message")
+	readOnly := fs.Bool("readonly", false, "Refuse all changes to the files")
+	debug := fs.Int("debug", 0, "print debug messages")
+	audit := fs.String("audit", "", "File to append an audit record of each change to, as JSON lines; none if empty")
+	spans := fs.String("spans", "", "File to write a span for each message to, as Chrome trace event JSON; none if empty")
+
+	return func() ([]Option, error) {
+		opts := []Option{
+			Root(*root),
+			User(*user),
+			IOunit(protocol.MaxSize(*iounit)),
+		}
+		if *readOnly {
+			opts = append(opts, ReadOnly())
+		}
+		if *debug != 0 {
+			opts = append(opts, Debug())
+		}
+		if *audit != "" {
+			a, err := NewAuditLog(*audit, 64<<20, 10)
+			if err != nil {
+				return nil, err
+			}
+			opts = append(opts, Audit(a))
+		}
+		if *spans != "" {
+			s, err := protocol.NewSpanLog(*spans, 64<<20, 3)
+			if err != nil {
+				return nil, err
+			}
+			opts = append(opts, ListenerOpts(func(l *protocol.Listener) error {
+				l.Spans = s
+				return nil
+			}))
+		}
+		return opts, nil
+	}
+}
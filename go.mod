@@ -1,5 +1,11 @@
 module sevki.org/q9p
 
-go 1.12
+go 1.26.0
 
-require github.com/lucas-clemente/quic-go v0.11.1
+require github.com/quic-go/quic-go v0.63.0
+
+require (
+	golang.org/x/crypto v0.54.0 // indirect
+	golang.org/x/net v0.56.0 // indirect
+	golang.org/x/sys v0.47.0 // indirect
+)
@@ -0,0 +1,106 @@
+// Copyright 2019 The Ninep Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protocol
+
+import (
+	"errors"
+	"strings"
+)
+
+// The errors BaseServer answers with. The strings are lib9p's.
+var (
+	ErrNoWalk   = errors.New("file does not exist")
+	ErrNoOpen   = errors.New("permission denied")
+	ErrNoCreate = errors.New("create prohibited")
+	ErrNoRead   = errors.New("read prohibited")
+	ErrNoWrite  = errors.New("write prohibited")
+	ErrNoRemove = errors.New("remove prohibited")
+	ErrNoStat   = errors.New("stat prohibited")
+	ErrNoWstat  = errors.New("wstat prohibited")
+)
+
+// BaseServer is a NineServer that does as little as it can. It speaks
+// 9P2000, attaches to an empty directory, lets fids be cloned, flushed and
+// clunked, and answers everything else with Rerror. Embed it in a server
+// and define just the methods the server needs, i.e. implement Walker,
+// Opener, Reader and the rest of the optional interfaces.
+//
+// Most real servers will want at least Rwalk, Ropen, Rread and Rstat; one
+// that also needs its own Rattach can define that too.
+type BaseServer struct{}
+
+// Rversion agrees to 9P2000, and to nothing else.
+func (BaseServer) Rversion(msize MaxSize, version string) (MaxSize, string, error) {
+	if msize > MSIZE {
+		msize = MSIZE
+	}
+	if !strings.HasPrefix(version, "9P2000") {
+		return msize, "unknown", nil
+	}
+	return msize, "9P2000", nil
+}
+
+// Rattach attaches to a directory.
+func (BaseServer) Rattach(FID, FID, string, string) (QID, error) {
+	return QID{Type: QTDIR}, nil
+}
+
+// Rflush has nothing to flush.
+func (BaseServer) Rflush(Tag) error {
+	return nil
+}
+
+// Rwalk clones fids, which is a walk of no names, but walks no further.
+func (BaseServer) Rwalk(fid FID, newfid FID, paths []string) ([]QID, error) {
+	if len(paths) == 0 {
+		return nil, nil
+	}
+	return nil, ErrNoWalk
+}
+
+func (BaseServer) Ropen(FID, Mode) (QID, MaxSize, error) {
+	return QID{}, 0, ErrNoOpen
+}
+
+func (BaseServer) Rcreate(FID, string, Perm, Mode) (QID, MaxSize, error) {
+	return QID{}, 0, ErrNoCreate
+}
+
+func (BaseServer) Rread(FID, Offset, Count) ([]byte, error) {
+	return nil, ErrNoRead
+}
+
+func (BaseServer) Rwrite(FID, Offset, []byte) (Count, error) {
+	return 0, ErrNoWrite
+}
+
+// Rclunk forgets nothing, as it knows nothing.
+func (BaseServer) Rclunk(FID) error {
+	return nil
+}
+
+func (BaseServer) Rremove(FID) error {
+	return ErrNoRemove
+}
+
+func (BaseServer) Rstat(FID) ([]byte, error) {
+	return nil, ErrNoStat
+}
+
+func (BaseServer) Rwstat(FID, []byte) error {
+	return ErrNoWstat
+}
+
+// Adapt makes a NineServer of v, which may have any of the NineServer
+// methods, i.e. implement any of Versioner, Attacher, Walker and the rest.
+// Each message v has no method for gets BaseServer's answer. It is for a
+// server that would rather not embed BaseServer: its NsCreator returns
+// the server Adapted.
+func Adapt(v interface{}) NineServer {
+	if s, ok := v.(NineServer); ok {
+		return s
+	}
+	return &adapter{v: v}
+}
@@ -8,13 +8,20 @@ package protocol
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"runtime"
+	"sync"
 	"sync/atomic"
+	"time"
 )
 
+// ErrClientDead is the error for a call on a Client that has died,
+// whether it was waiting for its reply or came after.
+var ErrClientDead = errors.New("client is dead")
+
 // Client implements a 9p client. It has a chan containing all tags,
 // a scalar FID which is incremented to provide new FIDS (all FIDS for a given
 // client are unique), an array of MaxTag-2 RPC structs, a ReadWriteCloser
@@ -32,7 +39,34 @@ type Client struct {
 	FromServer chan *RPCReply
 	Msize      uint32
 	Dead       bool
-	Trace      Tracer
+	// Observer, if not nil, is told of each Event on the connection.
+	// Trace, if Observer is nil, gets each as a line.
+	Observer Observer
+	Trace    Tracer
+	// Extensions the client knows; extensions are those the server
+	// agreed to in Version.
+	Extensions *Registry
+	extensions map[MType]*Extension
+
+	// KeepAlive, if not zero, is how long the client may hear nothing
+	// from the server before it checks the server is still there. See
+	// keepAlive. Clock is the clock for it; nil means the real one.
+	KeepAlive time.Duration
+	Clock     Clock
+
+	// Metrics, if not nil, counts the client's messages.
+	Metrics *Metrics
+	meter   *meter
+
+	heardAt  int64 // when the last message came, in UnixNano; use atomic
+	done     chan struct{}
+	doneOnce sync.Once
+
+	// mu guards RPC, and err and failed: err is why the client died, and
+	// failed is set once the calls it left waiting have been failed.
+	mu     sync.Mutex
+	err    error
+	failed bool
 }
 
 func NewClient(opts ...ClientOpt) (*Client, error) {
@@ -51,8 +85,14 @@ func NewClient(opts ...ClientOpt) (*Client, error) {
 	}
 	c.FromClient = make(chan *RPCCall, NumTags)
 	c.FromServer = make(chan *RPCReply)
+	c.done = make(chan struct{})
+	c.hear()
+	c.meter = c.Metrics.meter(c.Clock)
 	go c.IO()
 	go c.readNetPackets()
+	if c.KeepAlive > 0 {
+		go c.keepAlive()
+	}
 	return c, nil
 }
 
@@ -76,96 +116,184 @@ func (c *Client) GetFID() FID {
 
 func (c *Client) readNetPackets() {
 	if c.FromNet == nil {
-		if c.Trace != nil {
-			c.Trace("c.FromNet is nil, marking dead")
-		}
-		c.Dead = true
+		c.die("FromNet is nil")
+		c.meter.close()
+		close(c.FromServer)
 		return
 	}
 	defer c.FromNet.Close()
 	defer close(c.FromServer)
-	if c.Trace != nil {
-		c.Trace("Starting readNetPackets")
-	}
-	for !c.Dead {
+	defer c.meter.close()
+	for !c.dead() {
 		l := make([]byte, 7)
-		if c.Trace != nil {
-			c.Trace("Before read")
-		}
-
-		if n, err := c.FromNet.Read(l); err != nil || n < 7 {
-			log.Printf("readNetPackets: short read: %v", err)
-			c.Dead = true
+		if _, err := io.ReadFull(c.FromNet, l); err != nil {
+			c.die("readNetPackets: short read: %v", err)
 			return
 		}
-		if c.Trace != nil {
-			c.Trace("Server reads %v", l)
-		}
 		s := int64(l[0]) + int64(l[1])<<8 + int64(l[2])<<16 + int64(l[3])<<24
-		b := bytes.NewBuffer(l)
-		r := io.LimitReader(c.FromNet, s-7)
-		if _, err := io.Copy(b, r); err != nil {
-			log.Printf("readNetPackets: short read: %v", err)
-			c.Dead = true
+		if s < 7 || s > MSIZE {
+			c.die("readNetPackets: bad message size %d", s)
 			return
 		}
-		if c.Trace != nil {
-			c.Trace("readNetPackets: got %v, len %d, sending to IO", RPCNames[MType(l[4])], b.Len())
+		b := bytes.NewBuffer(l)
+		if _, err := io.CopyN(b, c.FromNet, s-7); err != nil {
+			c.die("readNetPackets: short read: %v", err)
+			return
 		}
+		c.hear()
 		c.FromServer <- &RPCReply{b: b.Bytes()}
 	}
-	if c.Trace != nil {
-		c.Trace("Client %v is all done", c)
-	}
-
 }
 
+// IO writes the calls sent on FromClient and hands each reply from
+// FromServer to its call. A reply for a tag that is not in flight, from
+// a confused server, kills the client. Once the client is dead, the calls
+// waiting and any made after get an Rerror for ErrClientDead.
 func (c *Client) IO() {
+	clock := clockOr(c.Clock)
 	go func() {
 		for {
 			r := <-c.FromClient
 			t := <-c.Tags
-			if c.Trace != nil {
-				c.Trace(fmt.Sprintf("Tag for request is %v", t))
-			}
 			r.b[5] = uint8(t)
 			r.b[6] = uint8(t >> 8)
-			if c.Trace != nil {
-				c.Trace(fmt.Sprintf("Tag for request is %v", t))
-			}
-			c.RPC[int(t)-1] = r
-			if c.Trace != nil {
-				c.Trace("Write %v to ToNet", r.b)
+			if !c.register(t, r) {
+				c.fail(t, r)
+				continue
 			}
+			r.req = c.meter.begin(MType(r.b[4]), r.b[5:])
+			r.sent = clock.Now()
+			// The reply may be read before Write returns.
+			c.observe(MessageSent{Type: MType(r.b[4]), Tag: t, Size: len(r.b)})
 			if _, err := c.ToNet.Write(r.b); err != nil {
-				c.Dead = true
-				log.Fatalf("Write to server: %v", err)
-				return
+				// r is failed with the rest once readNetPackets stops.
+				c.die("Write to server: %v", err)
 			}
 		}
 	}()
 
-	for {
-		r := <-c.FromServer
-		if c.Trace != nil {
-			c.Trace("Read %v FromServer", r.b)
-		}
+	for r := range c.FromServer {
 		t := Tag(r.b[5]) | Tag(r.b[6])<<8
-		if c.Trace != nil {
-			c.Trace(fmt.Sprintf("Tag for reply is %v", t))
+		rrr := c.take(t)
+		if rrr == nil {
+			c.die("reply for tag %d, which is not in flight", t)
+			continue
 		}
-		if t < 1 {
-			panic(fmt.Sprintf("tag %d < 1", t))
-		}
-		if int(t-1) >= len(c.RPC) {
-			panic(fmt.Sprintf("tag %d >= len(c.RPC) %d", t, len(c.RPC)))
-		}
-		c.Trace("RPC %v ", c.RPC[t-1])
-		rrr := c.RPC[t-1]
-		c.Trace("rrr %v ", rrr)
+		c.observe(MessageReceived{Type: MType(r.b[4]), Tag: t, Size: len(r.b), Duration: clock.Now().Sub(rrr.sent)})
+		c.meter.end(rrr.req, r.b)
 		rrr.Reply <- r.b
 		c.Tags <- t
 	}
+	c.failAll()
+}
+
+// register records that r is waiting for the reply with tag t, unless the
+// client has died and failed its calls.
+func (c *Client) register(t Tag, r *RPCCall) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.failed {
+		return false
+	}
+	c.RPC[t-1] = r
+	return true
+}
+
+// waiting returns how many calls are waiting for replies.
+func (c *Client) waiting() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := 0
+	for _, r := range c.RPC {
+		if r != nil {
+			n++
+		}
+	}
+	return n
+}
+
+// take returns the call waiting for the reply with tag t, and forgets it;
+// nil if there is none.
+func (c *Client) take(t Tag) *RPCCall {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if t < 1 || int(t) > len(c.RPC) {
+		return nil
+	}
+	r := c.RPC[t-1]
+	c.RPC[t-1] = nil
+	return r
+}
+
+// failAll fails the calls still waiting for replies, and marks the client
+// failed so later calls are failed as they come.
+func (c *Client) failAll() {
+	c.mu.Lock()
+	c.failed = true
+	var rs []*RPCCall
+	var ts []Tag
+	for i, r := range c.RPC {
+		if r != nil {
+			rs, ts = append(rs, r), append(ts, Tag(i+1))
+			c.RPC[i] = nil
+		}
+	}
+	c.mu.Unlock()
+	for i, r := range rs {
+		c.fail(ts[i], r)
+	}
+}
+
+// fail answers r, sent with tag t, with an Rerror for ErrClientDead and
+// why the client died.
+func (c *Client) fail(t Tag, r *RPCCall) {
+	c.mu.Lock()
+	err := c.err
+	c.mu.Unlock()
+	r.Reply <- AppendRerror(nil, t, fmt.Sprintf("%v: %v", err, ErrClientDead))
+	c.Tags <- t
+}
+
+// hear notes that a message came from the server.
+func (c *Client) hear() {
+	atomic.StoreInt64(&c.heardAt, clockOr(c.Clock).Now().UnixNano())
+}
+
+// heard returns when the last message came from the server.
+func (c *Client) heard() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&c.heardAt))
+}
+
+// dead reports whether the client has died.
+func (c *Client) dead() bool {
+	select {
+	case <-c.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// die marks the client dead, says why, and closes its connection. Why
+// is a ClientDead, which is logged if the client has no Observer or Trace.
+func (c *Client) die(format string, args ...interface{}) {
+	c.doneOnce.Do(func() {
+		c.mu.Lock()
+		c.err = fmt.Errorf(format, args...)
+		c.mu.Unlock()
+		c.Dead = true
+		e := ClientDead{Err: c.err}
+		if !c.observe(e) {
+			log.Print(e)
+		}
+		close(c.done)
+		if c.ToNet != nil {
+			c.ToNet.Close()
+		}
+		if c.FromNet != nil {
+			c.FromNet.Close()
+		}
+	})
 }
 
 func (c *Client) String() string {
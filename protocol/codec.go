@@ -0,0 +1,42 @@
+// Copyright 2019 The Ninep Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protocol
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// grow returns b with room for n more bytes.
+func grow(b []byte, n int) []byte {
+	if cap(b)-len(b) >= n {
+		return b
+	}
+	nb := make([]byte, len(b), len(b)+n)
+	copy(nb, b)
+	return nb
+}
+
+// decodeHeader checks that src is one whole message of type t, and returns
+// the rest of it, starting at the tag.
+func decodeHeader(src []byte, t MType) ([]byte, error) {
+	if len(src) < 7 {
+		return nil, fmt.Errorf("pkt too short for header: need 7, have %d", len(src))
+	}
+	if l := int64(src[0]) | int64(src[1])<<8 | int64(src[2])<<16 | int64(src[3])<<24; l != int64(len(src)) {
+		return nil, fmt.Errorf("pkt size is %d, have %d bytes", l, len(src))
+	}
+	if MType(src[4]) != t {
+		return nil, fmt.Errorf("pkt type is %v, want %v", RPCNames[MType(src[4])], RPCNames[t])
+	}
+	return src[5:], nil
+}
+
+// reply makes b hold the reply p. The Srv functions append their replies
+// to the request's own buffer, so that in the common case p shares it and
+// nothing is copied.
+func reply(b *bytes.Buffer, p []byte) {
+	*b = *bytes.NewBuffer(p)
+}
@@ -0,0 +1,22 @@
+// Copyright 2019 The Ninep Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protocol_test
+
+import (
+	"testing"
+
+	"sevki.org/q9p/protocol"
+	"sevki.org/q9p/protocol/protocoltest"
+)
+
+// The echo server has no tree, so it only gets the parts of the suite
+// that do not need one. It leaves checking fids and tags to a FidTable.
+func TestConformance(t *testing.T) {
+	protocoltest.RunConformance(t, protocol.NewEcho, protocoltest.FidTable)
+}
+
+func TestScripts(t *testing.T) {
+	protocoltest.RunScripts(t, protocol.NewEcho, "testdata/*.txt", protocoltest.FidTable)
+}
@@ -0,0 +1,487 @@
+// Copyright 2019 The Ninep Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protocol
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultCtlAname is the usual aname for a Listener's control tree.
+const DefaultCtlAname = ".q9p"
+
+// The control tree is a synthetic file tree, in the Plan 9 way, for
+// looking at and managing a Listener with the tools used for its data. A
+// Listener with CtlAname set serves it to a Tattach with that aname, on
+// any connection, alongside its NineServer:
+//
+//	stats                  the Listener's Stats, one "name value" a line
+//	ctl                    write commands here; reading it gives the state
+//	conns/<id>/remote      the remote address of connection <id>
+//	conns/<id>/uname       the user it last attached as
+//	conns/<id>/fids        its fids, one a line
+//	conns/<id>/tags        the tags of its messages in flight, one a line
+//	conns/<id>/stats       counts of the above
+//
+// The fids and tags come from the connection's FidTable. The commands are
+//
+//	kill <id>              close connection <id>
+//	trace on|off           log Events, if the Listener has no Observer or Trace
+//
+// Only the Listener's CtlUsers may attach to it, on a connection its
+// CtlAuth accepts for them.
+//
+// ctlServer puts the tree beside a connection's NineServer: fids attached
+// to it, and walked to from those, are its, and the rest are passed on.
+// It keeps track of which of its fids are open, and how, itself, as the
+// Listener need not have a FidTable to do it.
+type ctlServer struct {
+	NineServer
+	l   *Listener
+	rwc net.Conn
+
+	mu   sync.Mutex
+	fids map[FID]*ctlFid
+}
+
+type ctlFid struct {
+	path []string // from the root, e.g. conns, 1, fids
+	open bool
+	mode Mode // what it was opened for
+	dir  bool
+	data []byte // the contents when it was opened
+}
+
+// The errors for reads of a control tree directory that cannot be
+// answered with whole entries.
+var (
+	errCtlShortRead = errors.New("read count too small for directory entry")
+	errCtlDirOffset = errors.New("bad offset in directory read")
+)
+
+// The kinds of file in the tree, in the low byte of their QID paths.
+const (
+	ctlRoot = iota
+	ctlStats
+	ctlCtl
+	ctlConns
+	ctlConn
+	ctlRemote
+	ctlUname
+	ctlFids
+	ctlTags
+	ctlConnStats
+)
+
+var ctlConnFiles = map[string]int{
+	"remote": ctlRemote,
+	"uname":  ctlUname,
+	"fids":   ctlFids,
+	"tags":   ctlTags,
+	"stats":  ctlConnStats,
+}
+
+// Unwrap returns the connection's own NineServer.
+func (s *ctlServer) Unwrap() NineServer {
+	return s.NineServer
+}
+
+func (s *ctlServer) fid(fid FID) (*ctlFid, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, ok := s.fids[fid]
+	return f, ok
+}
+
+// lookup returns the QID of the file at path, and, for a file in a
+// conns/<id> directory, the connection.
+func (s *ctlServer) lookup(path []string) (QID, *conn, error) {
+	q := func(kind int, id uint64) QID {
+		t := uint8(QTFILE)
+		if kind == ctlRoot || kind == ctlConns || kind == ctlConn {
+			t = QTDIR
+		}
+		return QID{Type: t, Path: id<<8 | uint64(kind)}
+	}
+	switch len(path) {
+	case 0:
+		return q(ctlRoot, 0), nil, nil
+	case 1:
+		switch path[0] {
+		case "stats":
+			return q(ctlStats, 0), nil, nil
+		case "ctl":
+			return q(ctlCtl, 0), nil, nil
+		case "conns":
+			return q(ctlConns, 0), nil, nil
+		}
+	case 2, 3:
+		if path[0] != "conns" {
+			break
+		}
+		id, err := strconv.ParseUint(path[1], 10, 64)
+		if err != nil {
+			break
+		}
+		c := s.l.conn(id)
+		if c == nil {
+			break
+		}
+		if len(path) == 2 {
+			return q(ctlConn, id), c, nil
+		}
+		if k, ok := ctlConnFiles[path[2]]; ok {
+			return q(k, id), c, nil
+		}
+	}
+	return QID{}, nil, ErrNoWalk
+}
+
+func (s *ctlServer) Rversion(msize MaxSize, version string) (MaxSize, string, error) {
+	s.mu.Lock()
+	s.fids = make(map[FID]*ctlFid)
+	s.mu.Unlock()
+	return s.NineServer.Rversion(msize, version)
+}
+
+func (s *ctlServer) Rattach(fid FID, afid FID, uname string, aname string) (QID, error) {
+	if aname != s.l.CtlAname {
+		return s.NineServer.Rattach(fid, afid, uname, aname)
+	}
+	ok := false
+	for _, u := range s.l.CtlUsers {
+		ok = ok || u == uname
+	}
+	if !ok || s.l.CtlAuth == nil || !s.l.CtlAuth(s.rwc, uname) {
+		return QID{}, os.ErrPermission
+	}
+	s.mu.Lock()
+	s.fids[fid] = &ctlFid{}
+	s.mu.Unlock()
+	return QID{Type: QTDIR}, nil
+}
+
+func (s *ctlServer) Rwalk(fid FID, newfid FID, paths []string) ([]QID, error) {
+	f, ok := s.fid(fid)
+	if !ok {
+		return s.NineServer.Rwalk(fid, newfid, paths)
+	}
+	path := append([]string{}, f.path...)
+	var qids []QID
+	for _, p := range paths {
+		if p == ".." {
+			if len(path) > 0 {
+				path = path[:len(path)-1]
+			}
+		} else {
+			path = append(path, p)
+		}
+		q, _, err := s.lookup(path)
+		if err != nil {
+			if len(qids) == 0 {
+				return nil, err
+			}
+			return qids, nil
+		}
+		qids = append(qids, q)
+	}
+	s.mu.Lock()
+	s.fids[newfid] = &ctlFid{path: path}
+	s.mu.Unlock()
+	return qids, nil
+}
+
+func (s *ctlServer) Ropen(fid FID, mode Mode) (QID, MaxSize, error) {
+	f, ok := s.fid(fid)
+	if !ok {
+		return s.NineServer.Ropen(fid, mode)
+	}
+	q, c, err := s.lookup(f.path)
+	if err != nil {
+		return QID{}, 0, err
+	}
+	kind := int(q.Path & 0xff)
+	if mode&3 != OREAD && kind != ctlCtl {
+		return QID{}, 0, ErrNoOpen
+	}
+	var data []byte
+	if q.Type&QTDIR != 0 {
+		data = s.dir(f.path)
+	} else {
+		data = s.read(kind, c)
+	}
+	s.mu.Lock()
+	f.open, f.mode, f.dir, f.data = true, mode, q.Type&QTDIR != 0, data
+	s.mu.Unlock()
+	return q, 0, nil
+}
+
+func (s *ctlServer) Rcreate(fid FID, name string, perm Perm, mode Mode) (QID, MaxSize, error) {
+	if _, ok := s.fid(fid); !ok {
+		return s.NineServer.Rcreate(fid, name, perm, mode)
+	}
+	return QID{}, 0, ErrNoCreate
+}
+
+func (s *ctlServer) Rread(fid FID, o Offset, c Count) ([]byte, error) {
+	f, ok := s.fid(fid)
+	if !ok {
+		return s.NineServer.Rread(fid, o, c)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !f.open {
+		return nil, ErrFIDNotOpen
+	}
+	if f.mode&3 == OWRITE {
+		return nil, ErrNotOpenRead
+	}
+	if o >= Offset(len(f.data)) {
+		return nil, nil
+	}
+	if f.dir {
+		return dirRead(f.data, o, c)
+	}
+	b := f.data[o:]
+	if len(b) > int(c) {
+		b = b[:c]
+	}
+	return b, nil
+}
+
+// dirRead returns the whole entries of the directory data, from offset
+// o, that fit in c bytes. o must be where an entry starts, as it is if
+// each read starts where the last one ended.
+func dirRead(data []byte, o Offset, c Count) ([]byte, error) {
+	// An entry is its size, in 2 bytes, and that many more.
+	size := func(b []byte) int {
+		if len(b) < 2 {
+			return len(b)
+		}
+		return 2 + (int(b[0]) | int(b[1])<<8)
+	}
+	start := 0
+	for Offset(start) < o {
+		start += size(data[start:])
+	}
+	if Offset(start) != o {
+		return nil, errCtlDirOffset
+	}
+	end := start
+	for end < len(data) && end+size(data[end:]) <= start+int(c) {
+		end += size(data[end:])
+	}
+	if end == start {
+		return nil, errCtlShortRead
+	}
+	return data[start:end], nil
+}
+
+func (s *ctlServer) Rwrite(fid FID, o Offset, b []byte) (Count, error) {
+	f, ok := s.fid(fid)
+	if !ok {
+		return s.NineServer.Rwrite(fid, o, b)
+	}
+	s.mu.Lock()
+	open, mode := f.open, f.mode
+	s.mu.Unlock()
+	switch {
+	case !open:
+		return 0, ErrFIDNotOpen
+	case mode&3 != OWRITE && mode&3 != ORDWR:
+		return 0, ErrNotOpenWrite
+	}
+	if len(f.path) != 1 || f.path[0] != "ctl" {
+		return 0, ErrNoWrite
+	}
+	for _, line := range strings.Split(string(b), "\n") {
+		if err := s.l.ctl(strings.Fields(line)); err != nil {
+			return 0, err
+		}
+	}
+	return Count(len(b)), nil
+}
+
+func (s *ctlServer) Rclunk(fid FID) error {
+	if _, ok := s.fid(fid); !ok {
+		return s.NineServer.Rclunk(fid)
+	}
+	s.mu.Lock()
+	delete(s.fids, fid)
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *ctlServer) Rremove(fid FID) error {
+	if _, ok := s.fid(fid); !ok {
+		return s.NineServer.Rremove(fid)
+	}
+	s.Rclunk(fid)
+	return ErrNoRemove
+}
+
+func (s *ctlServer) Rstat(fid FID) ([]byte, error) {
+	f, ok := s.fid(fid)
+	if !ok {
+		return s.NineServer.Rstat(fid)
+	}
+	d, err := s.stat(f.path)
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	Marshaldir(&b, d)
+	return b.Bytes(), nil
+}
+
+func (s *ctlServer) Rwstat(fid FID, b []byte) error {
+	if _, ok := s.fid(fid); !ok {
+		return s.NineServer.Rwstat(fid, b)
+	}
+	return ErrNoWstat
+}
+
+func (s *ctlServer) stat(path []string) (Dir, error) {
+	q, _, err := s.lookup(path)
+	if err != nil {
+		return Dir{}, err
+	}
+	d := Dir{QID: q, Name: "/", User: "q9p", Group: "q9p", ModUser: "q9p", Mode: 0444}
+	if len(path) > 0 {
+		d.Name = path[len(path)-1]
+	}
+	switch {
+	case q.Type&QTDIR != 0:
+		d.Mode = DMDIR | 0555
+	case q.Path&0xff == ctlCtl:
+		d.Mode = 0664
+	}
+	return d, nil
+}
+
+// dir returns the contents of the directory at path.
+func (s *ctlServer) dir(path []string) []byte {
+	var names []string
+	switch len(path) {
+	case 0:
+		names = []string{"conns", "ctl", "stats"}
+	case 1:
+		for _, c := range s.l.Conns() {
+			names = append(names, strconv.FormatUint(c.ID, 10))
+		}
+	case 2:
+		for n := range ctlConnFiles {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+	}
+	// Marshaldir starts its buffer afresh, so each entry gets its own.
+	var b, e bytes.Buffer
+	for _, n := range names {
+		if d, err := s.stat(append(append([]string{}, path...), n)); err == nil {
+			Marshaldir(&e, d)
+			b.Write(e.Bytes())
+		}
+	}
+	return b.Bytes()
+}
+
+// read returns the contents of a file of the given kind; c is its
+// connection, for those under conns.
+func (s *ctlServer) read(kind int, c *conn) []byte {
+	var b bytes.Buffer
+	switch kind {
+	case ctlStats:
+		st := s.l.Stats()
+		for _, v := range []struct {
+			n string
+			v interface{}
+		}{
+			{"conns", st.Conns},
+			{"fids", st.Fids},
+			{"panics", st.Panics},
+			{"timeouts", st.Timeouts},
+			{"waits", st.Waits},
+			{"bufferfull", st.BufferFull},
+			{"toomanyfids", st.TooManyFIDs},
+			{"toomanyinflight", st.TooManyInFlight},
+			{"toobig", st.TooBig},
+			{"toomanyconns", st.TooManyConns},
+		} {
+			fmt.Fprintf(&b, "%v %v\n", v.n, v.v)
+		}
+	case ctlCtl:
+		state := "off"
+		if s.l.tracingOn() {
+			state = "on"
+		}
+		fmt.Fprintf(&b, "trace %v\n", state)
+	case ctlRemote:
+		fmt.Fprintf(&b, "%v\n", c.remoteAddr)
+	case ctlUname:
+		fmt.Fprintf(&b, "%v\n", c.info().Uname)
+	case ctlFids:
+		if c.server.Fids != nil {
+			for _, f := range c.server.Fids.Fids() {
+				fmt.Fprintf(&b, "%d\n", f)
+			}
+		}
+	case ctlTags:
+		for _, t := range c.info().Tags {
+			fmt.Fprintf(&b, "%d\n", t)
+		}
+	case ctlConnStats:
+		i := c.info()
+		fmt.Fprintf(&b, "fids %d\nopen %d\ntags %d\n", i.Fids, i.Open, len(i.Tags))
+	}
+	return b.Bytes()
+}
+
+// ctl carries out a command written to the control tree's ctl file.
+func (l *Listener) ctl(f []string) error {
+	switch {
+	case len(f) == 0:
+		return nil
+	case f[0] == "kill" && len(f) == 2:
+		id, err := strconv.ParseUint(f[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("ctl: kill: bad conn %q", f[1])
+		}
+		c := l.conn(id)
+		if c == nil {
+			return fmt.Errorf("ctl: kill: no conn %d", id)
+		}
+		c.setReason(errors.New("killed by ctl"))
+		return c.rwc.Close()
+	case f[0] == "trace" && len(f) == 2 && (f[1] == "on" || f[1] == "off"):
+		var on int32
+		if f[1] == "on" {
+			on = 1
+		}
+		atomic.StoreInt32(&l.tracing, on)
+		return nil
+	}
+	return fmt.Errorf("ctl: bad command %q", strings.Join(f, " "))
+}
+
+// conn returns the connection with the given id, or nil.
+func (l *Listener) conn(id uint64) *conn {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for c := range l.conns {
+		if c.id == id {
+			return c
+		}
+	}
+	return nil
+}
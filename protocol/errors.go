@@ -0,0 +1,135 @@
+// Copyright 2019 The Ninep Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protocol
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+)
+
+// Error is an error that goes, or came, over the wire in an Rerror.
+// Servers answer with the Error that NewError makes of the NineServer's
+// error, so the same Go error always gets the same string and errno; a
+// Client returns an Error for each Rerror it gets, which unwraps to the
+// Go error for its string or errno, so
+//
+//	errors.Is(err, os.ErrNotExist)
+//
+// works on both sides of a connection.
+type Error struct {
+	Err   string // the error string, as in Rerror
+	Errno uint32 // the errno, for 9P2000.u and 9P2000.L
+	err   error
+}
+
+func (e *Error) Error() string {
+	return e.Err
+}
+
+// Unwrap returns the Go error e stands for, e.g. os.ErrNotExist, or nil
+// if the error string is not one NewError makes.
+func (e *Error) Unwrap() error {
+	return e.err
+}
+
+// errorTable is the canonical mapping between Go errors, Plan 9 error
+// strings and errnos. The strings are the ones Plan 9's kernel and lib9p
+// use. NewError takes the first line the error matches; lookupError the
+// first line with the string or errno. ENOTEMPTY comes before
+// os.ErrExist, which it matches too.
+var errorTable = []struct {
+	err   error
+	errno uint32
+	s     string
+}{
+	{os.ErrNotExist, ENOENT, "file does not exist"},
+	{os.ErrPermission, EACCES, "permission denied"},
+	{os.ErrPermission, EPERM, "permission denied"},
+	{syscall.ENOTEMPTY, ENOTEMPTY, "directory not empty"},
+	{os.ErrExist, EEXIST, "file already exists"},
+	{syscall.ENOTDIR, ENOTDIR, "not a directory"},
+	{syscall.EISDIR, EISDIR, "file is a directory"},
+	{syscall.ENAMETOOLONG, ENAMETOOLONG, "file name too long"},
+	{syscall.ENOSPC, ENOSPC, "file system full"},
+	{syscall.EROFS, EROFS, "read only file system"},
+	{os.ErrInvalid, EINVAL, "bad arg in system call"},
+	{ErrUnknownFID, EBADF, ErrUnknownFID.Error()},
+	{ErrFIDNotOpen, EBADF, ErrFIDNotOpen.Error()},
+	{syscall.EIO, EIO, "i/o error"},
+	{ErrClientDead, EIO, ErrClientDead.Error()},
+}
+
+// NewError makes the Error a server answers err with. Errors in the table
+// get its string and errno, whatever their text, so an *os.PathError for
+// ENOENT becomes "file does not exist"; other errors keep their text and
+// get EIO. An *Error is returned as is.
+func NewError(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	for _, r := range errorTable {
+		if errors.Is(err, r.err) {
+			return &Error{Err: r.s, Errno: r.errno, err: r.err}
+		}
+	}
+	return &Error{Err: err.Error(), Errno: EIO}
+}
+
+// lookupError makes the Error a client returns for an Rerror with string
+// s or, if it is not zero, errno. A string that ends in one from the
+// table, such as "/tmp/x: file does not exist", counts as that string.
+// An Rlerror has no string, so s is empty and the table's is used.
+func lookupError(s string, errno uint32) *Error {
+	for _, r := range errorTable {
+		if errno != 0 && errno == r.errno || errno == 0 && (s == r.s || strings.HasSuffix(s, ": "+r.s)) {
+			if s == "" {
+				s = r.s
+			}
+			return &Error{Err: s, Errno: r.errno, err: r.err}
+		}
+	}
+	if s == "" {
+		s = fmt.Sprintf("errno %d", errno)
+	}
+	return &Error{Err: s, Errno: errno}
+}
+
+// errorString is the string to send in an Rerror for err.
+func errorString(err error) string {
+	return NewError(err).Err
+}
+
+// replyError returns the error in the reply bb, if it is an Rerror or an
+// Rlerror, and nil otherwise.
+func replyError(bb []byte) error {
+	if len(bb) < 5 {
+		return fmt.Errorf("pkt too short for header: need 5, have %d", len(bb))
+	}
+	switch MType(bb[4]) {
+	case Rerror:
+		var e RerrorMsg
+		if err := DecodeRerror(bb, &e); err != nil {
+			return err
+		}
+		return lookupError(e.Error, 0)
+	case Rlerror:
+		var e RlerrorMsg
+		if err := DecodeRlerror(bb, &e); err != nil {
+			return err
+		}
+		return lookupError("", e.Ecode)
+	}
+	return nil
+}
+
+// errorReply is ServerError for a message whose tag is known.
+func errorReply(b *bytes.Buffer, t Tag, err error) {
+	MarshalRerrorPkt(b, t, errorString(err))
+}
@@ -0,0 +1,10 @@
+// Copyright 2019 The Ninep Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protocol
+
+// NewEcho makes the echo server for the tests outside the package.
+func NewEcho() NineServer {
+	return newEcho()
+}
@@ -0,0 +1,260 @@
+// Copyright 2019 The Ninep Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protocol
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// An Extension is a pair of private messages, such as a server-side copy
+// or a checksum, that is not part of any 9P dialect. Extensions are kept in
+// a Registry; Listener, Server and Client each have an Extensions field.
+//
+// Peers agree on extensions in Tversion: the client appends "+name" for
+// each extension it knows to the version string, e.g. "9P2000+copy+sum",
+// and the server answers with the ones it knows too. A server that knows
+// none of them, or that has never heard of extensions, answers with a
+// plain version, and the client does not send them.
+type Extension struct {
+	// Name is the name advertised in the version string.
+	Name string
+	// T and R are the message types. They must not be used by any dialect.
+	T, R MType
+	// DecodeT and DecodeR decode a T or R message. The buffer starts at
+	// the tag, as for the UnmarshalXPkt functions.
+	DecodeT, DecodeR func(b *bytes.Buffer) (Msg, error)
+	// Handler answers a T message on a server. If it returns an error the
+	// client gets an Rerror. Msg.Encode must write a whole message, size
+	// and all, as the generated Msg types do.
+	Handler func(s *Server, m Msg) (Msg, error)
+}
+
+// A Registry is a set of extensions. It is safe for concurrent use.
+type Registry struct {
+	mu    sync.RWMutex
+	names map[string]*Extension
+	types map[MType]*Extension
+}
+
+// NewRegistry returns an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		names: make(map[string]*Extension),
+		types: make(map[MType]*Extension),
+	}
+}
+
+// Register adds e to the registry. It fails if e's name or types are
+// already taken, by a dialect or by another extension.
+func (r *Registry) Register(e *Extension) error {
+	if e.Name == "" || strings.ContainsAny(e.Name, "+. ") {
+		return fmt.Errorf("extension %q: bad name", e.Name)
+	}
+	if e.T == e.R {
+		return fmt.Errorf("extension %v: T and R are both %d", e.Name, e.T)
+	}
+	if e.DecodeT == nil || e.DecodeR == nil {
+		return fmt.Errorf("extension %v: no decoder", e.Name)
+	}
+	for _, t := range []MType{e.T, e.R} {
+		if n, ok := RPCNames[t]; ok {
+			return fmt.Errorf("extension %v: type %d is %v", e.Name, t, n)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.names[e.Name]; ok {
+		return fmt.Errorf("extension %v: already registered", e.Name)
+	}
+	for _, t := range []MType{e.T, e.R} {
+		if o, ok := r.types[t]; ok {
+			return fmt.Errorf("extension %v: type %d is used by %v", e.Name, t, o.Name)
+		}
+	}
+	r.names[e.Name] = e
+	r.types[e.T] = e
+	r.types[e.R] = e
+	return nil
+}
+
+// Lookup returns the extension using message type t, or nil.
+func (r *Registry) Lookup(t MType) *Extension {
+	if r == nil {
+		return nil
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.types[t]
+}
+
+// Names returns the names of the registered extensions, sorted.
+func (r *Registry) Names() []string {
+	if r == nil {
+		return nil
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var n []string
+	for k := range r.names {
+		n = append(n, k)
+	}
+	sort.Strings(n)
+	return n
+}
+
+// Decode is the package's Decode, but knows the registered extensions too.
+func (r *Registry) Decode(b []byte) (Msg, error) {
+	if len(b) < 7 {
+		return Decode(b)
+	}
+	e := r.Lookup(MType(b[4]))
+	if e == nil {
+		return Decode(b)
+	}
+	if l := int64(b[0]) | int64(b[1])<<8 | int64(b[2])<<16 | int64(b[3])<<24; l != int64(len(b)) {
+		return nil, fmt.Errorf("pkt size is %d, have %d bytes", l, len(b))
+	}
+	if MType(b[4]) == e.T {
+		return e.DecodeT(bytes.NewBuffer(b[5:]))
+	}
+	return e.DecodeR(bytes.NewBuffer(b[5:]))
+}
+
+// agree picks the names the registry knows out of names, and returns the
+// version suffix for them and a map of their types.
+func (r *Registry) agree(names []string) (string, map[MType]*Extension) {
+	var s string
+	m := make(map[MType]*Extension)
+	for _, n := range names {
+		if r == nil {
+			break
+		}
+		r.mu.RLock()
+		e, ok := r.names[n]
+		r.mu.RUnlock()
+		if !ok || m[e.T] != nil {
+			continue
+		}
+		s += "+" + n
+		m[e.T], m[e.R] = e, e
+	}
+	return s, m
+}
+
+// splitVersion splits a version string into the version proper and the
+// extensions appended to it.
+func splitVersion(v string) (string, []string) {
+	f := strings.Split(v, "+")
+	return f[0], f[1:]
+}
+
+// dispatchExtension answers the messages that extensions have a say in:
+// Tversion, so the extensions can be agreed on, and the messages of
+// extensions the client and server agreed on. It returns false for
+// anything else.
+func (s *Server) dispatchExtension(b *bytes.Buffer, t MType) (bool, error) {
+	if t == Tversion {
+		return true, s.srvVersion(b)
+	}
+	e, ok := s.extensions[t]
+	if !ok || t != e.T {
+		return false, nil
+	}
+	var tag Tag
+	if b.Len() >= 2 {
+		tag = Tag(b.Bytes()[0]) | Tag(b.Bytes()[1])<<8
+	}
+	m, err := e.DecodeT(b)
+	if err != nil {
+		errorReply(b, tag, err)
+		return true, nil
+	}
+	if e.Handler == nil {
+		MarshalRerrorPkt(b, m.Tag(), fmt.Sprintf("%v: no handler", e.Name))
+		return true, nil
+	}
+	r, err := e.Handler(s, m)
+	if err != nil {
+		errorReply(b, m.Tag(), err)
+		return true, nil
+	}
+	r.Encode(b)
+	return true, nil
+}
+
+// srvVersion is SrvRversion, but aware of extensions. The NineServer sees
+// the version without them; if it agrees to it, the reply names the
+// extensions both sides know.
+func (s *Server) srvVersion(b *bytes.Buffer) error {
+	msize, version, tag, err := UnmarshalTversionPkt(b)
+	if err != nil {
+		errorReply(b, tag, err)
+		return nil
+	}
+	v, names := splitVersion(version)
+	// A Tversion starts a new session, and it may agree on different
+	// extensions from the last one.
+	s.extensions = nil
+	rmsize, rversion, err := s.NS.Rversion(msize, v)
+	if err != nil {
+		errorReply(b, tag, err)
+		return nil
+	}
+	if rversion == v {
+		var suffix string
+		suffix, s.extensions = s.Extensions.agree(names)
+		rversion += suffix
+	}
+	MarshalRversionPkt(b, tag, rmsize, rversion)
+	return nil
+}
+
+// Version sends a Tversion advertising the client's extensions, and
+// remembers which of them the server agreed to. The version returned does
+// not include them. Some servers answer a version they do not know with
+// Rerror rather than an older version; for those, Version tries again
+// without the extensions.
+func (c *Client) Version(msize MaxSize, version string) (MaxSize, string, error) {
+	var suffix string
+	for _, n := range c.Extensions.Names() {
+		suffix += "+" + n
+	}
+	m, v, err := c.CallTversion(msize, version+suffix)
+	if err != nil && suffix != "" {
+		m, v, err = c.CallTversion(msize, version)
+	}
+	if err != nil {
+		return m, v, err
+	}
+	v, names := splitVersion(v)
+	_, c.extensions = c.Extensions.agree(names)
+	return m, v, nil
+}
+
+// CallExtension sends m, which must be the T message of an extension the
+// server agreed to in Version, and returns the reply.
+func (c *Client) CallExtension(m Msg) (Msg, error) {
+	e, ok := c.extensions[m.Type()]
+	if !ok || m.Type() != e.T {
+		return nil, fmt.Errorf("CallExtension: %d: server does not support it", m.Type())
+	}
+	var b = bytes.Buffer{}
+	r := make(chan []byte)
+	m.Encode(&b)
+	c.FromClient <- &RPCCall{b: b.Bytes(), Reply: r}
+	bb := <-r
+	if err := replyError(bb); err != nil {
+		return nil, err
+	}
+	if MType(bb[4]) == e.R {
+		return e.DecodeR(bytes.NewBuffer(bb[5:]))
+	}
+	return nil, fmt.Errorf("CallExtension: %v: got %d, want %d", e.Name, bb[4], e.R)
+}
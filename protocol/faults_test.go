@@ -0,0 +1,156 @@
+// Copyright 2019 The Ninep Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protocol_test
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"sevki.org/q9p/protocol"
+	"sevki.org/q9p/protocol/protocoltest"
+)
+
+// TestFaults runs a client and the echo server over a link that does
+// what the Faults say to one side's writes. Faults a link may have, like
+// fragments and delays, must not matter; the rest must make the client
+// die and the calls fail, rather than panic or hang. Either way both ends
+// must stop once the link is closed.
+func TestFaults(t *testing.T) {
+	for _, v := range []struct {
+		server bool // whether the faults are in the server's writes
+		f      protocoltest.Faults
+		ok     bool
+	}{
+		{false, protocoltest.Faults{}, true},
+		{false, protocoltest.Faults{Seed: 1, Fragment: 3}, true},
+		{true, protocoltest.Faults{Seed: 2, Fragment: 5}, true},
+		{false, protocoltest.Faults{Seed: 3, Latency: time.Millisecond}, true},
+		{true, protocoltest.Faults{Seed: 4, Latency: time.Millisecond, Fragment: 2}, true},
+		{false, protocoltest.Faults{Hold: 3}, true},
+		{true, protocoltest.Faults{Hold: 5}, true},
+		{false, protocoltest.Faults{ResetAfter: 100}, false},
+		{true, protocoltest.Faults{Seed: 5, Fragment: 4, ResetAfter: 100}, false},
+		{false, protocoltest.Faults{Seed: 6, Corrupt: 4}, false},
+		{true, protocoltest.Faults{Seed: 7, Corrupt: 4}, false},
+		{false, protocoltest.Faults{Duplicate: 4}, false},
+		{true, protocoltest.Faults{Duplicate: 4}, false},
+	} {
+		name := fmt.Sprintf("client %+v", v.f)
+		if v.server {
+			name = fmt.Sprintf("server %+v", v.f)
+		}
+		t.Run(name, func(t *testing.T) {
+			testFaults(t, v.server, v.f, v.ok)
+		})
+	}
+}
+
+func testFaults(t *testing.T, server bool, f protocoltest.Faults, ok bool) {
+	closed := make(chan protocol.ConnClosed, 1)
+	l, err := protocol.NewListener(protocol.NewEcho, func(l *protocol.Listener) error {
+		l.Observer = protocol.ObserverFunc(func(e protocol.Event) {
+			if e, ok := e.(protocol.ConnClosed); ok {
+				closed <- e
+			}
+		})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, p2 := net.Pipe()
+	var toNet, toClient net.Conn = p, p2
+	if server {
+		toClient = protocoltest.NewFaultyConn(p2, f)
+	} else {
+		toNet = protocoltest.NewFaultyConn(p, f)
+	}
+	if err := l.Accept(toClient); err != nil {
+		t.Fatal(err)
+	}
+	dead := make(chan protocol.ClientDead, 1)
+	c, err := protocol.NewClient(func(c *protocol.Client) error {
+		c.FromNet, c.ToNet = p, toNet
+		// A corrupt size that is not absurd leaves the server waiting
+		// for the rest of a message that never comes.
+		c.KeepAlive = 200 * time.Millisecond
+		c.Observer = protocol.ObserverFunc(func(e protocol.Event) {
+			if e, ok := e.(protocol.ClientDead); ok {
+				dead <- e
+			}
+		})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errs := make(chan error, 100)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if _, _, err := c.CallTversion(8192, "9P2000"); err != nil {
+			errs <- err
+			return
+		}
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func(fid protocol.FID) {
+				defer wg.Done()
+				for j := 0; j < 4; j++ {
+					if _, err := c.CallTattach(fid, protocol.NOFID, "glenda", ""); err != nil {
+						errs <- err
+						return
+					}
+					if _, _, err := c.CallTopen(fid, protocol.OREAD); err != nil {
+						errs <- err
+						return
+					}
+				}
+			}(protocol.FID(i))
+		}
+		wg.Wait()
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("calls still waiting after 10s")
+	}
+	close(errs)
+
+	if ok {
+		for err := range errs {
+			t.Errorf("call: want nil, got %v", err)
+		}
+	} else {
+		select {
+		case e := <-dead:
+			t.Logf("client died: %v", e.Err)
+		default:
+			t.Errorf("client is alive, want it dead")
+		}
+		for err := range errs {
+			if !errors.Is(err, protocol.ErrClientDead) {
+				t.Errorf("call: want ErrClientDead, got %v", err)
+			}
+		}
+	}
+
+	p.Close()
+	select {
+	case e := <-closed:
+		t.Logf("server closed: %v", e.Err)
+	case <-time.After(5 * time.Second):
+		t.Errorf("server still serving 5s after the link closed")
+	}
+	if n := l.Panics(); n != 0 {
+		t.Errorf("server panicked %d times", n)
+	}
+}
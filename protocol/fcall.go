@@ -0,0 +1,106 @@
+// Copyright 2019 The Ninep Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protocol
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// Msg is a single decoded 9P message. gen.go emits one struct per message,
+// named for the message with a Msg suffix (TwalkMsg, RreadMsg, ...), and
+// Decode returns them. String prints the message the way Plan 9's fcall(2)
+// %F verb does, so traces can be compared with those from Plan 9 tools.
+type Msg interface {
+	Type() MType
+	Tag() Tag
+	Encode(b *bytes.Buffer)
+	String() string
+}
+
+// String prints a QID as fcall(2) does: (path version type).
+func (q QID) String() string {
+	var t string
+	for _, f := range []struct {
+		bit uint8
+		c   string
+	}{
+		{QTDIR, "d"},
+		{QTAPPEND, "a"},
+		{QTEXCL, "l"},
+		{QTMOUNT, "m"},
+		{QTAUTH, "A"},
+		{QTTMP, "t"},
+		{QTSYMLINK, "L"},
+	} {
+		if q.Type&f.bit != 0 {
+			t += f.c
+		}
+	}
+	return fmt.Sprintf("(%.16x %d %s)", q.Path, q.Version, t)
+}
+
+// String prints a Dir as fcall(2) does, with the mode in octal.
+func (d Dir) String() string {
+	return fmt.Sprintf("'%s' '%s' '%s' '%s' q %v m %#o at %d mt %d l %d t %d d %d",
+		d.Name, d.User, d.Group, d.ModUser, d.QID, d.Mode,
+		d.Atime, d.Mtime, d.Length, d.Type, d.Dev)
+}
+
+// dirModeString is the %M verb from Plan 9's dirmodefmt.
+func dirModeString(m uint32) string {
+	var b bytes.Buffer
+	switch {
+	case m&DMDIR != 0:
+		b.WriteByte('d')
+	case m&DMAPPEND != 0:
+		b.WriteByte('a')
+	case m&DMAUTH != 0:
+		b.WriteByte('A')
+	default:
+		b.WriteByte('-')
+	}
+	if m&DMEXCL != 0 {
+		b.WriteByte('l')
+	} else {
+		b.WriteByte('-')
+	}
+	for i := 2; i >= 0; i-- {
+		r := (m >> (uint(i) * 3)) & 7
+		for j, c := range "rwx" {
+			if r&(4>>uint(j)) != 0 {
+				b.WriteRune(c)
+			} else {
+				b.WriteByte('-')
+			}
+		}
+	}
+	return b.String()
+}
+
+// statString prints the stat buffer carried by Rstat and Twstat.
+func statString(b []byte) string {
+	d, err := Unmarshaldir(bytes.NewBuffer(b))
+	if err != nil {
+		return fmt.Sprintf("(%d bytes)", len(b))
+	}
+	return d.String()
+}
+
+// dumpsome prints the start of a Tread or Twrite payload: as a string if
+// it is printable, in hex otherwise.
+func dumpsome(b []byte) string {
+	const max = 64
+	var tail string
+	if len(b) > max {
+		b, tail = b[:max], "..."
+	}
+	for _, c := range b {
+		if (c < ' ' || c > '~') && c != '\n' && c != '\t' {
+			return fmt.Sprintf("%x%s", b, tail)
+		}
+	}
+	return fmt.Sprintf("'%s'%s", b, tail)
+}
@@ -0,0 +1,360 @@
+// Copyright 2019 The Ninep Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protocol
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+	"sync"
+)
+
+// The errors a FidTable answers bad messages with. The strings are the
+// ones Plan 9's lib9p uses.
+var (
+	ErrUnknownFID   = errors.New("unknown fid")
+	ErrDupFID       = errors.New("duplicate fid")
+	ErrDupTag       = errors.New("duplicate tag")
+	ErrCloneOpen    = errors.New("cannot clone open fid")
+	ErrWalkNoDir    = errors.New("walk in non-directory")
+	ErrCreateNoDir  = errors.New("create in non-directory")
+	ErrTooManyNames = errors.New("too many names in walk")
+	ErrFIDOpen      = errors.New("fid already open")
+	ErrFIDNotOpen   = errors.New("fid not open")
+	ErrNotOpenRead  = errors.New("fid not open for reading")
+	ErrNotOpenWrite = errors.New("fid not open for writing")
+)
+
+// A Fid is what a FidTable knows about one fid.
+type Fid struct {
+	QID  QID
+	Open bool
+	Mode Mode // the mode it was opened with, if Open
+	// Uname is the user that attached it, or the fid it was walked from,
+	// and Remote the remote address of the connection.
+	Uname  string
+	Remote string
+	// Aux is for the NineServer: it can keep whatever it likes here, e.g.
+	// the open file, and the FidTable drops it when the fid goes away.
+	Aux interface{}
+}
+
+// A FidTable keeps track of the fids and tags in use on a connection and
+// checks each T message against them, so a NineServer never sees a
+// message that makes no sense: I/O on a fid that is not open, a walk from
+// an open fid, a fid or tag that is already in use and so on. Such
+// messages are answered with Rerror before they reach the NineServer. A
+// Tflush of a message that is not in flight, which has been answered
+// already or never came, is answered with Rflush, as there is nothing to
+// flush.
+//
+// A Listener with FidTable set gives each connection a FidTable. The
+// Listener reads messages ahead of the one being answered, and checks
+// their tags as they arrive; the rest is checked as each is dispatched.
+// A NineServer that wants to use it, e.g. for the Aux slot, implements
+// FidTableSetter.
+type FidTable struct {
+	mu   sync.Mutex
+	fids map[FID]*Fid
+	tags map[Tag]bool
+
+	// maxFids and maxInFlight are the Listener's Limits, and counters
+	// where it counts them being hit.
+	maxFids, maxInFlight int
+	counters             *counters
+
+	// remote is the remote address of the connection, for each Fid.
+	remote string
+}
+
+// FidTableSetter is implemented by a NineServer that wants its
+// connection's FidTable. SetFidTable is called once, before any messages.
+type FidTableSetter interface {
+	SetFidTable(t *FidTable)
+}
+
+// NewFidTable returns an empty FidTable.
+func NewFidTable() *FidTable {
+	return &FidTable{
+		fids: make(map[FID]*Fid),
+		tags: make(map[Tag]bool),
+	}
+}
+
+// Get returns a copy of what the table knows about fid.
+func (t *FidTable) Get(fid FID) (Fid, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	f, ok := t.fids[fid]
+	if !ok {
+		return Fid{}, false
+	}
+	return *f, true
+}
+
+// SetAux sets the Aux slot of fid. A fid being attached to or walked to is
+// in the table while Rattach or Rwalk runs, so they can set it.
+func (t *FidTable) SetAux(fid FID, aux interface{}) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	f, ok := t.fids[fid]
+	if !ok {
+		return ErrUnknownFID
+	}
+	f.Aux = aux
+	return nil
+}
+
+// Len returns the number of fids in use.
+func (t *FidTable) Len() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.fids)
+}
+
+// Fids returns the fids in use, sorted.
+func (t *FidTable) Fids() []FID {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	fids := make([]FID, 0, len(t.fids))
+	for f := range t.fids {
+		fids = append(fids, f)
+	}
+	sort.Slice(fids, func(i, j int) bool { return fids[i] < fids[j] })
+	return fids
+}
+
+// Open returns the number of open fids.
+func (t *FidTable) Open() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var n int
+	for _, f := range t.fids {
+		if f.Open {
+			n++
+		}
+	}
+	return n
+}
+
+// Tags returns the tags of the messages in flight, sorted.
+func (t *FidTable) Tags() []Tag {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	tags := make([]Tag, 0, len(t.tags))
+	for tag := range t.tags {
+		tags = append(tags, tag)
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i] < tags[j] })
+	return tags
+}
+
+// begin marks tag in flight, as a message with it has arrived, unless it
+// is in flight already or there are MaxInFlight tags in flight. It is not
+// called for Tversion, whose tag is usually NOTAG.
+func (t *FidTable) begin(tag Tag) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.tags[tag] {
+		return ErrDupTag
+	}
+	if t.maxInFlight > 0 && len(t.tags) >= t.maxInFlight {
+		t.counters.add(tooManyInFlight)
+		return ErrTooManyInFlight
+	}
+	t.tags[tag] = true
+	return nil
+}
+
+// end marks tag as no longer in flight, as its message has been
+// answered.
+func (t *FidTable) end(tag Tag) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.tags, tag)
+}
+
+// check decodes the T message in b and checks it. If it is bad, check
+// replaces it with an Rerror and returns false, as it does with Rflush
+// for a Tflush with nothing to flush. If it is good, check makes an
+// entry for any new fid, and returns the message for update. Messages
+// check cannot decode are let through for the Srv functions to complain
+// about.
+func (t *FidTable) check(b *bytes.Buffer, typ MType) (Msg, bool) {
+	m, err := decodeMsg(typ, b.Bytes())
+	if err != nil {
+		return nil, true
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if f, ok := m.(*TflushMsg); ok && (f.OTag == f.MTag || !t.tags[f.OTag]) {
+		MarshalRflushPkt(b, f.MTag)
+		return nil, false
+	}
+	if err = t.checkLocked(m); err != nil {
+		errorReply(b, m.Tag(), err)
+		return nil, false
+	}
+	return m, true
+}
+
+func (t *FidTable) checkLocked(m Msg) error {
+	if m.Type() == Tversion {
+		// Tversion clunks every fid. The messages before it have been
+		// answered; those after it are in the new session.
+		t.fids = make(map[FID]*Fid)
+		return nil
+	}
+
+	var err error
+	switch m := m.(type) {
+	case *TattachMsg:
+		if _, ok := t.fids[m.SFID]; ok {
+			return ErrDupFID
+		}
+		if err = t.room(); err != nil {
+			return err
+		}
+		t.fids[m.SFID] = &Fid{Uname: m.Uname, Remote: t.remote}
+	case *TwalkMsg:
+		var f *Fid
+		if f, err = t.fid(m.SFID); err != nil {
+			return err
+		}
+		switch {
+		case f.Open:
+			return ErrCloneOpen
+		case len(m.Paths) > MAXWELEM:
+			return ErrTooManyNames
+		case len(m.Paths) > 0 && f.QID.Type&QTDIR == 0:
+			return ErrWalkNoDir
+		}
+		if m.NewFID != m.SFID {
+			if _, ok := t.fids[m.NewFID]; ok {
+				return ErrDupFID
+			}
+			if err = t.room(); err != nil {
+				return err
+			}
+			t.fids[m.NewFID] = &Fid{QID: f.QID, Uname: f.Uname, Remote: f.Remote}
+		}
+	case *TopenMsg:
+		err = t.closed(m.OFID)
+	case *TcreateMsg:
+		if err = t.closed(m.OFID); err == nil && t.fids[m.OFID].QID.Type&QTDIR == 0 {
+			err = ErrCreateNoDir
+		}
+	case *TreadMsg:
+		var f *Fid
+		if f, err = t.open(m.OFID); err == nil && f.Mode&3 == OWRITE {
+			err = ErrNotOpenRead
+		}
+	case *TwriteMsg:
+		var f *Fid
+		if f, err = t.open(m.OFID); err == nil && f.Mode&3 != OWRITE && f.Mode&3 != ORDWR {
+			err = ErrNotOpenWrite
+		}
+	case *TclunkMsg:
+		_, err = t.fid(m.OFID)
+	case *TremoveMsg:
+		_, err = t.fid(m.OFID)
+	case *TstatMsg:
+		_, err = t.fid(m.OFID)
+	case *TwstatMsg:
+		_, err = t.fid(m.OFID)
+	}
+	return err
+}
+
+func (t *FidTable) fid(fid FID) (*Fid, error) {
+	f, ok := t.fids[fid]
+	if !ok {
+		return nil, ErrUnknownFID
+	}
+	return f, nil
+}
+
+// room returns an error if there is no room for another fid.
+func (t *FidTable) room() error {
+	if t.maxFids > 0 && len(t.fids) >= t.maxFids {
+		t.counters.add(tooManyFIDs)
+		return ErrTooManyFIDs
+	}
+	return nil
+}
+
+// closed returns an error unless fid is known and not open.
+func (t *FidTable) closed(fid FID) error {
+	f, err := t.fid(fid)
+	if err == nil && f.Open {
+		err = ErrFIDOpen
+	}
+	return err
+}
+
+// open returns fid if it is known and open.
+func (t *FidTable) open(fid FID) (*Fid, error) {
+	f, err := t.fid(fid)
+	if err == nil && !f.Open {
+		err = ErrFIDNotOpen
+	}
+	return f, err
+}
+
+// update brings the table up to date once m has been answered with the
+// reply in b.
+func (t *FidTable) update(m Msg, b *bytes.Buffer) {
+	if m == nil {
+		return
+	}
+	r, err := Decode(b.Bytes())
+	if err != nil {
+		r = nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	switch m := m.(type) {
+	case *TattachMsg:
+		if r, ok := r.(*RattachMsg); ok {
+			t.set(m.SFID, r.QID, false, 0)
+		} else {
+			delete(t.fids, m.SFID)
+		}
+	case *TwalkMsg:
+		r, ok := r.(*RwalkMsg)
+		switch {
+		case !ok || len(r.QIDs) != len(m.Paths):
+			// A failed or partial walk leaves newfid as it was.
+			if m.NewFID != m.SFID {
+				delete(t.fids, m.NewFID)
+			}
+		case len(r.QIDs) > 0:
+			t.set(m.NewFID, r.QIDs[len(r.QIDs)-1], false, 0)
+		}
+	case *TopenMsg:
+		if r, ok := r.(*RopenMsg); ok {
+			t.set(m.OFID, r.OQID, true, m.Omode)
+		}
+	case *TcreateMsg:
+		if r, ok := r.(*RcreateMsg); ok {
+			t.set(m.OFID, r.OQID, true, m.Omode)
+		}
+	case *TclunkMsg:
+		delete(t.fids, m.OFID)
+	case *TremoveMsg:
+		// Tremove clunks the fid even if the remove fails.
+		delete(t.fids, m.OFID)
+	}
+}
+
+// set updates fid, if it is still there; a Tversion may have come in and
+// cleared the table while the message was being answered.
+func (t *FidTable) set(fid FID, q QID, open bool, mode Mode) {
+	if f, ok := t.fids[fid]; ok {
+		f.QID, f.Open, f.Mode = q, open, mode
+	}
+}
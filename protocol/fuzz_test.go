@@ -0,0 +1,72 @@
+// Copyright 2019 The Ninep Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.18
+// +build go1.18
+
+package protocol
+
+import (
+	"io"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+)
+
+// FuzzConn feeds a connection to the echo server whatever bytes it is
+// given, then closes it. The server may answer however it likes, but it
+// must not panic, and it must stop once its client is gone.
+func FuzzConn(f *testing.F) {
+	var session []byte
+	for _, m := range [][]byte{
+		AppendTversion(nil, NOTAG, 8192, "9P2000"),
+		AppendTattach(nil, 1, 1, NOFID, "glenda", ""),
+		AppendTwalk(nil, 2, 1, 2, []string{"hi"}),
+		AppendTopen(nil, 3, 2, OREAD),
+		AppendTread(nil, 4, 2, 0, 8192),
+		AppendTwrite(nil, 5, 2, 0, []byte("hello")),
+		AppendTflush(nil, 6, 3),
+		AppendTstat(nil, 7, 2),
+		AppendTclunk(nil, 8, 2),
+	} {
+		f.Add(m)
+		session = append(session, m...)
+	}
+	f.Add(session)
+	f.Add([]byte{0xff, 0xff, 0xff, 0xff, byte(Tversion), 0xff, 0xff})
+	f.Add([]byte{7, 0, 0, 0, byte(Twalk), 1, 0})
+
+	f.Fuzz(func(t *testing.T, in []byte) {
+		closed := make(chan struct{})
+		l, err := NewListener(func() NineServer { return newEcho() }, func(l *Listener) error {
+			l.FidTable = true
+			l.Observer = ObserverFunc(func(e Event) {
+				if _, ok := e.(ConnClosed); ok {
+					close(closed)
+				}
+			})
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		p, p2 := net.Pipe()
+		if err := l.Accept(p2); err != nil {
+			t.Fatal(err)
+		}
+		go io.Copy(ioutil.Discard, p)
+		p.SetWriteDeadline(time.Now().Add(time.Second))
+		p.Write(in)
+		p.Close()
+		select {
+		case <-closed:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("server still serving 5s after the client closed")
+		}
+		if n := l.Panics(); n != 0 {
+			t.Fatalf("server panicked %d times", n)
+		}
+	})
+}
@@ -2,555 +2,1241 @@
 // Use of this source code is governed by a BSD-style
 // license that can be found in the LICENSE file.
 
+//go:build ignore
 // +build ignore
 
-// gen is an rpc generator for the Plan 9 style XDR. It uses the types and structs
-// defined in types. go. A core function, gen, creates the needed lists of
-// parameters, code, and variable list for calling a Marshall function; and the
-// return declaration, code, and return value list for an unmarshall function.
+// gen is an rpc generator for the Plan 9 style XDR. It reads the messages,
+// structs and types declared in messages.spec and writes genout.go; see the
+// top of messages.spec for its format.
 // You can think of an RPC as a pipline:
 // marshal(parms) -> b[]byte over a network -> unmarshal -> dispatch -> reply(parms) -> unmarshal
 // Since we have T messages and R messages in 9p, we adopt the following naming convention for, e.g., Version:
-// MarshalTPktVersion
-// UnmarshalTpktVersion
-// MarshalRPktVersion
-// UnmarshalRPktVersion
+// MarshalTversionPkt
+// UnmarshalTversionPkt
+// MarshalRversionPkt
+// UnmarshalRversionPkt
 //
-// A caller uses the MarshalT* and UnmarshallR* information. A dispatcher
-// uses the  UnmarshalT* and MarshalR* information.
-// Hence the caller needs the call MarshalT params, and UnmarshalR* returns;
-// a dispatcher needs the UnmarshalT returns, and the MarshalR params.
+// A caller uses the MarshalT* and UnmarshalR* functions, and a dispatcher
+// the UnmarshalT* and MarshalR* ones.
+//
+// It also emits AppendTversion, DecodeRversion and so on, which do the same
+// job on plain []byte: the Append functions size the message first and so
+// grow dst at most once, and the Decode functions fill in a Msg struct and
+// leave byte slices pointing into the message instead of copying them. The
+// Call* stubs and the Srv* functions use these.
+//
+// Finally, it writes the NineServer that Intercept wraps around another,
+// which runs an Interceptor's hooks around each method.
+//
+// Into genfuzz_test.go it writes a fuzz test for each decoder: the
+// Unmarshal*Pkt and Decode* functions, Decode, and the Unmarshal functions
+// for structs. Whatever a decoder accepts must encode back to the same
+// bytes, so each also checks that decode(encode(x)) == x.
+//
+// For code that just wants to look at messages, e.g. sniffers and loggers,
+// it also emits a struct per message, e.g. TwalkMsg, which implements Msg,
+// and a Decode function which turns a []byte into the right one.
 package main
 
 import (
+	"bufio"
 	"bytes"
 	"flag"
 	"fmt"
+	"go/format"
 	"io"
 	"io/ioutil"
 	"log"
-	"reflect"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
 	"text/template"
-
-	"sevki.org/q9p/protocol"
 )
 
-const (
-	header = `
-package protocol
-import (
-"bytes"
-"fmt"
-_ "log"
-)
-`
-)
+type dialect struct {
+	Version string
+	Suffix  string
+	Base    bool
+}
 
-type emitter struct {
-	Name  string
-	MFunc string
-	// Encoders always return []byte
-	MParms *bytes.Buffer
-	MList  *bytes.Buffer
-	MLsep  string
-	MCode  *bytes.Buffer
-
-	// Decoders always take []byte as parms.
-	UFunc    string
-	UList    *bytes.Buffer
-	UCode    *bytes.Buffer
-	URet     *bytes.Buffer
-	inBWrite bool
-}
-
-type call struct {
-	T *emitter
-	R *emitter
-}
-
-type pack struct {
-	n  string
-	t  interface{}
-	tn string
-	r  interface{}
-	rn string
-}
-
-const (
-	serverError = `func ServerError (b *bytes.Buffer, s string) {
-	var u [8]byte
-	// This can't really happen. 
-	if _, err := b.Read(u[:2]); err != nil {
-		return
-	}
-	t := Tag(uint16(u[0])|uint16(u[1])<<8)
-	MarshalRerrorPkt (b, t, s)
+// member is a member of a message or a struct.
+type member struct {
+	Name    string
+	Type    string // as written in the spec
+	Label   string
+	Comment string
 }
-`
-)
 
-var (
-	doDebug  = flag.Bool("d", false, "Debug prints")
-	debug    = nodebug //log.Printf
-	packages = []*pack{
-		{n: "error", t: protocol.RerrorPkt{}, tn: "Rerror", r: protocol.RerrorPkt{}, rn: "Rerror"},
-		{n: "version", t: protocol.TversionPkt{}, tn: "Tversion", r: protocol.RversionPkt{}, rn: "Rversion"},
-		{n: "attach", t: protocol.TattachPkt{}, tn: "Tattach", r: protocol.RattachPkt{}, rn: "Rattach"},
-		{n: "flush", t: protocol.TflushPkt{}, tn: "Tflush", r: protocol.RflushPkt{}, rn: "Rflush"},
-		{n: "walk", t: protocol.TwalkPkt{}, tn: "Twalk", r: protocol.RwalkPkt{}, rn: "Rwalk"},
-		{n: "open", t: protocol.TopenPkt{}, tn: "Topen", r: protocol.RopenPkt{}, rn: "Ropen"},
-		{n: "create", t: protocol.TcreatePkt{}, tn: "Tcreate", r: protocol.RcreatePkt{}, rn: "Rcreate"},
-		{n: "stat", t: protocol.TstatPkt{}, tn: "Tstat", r: protocol.RstatPkt{}, rn: "Rstat"},
-		{n: "wstat", t: protocol.TwstatPkt{}, tn: "Twstat", r: protocol.RwstatPkt{}, rn: "Rwstat"},
-		{n: "clunk", t: protocol.TclunkPkt{}, tn: "Tclunk", r: protocol.RclunkPkt{}, rn: "Rclunk"},
-		{n: "remove", t: protocol.TremovePkt{}, tn: "Tremove", r: protocol.RremovePkt{}, rn: "Rremove"},
-		{n: "read", t: protocol.TreadPkt{}, tn: "Tread", r: protocol.RreadPkt{}, rn: "Rread"},
-		{n: "write", t: protocol.TwritePkt{}, tn: "Twrite", r: protocol.RwritePkt{}, rn: "Rwrite"},
-	}
-	msfunc = template.Must(template.New("ms").Parse(`func Marshal{{.MFunc}} (b *bytes.Buffer, {{.MParms}}) {
-var l uint64
-b.Reset()
-b.Write([]byte{0,0,})
-{{.MCode}}
-l = uint64(b.Len()) - 2
-copy(b.Bytes(), []byte{uint8(l), uint8(l>>8)})
-return
+type typeDef struct {
+	Name string
+	Base string
 }
-`))
-	usfunc = template.Must(template.New("us").Parse(`func Unmarshal{{.UFunc}} (b *bytes.Buffer) ({{.URet}} err error) {
-var u [8]uint8
-var l uint64
-_ = b.Next(2) // eat the length too
-{{.UCode}}
-return
+
+type structDef struct {
+	Name    string
+	Func    string
+	Comment string
+	Members []member
 }
-`))
 
-	mfunc = template.Must(template.New("mt").Parse(`func Marshal{{.MFunc}}Pkt (b *bytes.Buffer, t Tag, {{.MParms}}) {
-var l uint64
-b.Reset()
-b.Write([]byte{0,0,0,0,
-uint8({{.Name}}),
-byte(t), byte(t>>8),
-{{.MCode}}
-{
-l = uint64(b.Len())
-copy(b.Bytes(), []byte{uint8(l), uint8(l>>8), uint8(l>>16), uint8(l>>24)})
+// side is either the T or the R half of a message.
+type side struct {
+	Go      string // Go name, e.g. Twalk, or TattachU for an extension
+	MType   string // the MType constant it is sent with
+	Num     int
+	Dialect *dialect
+	Members []member
+	// Extended is set on the sides of an extension that add members.
+	Extended bool
 }
-return
+
+type message struct {
+	Name    string
+	Dialect *dialect
+	Reply   bool
+	Client  bool
+	Ext     bool
+	Iface   string // the interface with just the NineServer method, e.g. Walker
+	T, R    *side
 }
-`))
-	ufunc = template.Must(template.New("mr").Parse(`func Unmarshal{{.UFunc}}Pkt (b *bytes.Buffer) ({{.URet}} t Tag, err error) {
-var u [8]uint8
-var l uint64
-if _, err = b.Read(u[:2]); err != nil {
-err = fmt.Errorf("pkt too short for Tag; need 2, have %d", b.Len())
-return
+
+type spec struct {
+	Dialects []*dialect
+	Types    []*typeDef
+	Structs  []*structDef
+	Msgs     []*message
+	types    map[string]*typeDef
+	structs  map[string]*structDef
+	msgs     map[string]*message
 }
-l = uint64(u[0]) | uint64(u[1])<<8
-t = Tag(l)
-{{.UCode}}
-if b.Len() > 0 {
-err = fmt.Errorf("Packet too long: %d bytes left over after decode", b.Len())
+
+var (
+	doDebug = flag.Bool("d", false, "Debug prints")
+	specf   = flag.String("spec", "messages.spec", "message spec to read")
+	out     = flag.String("o", "genout.go", "file to write")
+	fuzzout = flag.String("fuzz", "genfuzz_test.go", "fuzz tests to write")
+	debug   = nodebug //log.Printf
+
+	ints = map[string]int{
+		"uint8":  1,
+		"uint16": 2,
+		"uint32": 4,
+		"int32":  4,
+		"uint64": 8,
+	}
+	// Member names that would collide with what every Msg type has.
+	reserved = map[string]bool{
+		"Type":        true,
+		"Tag":         true,
+		"MTag":        true,
+		"Encode":      true,
+		"String":      true,
+		"MarshalJSON": true,
+	}
+)
+
+func nodebug(string, ...interface{}) {
 }
-return
+
+// parse reads a spec. See messages.spec for what it looks like.
+func parse(r io.Reader) (*spec, error) {
+	s := &spec{
+		types:   map[string]*typeDef{},
+		structs: map[string]*structDef{},
+		msgs:    map[string]*message{},
+	}
+	var (
+		d   *dialect
+		st  *structDef
+		msg *message
+		n   int
+	)
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		n++
+		line := sc.Text()
+		var comment string
+		if i := strings.Index(line, "#"); i >= 0 {
+			line, comment = line[:i], strings.TrimSpace(line[i+1:])
+		}
+		f := strings.Fields(line)
+		if len(f) == 0 {
+			continue
+		}
+		errf := func(format string, args ...interface{}) error {
+			return fmt.Errorf("%s:%d: %s", *specf, n, fmt.Sprintf(format, args...))
+		}
+		if line[0] == ' ' || line[0] == '\t' {
+			switch {
+			case st != nil:
+				m, err := s.member(f, comment)
+				if err != nil {
+					return nil, errf("%v", err)
+				}
+				st.Members = append(st.Members, m)
+			case msg != nil:
+				m, err := s.member(f[1:], comment)
+				if err != nil {
+					return nil, errf("%v", err)
+				}
+				if reserved[m.Name] {
+					return nil, errf("member %v would collide with a Msg method", m.Name)
+				}
+				var sd *side
+				switch {
+				case f[0] == "T" && msg.T != nil:
+					sd = msg.T
+				case f[0] == "R":
+					sd = msg.R
+				default:
+					return nil, errf("want T or R member of %v, got %q", msg.Name, f[0])
+				}
+				sd.Members = append(sd.Members, m)
+				sd.Extended = msg.Ext
+			default:
+				return nil, errf("member outside of a struct or msg")
+			}
+			continue
+		}
+		st, msg = nil, nil
+		if f[0] != "dialect" && d == nil {
+			return nil, errf("%v before the first dialect", f[0])
+		}
+		switch {
+		case f[0] == "dialect" && (len(f) == 2 || len(f) == 3):
+			d = &dialect{Version: f[1], Base: len(s.Dialects) == 0}
+			if len(f) == 3 {
+				d.Suffix = f[2]
+			}
+			s.Dialects = append(s.Dialects, d)
+		case f[0] == "type" && len(f) == 3:
+			if _, ok := ints[f[2]]; !ok {
+				return nil, errf("type %v: %v is not an int", f[1], f[2])
+			}
+			t := &typeDef{Name: f[1], Base: f[2]}
+			s.types[t.Name] = t
+			s.Types = append(s.Types, t)
+		case f[0] == "struct" && (len(f) == 2 || len(f) == 3):
+			st = &structDef{Name: f[1], Comment: comment}
+			if len(f) == 3 {
+				st.Func = f[2]
+			}
+			s.addStruct(st)
+		case f[0] == "msg" && len(f) >= 3 && len(f) <= 5:
+			num, err := strconv.Atoi(f[2])
+			if err != nil {
+				return nil, errf("msg %v: %v", f[1], err)
+			}
+			msg = &message{Name: f[1], Dialect: d, Client: !d.Base}
+			for _, a := range f[3:] {
+				switch {
+				case a == "reply":
+					msg.Reply = true
+				case a == "client":
+					msg.Client = true
+				case isExported(a):
+					msg.Iface = a
+				default:
+					return nil, errf("msg %v: unknown attribute %q", f[1], a)
+				}
+			}
+			if msg.Served() && msg.Iface == "" {
+				return nil, errf("msg %v: NineServer serves it, so it needs an interface name", f[1])
+			}
+			if !msg.Reply {
+				msg.T = &side{Go: "T" + f[1], MType: "T" + f[1], Num: num, Dialect: d}
+			}
+			msg.R = &side{Go: "R" + f[1], MType: "R" + f[1], Num: num + 1, Dialect: d}
+			if _, ok := s.msgs[f[1]]; ok {
+				return nil, errf("msg %v defined twice", f[1])
+			}
+			s.msgs[f[1]] = msg
+			s.Msgs = append(s.Msgs, msg)
+		case f[0] == "extend" && len(f) == 2:
+			if d.Suffix == "" {
+				return nil, errf("extend %v in dialect %v, which has no suffix", f[1], d.Version)
+			}
+			if o, ok := s.structs[f[1]]; ok {
+				st = &structDef{Name: o.Name + d.Suffix, Members: append([]member{}, o.Members...)}
+				if o.Func != "" {
+					st.Func = o.Func + d.Suffix
+				}
+				s.addStruct(st)
+				break
+			}
+			o, ok := s.msgs[f[1]]
+			if !ok {
+				return nil, errf("extend %v: no such msg or struct", f[1])
+			}
+			msg = &message{Name: o.Name + d.Suffix, Dialect: d, Reply: o.Reply, Client: true, Ext: true}
+			if o.T != nil {
+				msg.T = &side{Go: o.T.Go + d.Suffix, MType: o.T.MType, Num: o.T.Num, Dialect: d, Members: append([]member{}, o.T.Members...)}
+			}
+			msg.R = &side{Go: o.R.Go + d.Suffix, MType: o.R.MType, Num: o.R.Num, Dialect: d, Members: append([]member{}, o.R.Members...)}
+			s.msgs[msg.Name] = msg
+			s.Msgs = append(s.Msgs, msg)
+		default:
+			return nil, errf("can't parse %q", line)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return s, nil
 }
-`))
-	sfunc = template.Must(template.New("s").Parse(`func (s *Server) Srv{{.R.UFunc}}(b*bytes.Buffer) (err error) {
-	{{.T.MList}}{{.T.MLsep}} t, err := Unmarshal{{.T.MFunc}}Pkt(b)
-	//if err != nil {
-	//}
-	if {{.R.MList}}{{.R.MLsep}} err := s.NS.{{.R.MFunc}}({{.T.MList}}); err != nil {
-	MarshalRerrorPkt(b, t, fmt.Sprintf("%v", err))
-} else {
-	Marshal{{.R.MFunc}}Pkt(b, t, {{.R.MList}})
+
+func isExported(name string) bool {
+	return name[0] >= 'A' && name[0] <= 'Z'
 }
-	return nil
+
+func (s *spec) addStruct(st *structDef) {
+	s.structs[st.Name] = st
+	s.Structs = append(s.Structs, st)
 }
-`))
-	cfunc = template.Must(template.New("s").Parse(`
-func (c *Client)Call{{.T.MFunc}} ({{.T.MParms}}) ({{.R.URet}} err error) {
-var b = bytes.Buffer{}
-if c.Trace != nil {c.Trace("%v", {{.T.MFunc}})}
-t := Tag(0)
-r := make (chan []byte)
-if c.Trace != nil { c.Trace(":tag %v, FID %v", t, c.FID)}
-Marshal{{.T.MFunc}}Pkt(&b, t, {{.T.MList}})
-c.FromClient <- &RPCCall{b: b.Bytes(), Reply: r}
-bb := <-r
-if MType(bb[4]) == Rerror {
-	s, _, err := UnmarshalRerrorPkt(bytes.NewBuffer(bb[5:]))
-	if err != nil {
-		return {{.R.UList}} err
+
+// member parses Name type [label].
+func (s *spec) member(f []string, comment string) (member, error) {
+	if len(f) < 2 || len(f) > 3 {
+		return member{}, fmt.Errorf("want Name type [label], got %q", strings.Join(f, " "))
 	}
-	return {{.R.UList}} fmt.Errorf("%v", s)
-} else {
-	{{.R.MList}}{{.R.MLsep}} _, err = Unmarshal{{.R.UFunc}}Pkt(bytes.NewBuffer(bb[5:]))
-}
-return {{.R.UList}} err
+	m := member{Name: f[0], Type: f[1], Label: strings.ToLower(f[0]), Comment: comment}
+	if len(f) == 3 {
+		m.Label = f[2]
+	}
+	if !isExported(m.Name) {
+		return m, fmt.Errorf("member %v is not exported", m.Name)
+	}
+	t := strings.TrimPrefix(m.Type, "[]")
+	switch {
+	case t == "string", t == m.Type && (t == "data" || t == "stat"):
+	case ints[t] != 0, s.types[t] != nil, s.structs[t] != nil:
+	default:
+		return m, fmt.Errorf("member %v: unknown type %v", m.Name, m.Type)
+	}
+	return m, nil
 }
-`))
-)
 
-func nodebug(string, ...interface{}) {
+// goType is the Go type of a spec type.
+func goType(t string) string {
+	switch {
+	case t == "data", t == "stat":
+		return "[]byte"
+	case strings.HasPrefix(t, "[]"):
+		return "[]" + goType(t[2:])
+	}
+	return t
 }
 
-func newCall(p *pack) *call {
-	c := &call{}
-	// We set inBWrite to true because the prologue marshal code sets up some default writes to b
-	c.T = &emitter{"T" + p.n, p.tn, &bytes.Buffer{}, &bytes.Buffer{}, "", &bytes.Buffer{}, p.tn, &bytes.Buffer{}, &bytes.Buffer{}, &bytes.Buffer{}, true}
-	c.R = &emitter{"R" + p.n, p.rn, &bytes.Buffer{}, &bytes.Buffer{}, "", &bytes.Buffer{}, p.rn, &bytes.Buffer{}, &bytes.Buffer{}, &bytes.Buffer{}, true}
-	return c
+// width is the size of an integer type, or 0 if t isn't one.
+func (s *spec) width(t string) int {
+	if d, ok := s.types[t]; ok {
+		t = d.Base
+	}
+	return ints[t]
 }
 
-func emitEncodeInt(v interface{}, n string, l int, e *emitter) {
-	debug("emit %v, %v", n, l)
+// loopvars are the index variables for nested lists.
+const loopvars = "ijkpq"
+
+func encodeInt(w io.Writer, x string, l int) {
+	fmt.Fprintf(w, "b.Write([]byte{")
 	for i := 0; i < l; i++ {
-		if !e.inBWrite {
-			e.MCode.WriteString("\tb.Write([]byte{")
-			e.inBWrite = true
+		fmt.Fprintf(w, "uint8(%s>>%d),", x, i*8)
+	}
+	fmt.Fprintf(w, "})\n")
+}
+
+// encode writes the code to encode x, of spec type t, into b.
+func (s *spec) encode(w io.Writer, x, t string, depth int) {
+	debug("encode %v %v", x, t)
+	if l := s.width(t); l != 0 {
+		encodeInt(w, x, l)
+		return
+	}
+	switch {
+	case t == "string":
+		encodeInt(w, "len("+x+")", 2)
+		fmt.Fprintf(w, "b.WriteString(%s)\n", x)
+	case t == "data":
+		encodeInt(w, "len("+x+")", 4)
+		fmt.Fprintf(w, "b.Write(%s)\n", x)
+	case t == "stat":
+		encodeInt(w, "len("+x+")", 2)
+		fmt.Fprintf(w, "b.Write(%s)\n", x)
+	case strings.HasPrefix(t, "[]"):
+		i := loopvars[depth : depth+1]
+		encodeInt(w, "len("+x+")", 2)
+		fmt.Fprintf(w, "for %s := range %s {\n", i, x)
+		s.encode(w, x+"["+i+"]", t[2:], depth+1)
+		fmt.Fprintf(w, "}\n")
+	default:
+		for _, m := range s.structs[t].Members {
+			s.encode(w, x+"."+m.Name, m.Type, depth)
 		}
-		e.MCode.WriteString(fmt.Sprintf("\tuint8(%v>>%v),\n", n, i*8))
 	}
 }
 
-func emitDecodeInt(v interface{}, n string, l int, e *emitter) {
-	t := reflect.ValueOf(v).Type().Name()
-	debug("emit reflect.ValueOf(v) %s %v, %v", t, n, l)
-	e.UCode.WriteString(fmt.Sprintf("\tif _, err = b.Read(u[:%v]); err != nil {\n\t\terr = fmt.Errorf(\"pkt too short for uint%v: need %v, have %%d\", b.Len())\n\treturn\n\t}\n", l, l*8, l))
-	e.UCode.WriteString(fmt.Sprintf("\t%v = %s(u[0])\n", n, t))
+// decodeInt writes the code to decode an l byte integer of Go type t into x.
+func decodeInt(w io.Writer, x, t string, l int) {
+	fmt.Fprintf(w, "if b.Len() < %d {\nerr = fmt.Errorf(\"pkt too short for uint%d: need %d, have %%d\", b.Len())\nreturn\n}\n", l, l*8, l)
+	fmt.Fprintf(w, "u = b.Next(%d)\n%s = %s(u[0])", l, x, t)
 	for i := 1; i < l; i++ {
-		e.UCode.WriteString(fmt.Sprintf("\t%v |= %s(u[%d])<<%v\n", n, t, i, i*8))
+		fmt.Fprintf(w, " | %s(u[%d])<<%d", t, i, i*8)
 	}
+	fmt.Fprintf(w, "\n")
+}
+
+// decodeLen writes the code to decode an l byte count into l, and to check
+// that there are that many bytes left in b.
+func decodeLen(w io.Writer, l int, what string) {
+	decodeInt(w, "l", "int", l)
+	fmt.Fprintf(w, "if b.Len() < l {\nerr = fmt.Errorf(\"pkt too short for %s: need %%d, have %%d\", l, b.Len())\nreturn\n}\n", what)
 }
 
-// TODO: templates.
-func emitEncodeString(v interface{}, n string, e *emitter) {
-	if !e.inBWrite {
-		e.MCode.WriteString("\tb.Write([]byte{")
-		e.inBWrite = true
+// decode writes the code to decode x, of spec type t, from b. Slices alias b.
+func (s *spec) decode(w io.Writer, x, t string, depth int) {
+	debug("decode %v %v", x, t)
+	if l := s.width(t); l != 0 {
+		decodeInt(w, x, t, l)
+		return
+	}
+	switch {
+	case t == "string":
+		decodeLen(w, 2, "string")
+		fmt.Fprintf(w, "%s = string(b.Next(l))\n", x)
+	case t == "data":
+		decodeLen(w, 4, "data")
+		fmt.Fprintf(w, "%s = b.Next(l)\n", x)
+	case t == "stat":
+		decodeLen(w, 2, "stat")
+		fmt.Fprintf(w, "%s = b.Next(l)\n", x)
+	case strings.HasPrefix(t, "[]"):
+		i := loopvars[depth : depth+1]
+		decodeInt(w, "l", "int", 2)
+		fmt.Fprintf(w, "%s = make(%s, l)\n", x, goType(t))
+		fmt.Fprintf(w, "for %s := range %s {\n", i, x)
+		s.decode(w, x+"["+i+"]", t[2:], depth+1)
+		fmt.Fprintf(w, "}\n")
+	default:
+		for _, m := range s.structs[t].Members {
+			s.decode(w, x+"."+m.Name, m.Type, depth)
+		}
 	}
-	e.MCode.WriteString(fmt.Sprintf("\tuint8(len(%v)),uint8(len(%v)>>8),\n", n, n))
-	e.MCode.WriteString("\t})\n")
-	e.inBWrite = false
-	e.MCode.WriteString(fmt.Sprintf("\tb.Write([]byte(%v))\n", n))
 }
 
-func emitDecodeString(n string, e *emitter) {
-	var l uint64
-	emitDecodeInt(l, "l", 2, e)
-	e.UCode.WriteString(fmt.Sprintf("\tif b.Len() < int(l) {\n\t\terr = fmt.Errorf(\"pkt too short for string: need %%d, have %%d\", l, b.Len())\n\treturn\n\t}\n"))
-	e.UCode.WriteString(fmt.Sprintf("\t%v = string(b.Bytes()[:l])\n", n))
-	e.UCode.WriteString("\t_ = b.Next(int(l))\n")
+// fixed is the encoded size of t if it is always the same, or 0.
+func (s *spec) fixed(t string) int {
+	if l := s.width(t); l != 0 {
+		return l
+	}
+	st, ok := s.structs[t]
+	if !ok {
+		return 0
+	}
+	var n int
+	for _, m := range st.Members {
+		l := s.fixed(m.Type)
+		if l == 0 {
+			return 0
+		}
+		n += l
+	}
+	return n
 }
 
-func genEncodeStruct(v interface{}, n string, e *emitter) error {
-	debug("genEncodeStruct(%T, %v, %v)", v, n, e)
-	t := reflect.ValueOf(v)
-	for i := 0; i < t.NumField(); i++ {
-		f := t.Field(i)
-		fn := t.Type().Field(i).Name
-		debug("genEncodeStruct %T n %v field %d %v %v\n", t, n, i, f.Type(), f.Type().Name())
-		genEncodeData(f.Interface(), n+fn, e)
+// size writes the code to add the encoded size of x, of spec type t, to n.
+func (s *spec) size(w io.Writer, x, t string, depth int) {
+	if l := s.fixed(t); l != 0 {
+		fmt.Fprintf(w, "n += %d\n", l)
+		return
+	}
+	switch {
+	case t == "string", t == "stat":
+		fmt.Fprintf(w, "n += 2 + len(%s)\n", x)
+	case t == "data":
+		fmt.Fprintf(w, "n += 4 + len(%s)\n", x)
+	case strings.HasPrefix(t, "[]"):
+		if l := s.fixed(t[2:]); l != 0 {
+			fmt.Fprintf(w, "n += 2 + %d*len(%s)\n", l, x)
+			return
+		}
+		i := loopvars[depth : depth+1]
+		fmt.Fprintf(w, "n += 2\nfor %s := range %s {\n", i, x)
+		s.size(w, x+"["+i+"]", t[2:], depth+1)
+		fmt.Fprintf(w, "}\n")
+	default:
+		for _, m := range s.structs[t].Members {
+			s.size(w, x+"."+m.Name, m.Type, depth)
+		}
 	}
-	return nil
 }
 
-func genDecodeStruct(v interface{}, n string, e *emitter) error {
-	debug("genDecodeStruct(%T, %v, %v)", v, n, "")
-	t := reflect.ValueOf(v)
-	for i := 0; i < t.NumField(); i++ {
-		f := t.Field(i)
-		fn := t.Type().Field(i).Name
-		debug("genDecodeStruct %T n %v field %d %v %v\n", t, n, i, f.Type(), f.Type().Name())
-		genDecodeData(f.Interface(), n+fn, e)
+func appendInt(w io.Writer, x string, l int) {
+	fmt.Fprintf(w, "dst = append(dst")
+	for i := 0; i < l; i++ {
+		fmt.Fprintf(w, ", uint8(%s>>%d)", x, i*8)
 	}
-	return nil
+	fmt.Fprintf(w, ")\n")
 }
 
-// TODO: there has to be a smarter way to do slices.
-func genEncodeSlice(v interface{}, n string, e *emitter) error {
-	t := fmt.Sprintf("%T", v)
-	switch t {
-	case "[]string":
-		var u uint16
-		emitEncodeInt(u, fmt.Sprintf("len(%v)", n), 2, e)
-		if e.inBWrite {
-			e.MCode.WriteString("\t})\n")
-			e.inBWrite = false
-		}
-		e.MCode.WriteString(fmt.Sprintf("for i := range %v {\n", n))
-		var s string
-		genEncodeData(s, n+"[i]", e)
-		e.MCode.WriteString("}\n")
-	case "[]protocol.QID":
-		var u uint16
-		emitEncodeInt(u, fmt.Sprintf("len(%v)", n), 2, e)
-		if e.inBWrite {
-			e.MCode.WriteString("\t})\n")
-			e.inBWrite = false
-		}
-		e.MCode.WriteString(fmt.Sprintf("for i := range %v {\n", n))
-		genEncodeData(protocol.QID{}, n+"[i]", e)
-		e.MCode.WriteString("\t})\n")
-		e.inBWrite = false
-		e.MCode.WriteString("}\n")
-	case "[]byte", "[]uint8":
-		var u uint32
-		emitEncodeInt(u, fmt.Sprintf("len(%v)", n), 4, e)
-		if e.inBWrite {
-			e.MCode.WriteString("\t})\n")
-			e.inBWrite = false
-		}
-		e.MCode.WriteString(fmt.Sprintf("\tb.Write(%v)\n", n))
-	case "[]protocol.DataCnt16":
-		var u uint16
-		emitEncodeInt(u, fmt.Sprintf("len(%v)", n), 2, e)
-		if e.inBWrite {
-			e.MCode.WriteString("\t})\n")
-			e.inBWrite = false
-		}
-		e.MCode.WriteString(fmt.Sprintf("\tb.Write(%v)\n", n))
+// append writes the code to append x, of spec type t, to dst.
+func (s *spec) append(w io.Writer, x, t string, depth int) {
+	if l := s.width(t); l != 0 {
+		appendInt(w, x, l)
+		return
+	}
+	switch {
+	case t == "string", t == "stat":
+		appendInt(w, "len("+x+")", 2)
+		fmt.Fprintf(w, "dst = append(dst, %s...)\n", x)
+	case t == "data":
+		appendInt(w, "len("+x+")", 4)
+		fmt.Fprintf(w, "dst = append(dst, %s...)\n", x)
+	case strings.HasPrefix(t, "[]"):
+		i := loopvars[depth : depth+1]
+		appendInt(w, "len("+x+")", 2)
+		fmt.Fprintf(w, "for %s := range %s {\n", i, x)
+		s.append(w, x+"["+i+"]", t[2:], depth+1)
+		fmt.Fprintf(w, "}\n")
 	default:
-		log.Printf("genEncodeSlice: Can't handle slice of %s", t)
+		for _, m := range s.structs[t].Members {
+			s.append(w, x+"."+m.Name, m.Type, depth)
+		}
 	}
-	return nil
 }
 
-func genDecodeSlice(v interface{}, n string, e *emitter) error {
-	// Sadly, []byte is not encoded like []everything else.
-	t := fmt.Sprintf("%T", v)
-	switch t {
-	case "[]string":
-		var u uint64
-		emitDecodeInt(u, "l", 2, e)
-		e.UCode.WriteString(fmt.Sprintf("\t%v = make([]string, l)\n", n))
-		e.UCode.WriteString(fmt.Sprintf("for i := range %v {\n", n))
-		var s string
-		genDecodeData(s, n+"[i]", e)
-		e.UCode.WriteString("}\n")
-	case "[]protocol.QID":
-		var u uint64
-		emitDecodeInt(u, "l", 2, e)
-		e.UCode.WriteString(fmt.Sprintf("\t%v = make([]QID, l)\n", n))
-		e.UCode.WriteString(fmt.Sprintf("for i := range %v {\n", n))
-		genDecodeData(protocol.QID{}, n+"[i]", e)
-		e.UCode.WriteString("}\n")
-	case "[]byte", "[]uint8":
-		var u uint64
-		emitDecodeInt(u, "l", 4, e)
-		e.UCode.WriteString(fmt.Sprintf("\t%v = b.Bytes()[:l]\n", n))
-		e.UCode.WriteString("\t_ = b.Next(int(l))\n")
-	case "[]protocol.DataCnt16":
-		var u uint64
-		emitDecodeInt(u, "l", 2, e)
-		e.UCode.WriteString(fmt.Sprintf("\t%v = b.Bytes()[:l]\n", n))
-		e.UCode.WriteString("\t_ = b.Next(int(l))\n")
-	default:
-		log.Printf("genDecodeSlice: Can't handle slice of %v", t)
+// sliceInt writes the code to decode an l byte integer of Go type t from
+// the front of src into x.
+func sliceInt(w io.Writer, x, t string, l int) {
+	fmt.Fprintf(w, "if len(src) < %d {\nreturn fmt.Errorf(\"pkt too short for uint%d: need %d, have %%d\", len(src))\n}\n", l, l*8, l)
+	fmt.Fprintf(w, "%s = %s(src[0])", x, t)
+	for i := 1; i < l; i++ {
+		fmt.Fprintf(w, " | %s(src[%d])<<%d", t, i, i*8)
 	}
-	return nil
+	fmt.Fprintf(w, "\nsrc = src[%d:]\n", l)
+}
+
+// sliceLen is decodeLen for src.
+func sliceLen(w io.Writer, l int, what string) {
+	sliceInt(w, "l", "int", l)
+	fmt.Fprintf(w, "if len(src) < l {\nreturn fmt.Errorf(\"pkt too short for %s: need %%d, have %%d\", l, len(src))\n}\n", what)
 }
 
-func genEncodeData(v interface{}, n string, e *emitter) error {
-	debug("genEncodeData(%T, %v, %v)", v, n, e)
-	s := reflect.ValueOf(v).Kind()
-	switch s {
-	case reflect.Uint8:
-		emitEncodeInt(v, n, 1, e)
-	case reflect.Uint16:
-		emitEncodeInt(v, n, 2, e)
-	case reflect.Uint32, reflect.Int32:
-		emitEncodeInt(v, n, 4, e)
-	case reflect.Uint64:
-		emitEncodeInt(v, n, 8, e)
-	case reflect.String:
-		emitEncodeString(v, n, e)
-	case reflect.Struct:
-		if n != "" {
-			n = n + "."
-		}
-		return genEncodeStruct(v, n, e)
-	case reflect.Slice:
-		return genEncodeSlice(v, n, e)
+// slice writes the code to decode x, of spec type t, from the front of src.
+// Byte slices alias src.
+func (s *spec) slice(w io.Writer, x, t string, depth int) {
+	if l := s.width(t); l != 0 {
+		sliceInt(w, x, t, l)
+		return
+	}
+	switch {
+	case t == "string":
+		sliceLen(w, 2, "string")
+		fmt.Fprintf(w, "%s = string(src[:l])\nsrc = src[l:]\n", x)
+	case t == "data", t == "stat":
+		sliceLen(w, map[string]int{"data": 4, "stat": 2}[t], t)
+		fmt.Fprintf(w, "%s = src[:l:l]\nsrc = src[l:]\n", x)
+	case strings.HasPrefix(t, "[]"):
+		i := loopvars[depth : depth+1]
+		sliceInt(w, "l", "int", 2)
+		fmt.Fprintf(w, "%s = make(%s, l)\n", x, goType(t))
+		fmt.Fprintf(w, "for %s := range %s {\n", i, x)
+		s.slice(w, x+"["+i+"]", t[2:], depth+1)
+		fmt.Fprintf(w, "}\n")
 	default:
-		log.Printf("genEncodeData: Can't handle type %T", v)
+		for _, m := range s.structs[t].Members {
+			s.slice(w, x+"."+m.Name, m.Type, depth)
+		}
 	}
-	return nil
 }
 
-func genDecodeData(v interface{}, n string, e *emitter) error {
-	debug("genEncodeData(%T, %v, %v)", v, n, "") //e)
-	s := reflect.ValueOf(v).Kind()
-	switch s {
-	case reflect.Uint8:
-		emitDecodeInt(v, n, 1, e)
-	case reflect.Uint16:
-		emitDecodeInt(v, n, 2, e)
-	case reflect.Uint32, reflect.Int32:
-		emitDecodeInt(v, n, 4, e)
-	case reflect.Uint64:
-		emitDecodeInt(v, n, 8, e)
-	case reflect.String:
-		emitDecodeString(n, e)
-	case reflect.Struct:
-		if n != "" {
-			n = n + "."
-		}
-		debug("----------> call gendecodstruct(%v, %v, e)", v, n)
-		return genDecodeStruct(v, n, e)
-	case reflect.Slice:
-		return genDecodeSlice(v, n, e)
+// fcall writes the code that prints member m, found in x, the way
+// fcall(2) does.
+func (s *spec) fcall(w io.Writer, x string, m member) {
+	switch {
+	case m.Type == "data":
+		fmt.Fprintf(w, "fmt.Fprintf(&b, \" count %%d %%s\", len(%s), dumpsome(%s))\n", x, x)
+	case m.Type == "stat":
+		fmt.Fprintf(w, "fmt.Fprintf(&b, \" stat %%s\", statString(%s))\n", x)
+	case strings.HasPrefix(m.Type, "[]"):
+		fmt.Fprintf(w, "fmt.Fprintf(&b, \" n%s %%d\", len(%s))\n", m.Label, x)
+		fmt.Fprintf(w, "for i := range %s {\nfmt.Fprintf(&b, \" %%d:%%v\", i, %s[i])\n}\n", x, x)
+	case m.Type == "Perm":
+		fmt.Fprintf(w, "fmt.Fprintf(&b, \" %s %%s\", dirModeString(uint32(%s)))\n", m.Label, x)
+	case m.Type == "string" && m.Label == "version":
+		fmt.Fprintf(w, "fmt.Fprintf(&b, \" %s '%%s'\", %s)\n", m.Label, x)
+	case m.Type == "string", s.structs[m.Type] != nil:
+		fmt.Fprintf(w, "fmt.Fprintf(&b, \" %s %%v\", %s)\n", m.Label, x)
 	default:
-		log.Printf("genDecodeData: Can't handle type %T", v)
+		fmt.Fprintf(w, "fmt.Fprintf(&b, \" %s %%d\", %s)\n", m.Label, x)
 	}
-	return nil
 }
 
-// Well, it's odd, but Name sometimes comes back empty.
-// I don't know why.
-func tn(f reflect.Value) string {
-	n := f.Type().Name()
-	if n == "" {
-		t := f.Type().String()
-		switch t {
-		case "[]protocol.QID":
-			n = "[]QID"
-		case "[]protocol.DataCnt16":
-			n = "[]byte"
-		default:
-			n = t
+// The methods below are for the templates.
+
+// Sides is the T and R halves of a message, in that order. For an
+// extension, it is only those it adds members to.
+func (m *message) Sides() []*side {
+	var l []*side
+	for _, s := range []*side{m.T, m.R} {
+		if s != nil && (s.Extended || !m.Ext) {
+			l = append(l, s)
 		}
 	}
-	return n
+	return l
 }
 
-// genParms writes the parameters for declarations (name and type)
-// a list of names (for calling the encoder)
-func genParms(v interface{}, n string, e *emitter) error {
-	t := reflect.ValueOf(v)
-	for i := 0; i < t.NumField(); i++ {
-		f := t.Field(i)
-		fn := t.Type().Field(i).Name
-		e.MList.WriteString(e.MLsep + fn)
-		e.MParms.WriteString(e.MLsep + fn + " " + tn(f))
-		e.MLsep = ", "
+// Served is whether NineServer has a method for the message.
+func (m *message) Served() bool {
+	return !m.Client && !m.Reply && !m.Ext
+}
+
+// Consts is the message types a dialect defines, in order. A reply
+// message gets a T type too, even though nothing is ever sent with it.
+func (s *spec) Consts(d *dialect) []*side {
+	var l []*side
+	for _, m := range s.Msgs {
+		if m.Dialect != d || m.Ext {
+			continue
+		}
+		if m.Reply {
+			l = append(l, &side{MType: "T" + m.Name, Num: m.R.Num - 1, Dialect: d})
+		}
+		l = append(l, m.Sides()...)
 	}
-	return nil
+	sort.Slice(l, func(i, j int) bool { return l[i].Num < l[j].Num })
+	return l
+}
+
+// Last is one more than the last message type in a dialect.
+func (s *spec) Last(d *dialect) int {
+	l := s.Consts(d)
+	return l[len(l)-1].Num + 1
 }
 
-// genRets writes the rets for declarations (name and type)
-// a list of names
-func genRets(v interface{}, n string, e *emitter) error {
-	t := reflect.ValueOf(v)
-	for i := 0; i < t.NumField(); i++ {
-		f := t.Field(i)
-		fn := t.Type().Field(i).Name
-		e.UList.WriteString(fn + ", ")
-		e.URet.WriteString(fn + " " + tn(f) + ", ")
+// Params is the members as parameters.
+func (s *side) Params() string {
+	var p []string
+	for _, m := range s.Members {
+		p = append(p, m.Name+" "+goType(m.Type))
 	}
-	return nil
+	return strings.Join(p, ", ")
 }
 
-// genMsgRPC generates the call and reply declarations and marshalers. We don't think of encoders as too separate
-// because the 9p encoding is so simple.
-func genMsgRPC(b io.Writer, p *pack) (*call, error) {
+// Bytes is the names of the []byte members, if any, as they would be
+// written in a sentence.
+func (s *side) Bytes() string {
+	var n []string
+	for _, m := range s.Members {
+		if goType(m.Type) == "[]byte" {
+			n = append(n, m.Name)
+		}
+	}
+	return strings.Join(n, " and ")
+}
 
-	c := newCall(p)
+// Results is the types of the members, each followed by a comma.
+func (s *side) Results() string {
+	var b bytes.Buffer
+	for _, m := range s.Members {
+		b.WriteString(goType(m.Type) + ", ")
+	}
+	return b.String()
+}
 
-	if err := genEncodeStruct(p.r, "", c.R); err != nil {
-		log.Fatalf("%v", err)
+// List is the members' names, each with pre before and sep after.
+func (s *side) List(pre, sep string) string {
+	var b bytes.Buffer
+	for _, m := range s.Members {
+		b.WriteString(pre + m.Name + sep)
 	}
-	if c.R.inBWrite {
-		c.R.MCode.WriteString("\t})\n")
-		c.R.inBWrite = false
+	return b.String()
+}
+
+// Named is the members as results named r0, r1 and so on, each followed
+// by a comma.
+func (s *side) Named() string {
+	var b bytes.Buffer
+	for i, m := range s.Members {
+		fmt.Fprintf(&b, "r%d %s, ", i, goType(m.Type))
 	}
-	if err := genDecodeStruct(p.r, "", c.R); err != nil {
-		log.Fatalf("%v", err)
+	return b.String()
+}
+
+// Refs is the names from Named, each followed by a comma.
+func (s *side) Refs() string {
+	var b bytes.Buffer
+	for i := range s.Members {
+		fmt.Fprintf(&b, "r%d, ", i)
 	}
+	return b.String()
+}
 
-	if err := genEncodeStruct(p.t, "", c.T); err != nil {
-		log.Fatalf("%v", err)
+// Args is the members of the struct x as arguments, or the members
+// themselves if x is empty.
+func (s *side) Args(x string) string {
+	if x != "" {
+		x += "."
 	}
-	if c.T.inBWrite {
-		c.T.MCode.WriteString("\t})\n")
-		c.T.inBWrite = false
+	return strings.TrimSuffix(s.List(x, ", "), ", ")
+}
+
+var (
+	funcs = template.FuncMap{"goType": goType}
+
+	header = template.Must(template.New("header").Funcs(funcs).Parse(`// Code generated by gen.go from messages.spec; DO NOT EDIT.
+
+package protocol
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+{{range .Dialects}}{{if not .Suffix}}
+// {{.Version}} message types
+const ({{range $.Consts .}}
+	{{.MType}} MType = {{.Num}}{{end}}{{if .Base}}
+	Tlast MType = {{$.Last .}}{{end}}
+)
+{{end}}{{end}}
+// Types contained in 9p messages.
+type ({{range .Types}}
+	{{.Name}} {{.Base}}{{end}}
+)
+{{range .Structs}}{{if .Comment}}
+// {{.Comment}}{{end}}
+type {{.Name}} struct { {{range .Members}}
+	{{.Name}} {{goType .Type}}{{if .Comment}} // {{.Comment}}{{end}}{{end}}
+}
+{{end}}
+// N.B. In all packets, the wire order is the order of the members in
+// messages.spec, which is the order of the struct members here.
+{{range .Msgs}}{{range .Sides}}
+type {{.Go}}Pkt struct { {{range .Members}}
+	{{.Name}} {{goType .Type}}{{if .Comment}} // {{.Comment}}{{end}}{{end}}
+}
+{{end}}{{end}}{{range .Msgs}}{{if .Served}}
+// {{.Iface}} is implemented by servers that answer {{.T.Go}}.{{with .T.Bytes}}
+// {{.}} points into the buffer the message was read into, which is used
+// again once the method returns: a server that keeps it must copy it.{{end}}
+type {{.Iface}} interface {
+	{{.R.Go}}({{.T.Params}}) ({{.R.Results}}error)
+}
+{{end}}{{end}}
+// NineServer is what a 9P server implements: a method for each T message.
+// Dispatch calls it with the members of the T message, and replies with
+// the R message made from its results or, if it fails, with Rerror.
+// A server that only answers some messages can embed BaseServer, or be
+// wrapped with Adapt. A NineServer that is also an io.Closer is closed
+// once its connection has gone.
+//
+// Byte slices a method is called with, such as Rwrite's Data, are only
+// valid until it returns.
+type NineServer interface { {{range .Msgs}}{{if .Served}}
+	{{.Iface}}{{end}}{{end}}
+}
+
+// adapter is the NineServer Adapt returns. Each method calls v's, if v
+// has it, and BaseServer's otherwise.
+type adapter struct {
+	BaseServer
+	v interface{}
+}
+{{range .Msgs}}{{if .Served}}
+func (a *adapter) {{.R.Go}}({{.T.Params}}) ({{.R.Results}}error) {
+	if s, ok := a.v.({{.Iface}}); ok {
+		return s.{{.R.Go}}({{.T.Args ""}})
+	}
+	return a.BaseServer.{{.R.Go}}({{.T.Args ""}})
+}
+{{end}}{{end}}
+var (
+	RPCNames = map[MType]string{ {{range .Dialects}}{{range $.Consts .}}
+		{{.MType}}: "{{.MType}}",{{end}}{{end}}
 	}
 
-	if err := genDecodeStruct(p.t, "", c.T); err != nil {
-		log.Fatalf("%v", err)
+	// Dialects maps each message type to the version of the protocol
+	// that defines it.
+	Dialects = map[MType]string{ {{range .Dialects}}{{range $.Consts .}}
+		{{.MType}}: "{{.Dialect.Version}}",{{end}}{{end}}
 	}
+)
 
-	if err := genParms(p.t, p.tn, c.T); err != nil {
-		log.Fatalf("%v", err)
+// interceptor is the NineServer Intercept returns. Each method runs the
+// hooks around the call to next.
+type interceptor struct {
+	next NineServer
+	i    Interceptor
+}
+{{range .Msgs}}{{if .Served}}
+func (s *interceptor) {{.R.Go}}({{.T.Params}}) ({{.R.Named}}err error) {
+	c, err := s.before("{{.R.Go}}"{{.T.List ", " ""}})
+	if err == nil {
+		{{.R.Refs}}err = s.next.{{.R.Go}}({{.T.Args ""}})
 	}
+	return {{.R.Refs}}s.after(c, err{{range $i, $m := .R.Members}}, r{{$i}}{{end}})
+}
+{{end}}{{end}}
+// Dispatch dispatches request to different functions.
+// It's also the the first place we try to establish server semantics.
+// A server with only some of the optional interfaces gets BaseServer's
+// answer to the rest by embedding it, or by being Adapted.
+func Dispatch(s *Server, b *bytes.Buffer, t MType) error {
+	if s.Fids != nil {
+		m, ok := s.Fids.check(b, t)
+		if !ok {
+			return nil
+		}
+		defer s.Fids.update(m, b)
+	}
+	if ok, err := s.dispatchExtension(b, t); ok {
+		return err
+	}
+	switch t { {{range .Msgs}}{{if .Served}}
+	case {{.T.MType}}:
+		return s.Srv{{.R.Go}}(b){{end}}{{end}}
+	}
+	// This has been tested by removing Attach from the switch.
+	ServerError(b, fmt.Sprintf("Dispatch: %v not supported", RPCNames[t]))
+	return nil
+}
 
-	if err := genRets(p.t, p.tn, c.T); err != nil {
-		log.Fatalf("%v", err)
+func ServerError(b *bytes.Buffer, s string) {
+	// This can't really happen.
+	if b.Len() < 2 {
+		return
 	}
+	u := b.Next(2)
+	t := Tag(u[0]) | Tag(u[1])<<8
+	MarshalRerrorPkt(b, t, s)
+}
 
-	if err := genParms(p.r, p.rn, c.R); err != nil {
-		log.Fatalf("%v", err)
+// Decode decodes one whole 9P message, size included, into the Msg for
+// its type. Slices in the returned Msg alias b.
+func Decode(b []byte) (Msg, error) {
+	if len(b) < 7 {
+		return nil, fmt.Errorf("pkt too short for header: need 7, have %d", len(b))
+	}
+	if l := int64(b[0]) | int64(b[1])<<8 | int64(b[2])<<16 | int64(b[3])<<24; l != int64(len(b)) {
+		return nil, fmt.Errorf("pkt size is %d, have %d bytes", l, len(b))
 	}
+	return decodeMsg(MType(b[4]), b[5:])
+}
 
-	if err := genRets(p.r, p.rn, c.R); err != nil {
-		log.Fatalf("%v", err)
+// decodeMsg is Decode for a message of type t that starts at the tag.
+func decodeMsg(t MType, src []byte) (Msg, error) {
+	switch t { {{range .Msgs}}{{if not .Ext}}{{range .Sides}}
+	case {{.MType}}:
+		m := &{{.Go}}Msg{}
+		return m, decode{{.Go}}(src, m){{end}}{{end}}{{end}}
+	}
+	return nil, fmt.Errorf("Decode: unknown message type %d", t)
+}
+`))
+
+	recfunc = template.Must(template.New("rec").Funcs(funcs).Parse(`
+func Marshal{{.Func}}(b *bytes.Buffer, D {{.Name}}) {
+	var l uint64
+	b.Reset()
+	b.Write([]byte{0, 0})
+	{{.Code}}
+	l = uint64(b.Len()) - 2
+	copy(b.Bytes(), []byte{uint8(l), uint8(l >> 8)})
+	return
+}
+
+func Unmarshal{{.Func}}(b *bytes.Buffer) (D {{.Name}}, err error) {
+	var u []byte
+	var l int
+	_, _ = u, l
+	if b.Len() < 2 {
+		err = fmt.Errorf("pkt too short for size: need 2, have %d", b.Len())
+		return
+	}
+	u = b.Next(2)
+	l = int(u[0]) | int(u[1])<<8
+	if b.Len() < l {
+		err = fmt.Errorf("pkt too short for {{.Name}}: need %d, have %d", l, b.Len())
+		return
+	}
+	// Decode just the {{.Name}}, and leave b at what follows it.
+	b = bytes.NewBuffer(b.Next(l))
+	{{.Decode}}
+	if b.Len() > 0 {
+		err = fmt.Errorf("{{.Name}} too long: %d bytes left over after decode", b.Len())
+	}
+	return
+}
+`))
+
+	pktfunc = template.Must(template.New("pkt").Funcs(funcs).Parse(`
+func Marshal{{.S.Go}}Pkt(b *bytes.Buffer, t Tag{{range .S.Members}}, {{.Name}} {{goType .Type}}{{end}}) {
+	var l uint64
+	b.Reset()
+	b.Write([]byte{0, 0, 0, 0,
+		uint8({{.S.MType}}),
+		byte(t), byte(t >> 8),
+	})
+	{{.Code}}
+	l = uint64(b.Len())
+	copy(b.Bytes(), []byte{uint8(l), uint8(l >> 8), uint8(l >> 16), uint8(l >> 24)})
+	return
+}
+
+func Unmarshal{{.S.Go}}Pkt(b *bytes.Buffer) ({{.S.Results}}Tag, error) {
+	var m {{.S.Go}}Pkt
+	t, err := unmarshal{{.S.Go}}Pkt(b, &m)
+	return {{.S.List "m." ", "}}t, err
+}
+
+func unmarshal{{.S.Go}}Pkt(b *bytes.Buffer, m *{{.S.Go}}Pkt) (t Tag, err error) {
+	var u []byte
+	var l int
+	_, _ = u, l
+	if b.Len() < 2 {
+		err = fmt.Errorf("pkt too short for Tag; need 2, have %d", b.Len())
+		return
 	}
+	u = b.Next(2)
+	t = Tag(u[0]) | Tag(u[1])<<8
+	{{.Decode}}
+	if b.Len() > 0 {
+		err = fmt.Errorf("Packet too long: %d bytes left over after decode", b.Len())
+	}
+	return
+}
+`))
 
-	//log.Print("e %v d %v", c.T, c.R)
+	msgfunc = template.Must(template.New("msg").Funcs(funcs).Parse(`
+// {{.S.Go}}Msg is a decoded {{.S.Go}} message.
+type {{.S.Go}}Msg struct {
+	MTag Tag{{range .S.Members}}
+	{{.Name}} {{goType .Type}}{{end}}
+}
 
-	//	log.Print("------------------", c.T.MParms, "0", c.T.MList, "1", c.R.URet, "2", c.R.UList)
-	//	log.Print("------------------", c.T.MCode)
-	mfunc.Execute(b, c.R)
-	ufunc.Execute(b, c.R)
+func (m *{{.S.Go}}Msg) Type() MType { return {{.S.MType}} }
+func (m *{{.S.Go}}Msg) Tag() Tag { return m.MTag }
+func (m *{{.S.Go}}Msg) Encode(b *bytes.Buffer) {
+	Marshal{{.S.Go}}Pkt(b, m.MTag{{.S.List ", m." ""}})
+}
+func (m *{{.S.Go}}Msg) String() string {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "{{.S.Go}} tag %d", m.MTag)
+	{{.Fcall}}return b.String()
+}
+func (m *{{.S.Go}}Msg) MarshalJSON() ([]byte, error) {
+	type msg {{.S.Go}}Msg
+	return json.Marshal(struct {
+		Type string
+		msg
+	}{"{{.S.Go}}", msg(*m)})
+}
+`))
 
-	if p.n == "error" {
-		return c, nil
+	appendfunc = template.Must(template.New("append").Funcs(funcs).Parse(`
+// Append{{.S.Go}} appends a {{.S.Go}} message to dst and returns the
+// extended slice.
+func Append{{.S.Go}}(dst []byte, t Tag{{range .S.Members}}, {{.Name}} {{goType .Type}}{{end}}) []byte {
+	n := 7
+	{{.Size}}dst = grow(dst, n)
+	dst = append(dst, uint8(n), uint8(n>>8), uint8(n>>16), uint8(n>>24), uint8({{.S.MType}}), uint8(t), uint8(t>>8))
+	{{.Append}}return dst
+}
+
+// Decode{{.S.Go}} decodes a whole {{.S.Go}} message, size included, into m.
+// Byte slices in m alias src.
+func Decode{{.S.Go}}(src []byte, m *{{.S.Go}}Msg) error {
+	src, err := decodeHeader(src, {{.S.MType}})
+	if err != nil {
+		return err
 	}
+	return decode{{.S.Go}}(src, m)
+}
 
-	mfunc.Execute(b, c.T)
-	ufunc.Execute(b, c.T)
-	sfunc.Execute(b, c)
-	cfunc.Execute(b, c)
-	return nil, nil
+// decode{{.S.Go}} is Decode{{.S.Go}} for a message that starts at the tag,
+// as Dispatch sees it.
+func decode{{.S.Go}}(src []byte, m *{{.S.Go}}Msg) error {
+	var l int
+	_ = l
+	if len(src) < 2 {
+		return fmt.Errorf("pkt too short for Tag; need 2, have %d", len(src))
+	}
+	m.MTag = Tag(src[0]) | Tag(src[1])<<8
+	src = src[2:]
+	{{.Slice}}if len(src) > 0 {
+		return fmt.Errorf("Packet too long: %d bytes left over after decode", len(src))
+	}
+	return nil
+}
+`))
 
+	srvfunc = template.Must(template.New("srv").Funcs(funcs).Parse(`
+func (s *Server) Srv{{.R.Go}}(b *bytes.Buffer) (err error) {
+	var m {{.T.Go}}Msg
+	if err = decode{{.T.Go}}(b.Bytes(), &m); err != nil {
+		errorReply(b, m.MTag, err)
+		return nil
+	}
+	{{if .R.Members}}var r {{.R.Go}}Msg
+	{{end}}if {{.R.List "r." ", "}}err = s.NS.{{.R.Go}}({{.T.Args "m"}}); err != nil {
+		errorReply(b, m.MTag, err)
+	} else {
+		reply(b, Append{{.R.Go}}(b.Bytes()[:0], m.MTag{{.R.List ", r." ""}}))
+	}
+	return nil
 }
+`))
 
-func main() {
-	flag.Parse()
-	if *doDebug {
-		debug = log.Printf
+	callfunc = template.Must(template.New("call").Funcs(funcs).Parse(`
+func (c *Client) Call{{.T.Go}}({{.T.Params}}) ({{.R.Results}}error) {
+	t := Tag(0)
+	r := make(chan []byte)
+	c.FromClient <- &RPCCall{b: Append{{.T.Go}}(nil, t{{.T.List ", " ""}}), Reply: r}
+	bb := <-r
+	var m {{.R.Go}}Msg
+	if err := replyError(bb); err != nil {
+		return {{.R.List "m." ", "}}err
 	}
-	var b = bytes.NewBufferString(header)
-	for _, p := range packages {
-		_, err := genMsgRPC(b, p)
+	err := Decode{{.R.Go}}(bb, &m)
+	return {{.R.List "m." ", "}}err
+}
+`))
+
+	fuzzheader = template.Must(template.New("fuzzheader").Funcs(funcs).Parse(`// Code generated by gen.go from messages.spec; DO NOT EDIT.
+
+// +build go1.18
+
+package protocol
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func FuzzDecode(f *testing.F) {
+	for _, m := range []Msg{ {{range .Msgs}}{{if not .Ext}}{{range .Sides}}
+		&{{.Go}}Msg{MTag: 1},{{end}}{{end}}{{end}}
+	} {
+		var b bytes.Buffer
+		m.Encode(&b)
+		f.Add(b.Bytes())
+	}
+	f.Fuzz(func(t *testing.T, in []byte) {
+		m, err := Decode(in)
+		if err != nil {
+			return
+		}
+		var b bytes.Buffer
+		m.Encode(&b)
+		if !bytes.Equal(b.Bytes(), in) {
+			t.Fatalf("Decode(%x) is %v, which encodes as %x", in, m, b.Bytes())
+		}
+	})
+}
+`))
+
+	fuzzrec = template.Must(template.New("fuzzrec").Funcs(funcs).Parse(`
+func FuzzUnmarshal{{.Func}}(f *testing.F) {
+	var b bytes.Buffer
+	Marshal{{.Func}}(&b, {{.Name}}{})
+	f.Add(b.Bytes())
+	f.Fuzz(func(t *testing.T, in []byte) {
+		D, err := Unmarshal{{.Func}}(bytes.NewBuffer(in))
 		if err != nil {
-			log.Fatalf("%v", err)
+			return
+		}
+		var b bytes.Buffer
+		Marshal{{.Func}}(&b, D)
+		if !bytes.HasPrefix(in, b.Bytes()) {
+			t.Fatalf("Unmarshal{{.Func}}(%x) is %v, which marshals as %x", in, D, b.Bytes())
+		}
+		if D2, err := Unmarshal{{.Func}}(&b); err != nil || !reflect.DeepEqual(D2, D) {
+			t.Fatalf("Unmarshal{{.Func}}(Marshal{{.Func}}(%v)) is %v, %v", D, D2, err)
+		}
+	})
+}
+`))
+
+	fuzzpkt = template.Must(template.New("fuzzpkt").Funcs(funcs).Parse(`
+func FuzzUnmarshal{{.S.Go}}Pkt(f *testing.F) {
+	{{if .S.Members}}var m {{.S.Go}}Pkt
+	{{end}}var b bytes.Buffer
+	Marshal{{.S.Go}}Pkt(&b, 1{{.S.List ", m." ""}})
+	f.Add(b.Bytes()[5:])
+	f.Fuzz(func(t *testing.T, in []byte) {
+		var m {{.S.Go}}Pkt
+		tag, err := unmarshal{{.S.Go}}Pkt(bytes.NewBuffer(in), &m)
+		if err != nil {
+			return
+		}
+		var b bytes.Buffer
+		Marshal{{.S.Go}}Pkt(&b, tag{{.S.List ", m." ""}})
+		if !bytes.Equal(b.Bytes()[5:], in) {
+			t.Fatalf("Unmarshal{{.S.Go}}Pkt(%x) is %+v, which marshals as %x", in, m, b.Bytes()[5:])
+		}
+		var m2 {{.S.Go}}Pkt
+		if tag2, err := unmarshal{{.S.Go}}Pkt(bytes.NewBuffer(b.Bytes()[5:]), &m2); err != nil || tag2 != tag || !reflect.DeepEqual(m2, m) {
+			t.Fatalf("Unmarshal{{.S.Go}}Pkt(Marshal{{.S.Go}}Pkt(%+v)) is %+v, %v", m, m2, err)
+		}
+	})
+}
+`))
+
+	fuzzmsg = template.Must(template.New("fuzzmsg").Funcs(funcs).Parse(`
+func FuzzDecode{{.S.Go}}(f *testing.F) {
+	var b bytes.Buffer
+	(&{{.S.Go}}Msg{MTag: 1}).Encode(&b)
+	f.Add(b.Bytes())
+	f.Fuzz(func(t *testing.T, in []byte) {
+		var m {{.S.Go}}Msg
+		if err := Decode{{.S.Go}}(in, &m); err != nil {
+			return
+		}
+		out := Append{{.S.Go}}(nil, m.MTag{{.S.List ", m." ""}})
+		if !bytes.Equal(out, in) {
+			t.Fatalf("Decode{{.S.Go}}(%x) is %v, which appends as %x", in, &m, out)
+		}
+		var m2 {{.S.Go}}Msg
+		if err := Decode{{.S.Go}}(out, &m2); err != nil || !reflect.DeepEqual(m2, m) {
+			t.Fatalf("Decode{{.S.Go}}(Append{{.S.Go}}(%v)) is %v, %v", &m, &m2, err)
+		}
+	})
+}
+`))
+)
+
+// genFuzz writes the fuzz tests for the decoders gen writes.
+func genFuzz(s *spec) ([]byte, error) {
+	var b bytes.Buffer
+	if err := fuzzheader.Execute(&b, s); err != nil {
+		return nil, err
+	}
+	for _, st := range s.Structs {
+		if st.Func == "" {
+			continue
+		}
+		if err := fuzzrec.Execute(&b, st); err != nil {
+			return nil, err
+		}
+	}
+	for _, m := range s.Msgs {
+		for _, sd := range m.Sides() {
+			v := struct{ S *side }{sd}
+			if err := fuzzpkt.Execute(&b, v); err != nil {
+				return nil, err
+			}
+			if m.Ext {
+				continue
+			}
+			if err := fuzzmsg.Execute(&b, v); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return b.Bytes(), nil
+}
+
+func gen(s *spec) ([]byte, error) {
+	var b bytes.Buffer
+	if err := header.Execute(&b, s); err != nil {
+		return nil, err
+	}
+	for _, st := range s.Structs {
+		if st.Func == "" {
+			continue
+		}
+		var code, dec bytes.Buffer
+		s.encode(&code, "D", st.Name, 0)
+		s.decode(&dec, "D", st.Name, 0)
+		if err := recfunc.Execute(&b, struct {
+			*structDef
+			Code, Decode string
+		}{st, code.String(), dec.String()}); err != nil {
+			return nil, err
+		}
+	}
+	for _, m := range s.Msgs {
+		for _, sd := range m.Sides() {
+			var code, dec, fc, size, app, slice bytes.Buffer
+			for _, mb := range sd.Members {
+				s.encode(&code, mb.Name, mb.Type, 0)
+				s.decode(&dec, "m."+mb.Name, mb.Type, 0)
+				s.fcall(&fc, "m."+mb.Name, mb)
+				s.size(&size, mb.Name, mb.Type, 0)
+				s.append(&app, mb.Name, mb.Type, 0)
+				s.slice(&slice, "m."+mb.Name, mb.Type, 0)
+			}
+			v := struct {
+				S                   *side
+				Code, Decode, Fcall string
+				Size, Append, Slice string
+			}{sd, code.String(), dec.String(), fc.String(),
+				size.String(), app.String(), slice.String()}
+			if err := pktfunc.Execute(&b, v); err != nil {
+				return nil, err
+			}
+			if m.Ext {
+				continue
+			}
+			if err := msgfunc.Execute(&b, v); err != nil {
+				return nil, err
+			}
+			if err := appendfunc.Execute(&b, v); err != nil {
+				return nil, err
+			}
+		}
+		if m.Reply || m.Ext {
+			continue
+		}
+		if m.Served() {
+			if err := srvfunc.Execute(&b, m); err != nil {
+				return nil, err
+			}
+		}
+		if err := callfunc.Execute(&b, m); err != nil {
+			return nil, err
 		}
 	}
-	b.WriteString(serverError)
+	return b.Bytes(), nil
+}
 
-	// yeah, it's a hack.
-	dir := &emitter{"dir", "dir", &bytes.Buffer{}, &bytes.Buffer{}, "", &bytes.Buffer{}, "dir", &bytes.Buffer{}, &bytes.Buffer{}, &bytes.Buffer{}, false}
-	if err := genEncodeStruct(protocol.DirPkt{}, "", dir); err != nil {
+func main() {
+	flag.Parse()
+	if *doDebug {
+		debug = log.Printf
+	}
+	f, err := os.Open(*specf)
+	if err != nil {
 		log.Fatalf("%v", err)
 	}
-	if err := genDecodeStruct(protocol.DirPkt{}, "", dir); err != nil {
+	s, err := parse(f)
+	f.Close()
+	if err != nil {
 		log.Fatalf("%v", err)
 	}
-	if err := genParms(protocol.DirPkt{}, "dir", dir); err != nil {
+	b, err := gen(s)
+	if err != nil {
 		log.Fatalf("%v", err)
 	}
-
-	if err := genRets(protocol.DirPkt{}, "dir", dir); err != nil {
+	write(*out, b)
+	b, err = genFuzz(s)
+	if err != nil {
 		log.Fatalf("%v", err)
 	}
+	write(*fuzzout, b)
+}
 
-	msfunc.Execute(b, dir)
-	usfunc.Execute(b, dir)
-
-	if err := ioutil.WriteFile("genout.go", b.Bytes(), 0600); err != nil {
+// write formats the source b and writes it to file.
+func write(file string, b []byte) {
+	src, err := format.Source(b)
+	if err != nil {
+		ioutil.WriteFile(file, b, 0600)
+		log.Fatalf("%v: unformatted source left in %v", err, file)
+	}
+	if err := ioutil.WriteFile(file, src, 0600); err != nil {
 		log.Fatalf("%v", err)
 	}
-
 }
@@ -0,0 +1,57 @@
+// Copyright 2019 The Ninep Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protocol
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// TestGenerated runs gen.go and checks that what it writes is what is
+// checked in, so neither messages.spec nor gen.go can change without the
+// files made from them changing too.
+func TestGenerated(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping go run in short mode")
+	}
+	goCmd, err := exec.LookPath("go")
+	if err != nil {
+		t.Skipf("no go command: %v", err)
+	}
+	dir, err := ioutil.TempDir("", "gen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := []struct{ flag, name string }{
+		{"-o", "genout.go"},
+		{"-fuzz", "genfuzz_test.go"},
+	}
+	args := []string{"run", "gen.go"}
+	for _, f := range files {
+		args = append(args, f.flag, filepath.Join(dir, f.name))
+	}
+	if out, err := exec.Command(goCmd, args...).CombinedOutput(); err != nil {
+		t.Fatalf("go run gen.go: %v\n%s", err, out)
+	}
+	for _, f := range files {
+		want, err := ioutil.ReadFile(f.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := ioutil.ReadFile(filepath.Join(dir, f.name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s is not what gen.go makes; run go generate", f.name)
+		}
+	}
+}
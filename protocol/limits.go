@@ -0,0 +1,249 @@
+// Copyright 2019 The Ninep Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protocol
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync/atomic"
+)
+
+// The errors a client gets when it goes over one of the Listener's Limits.
+var (
+	ErrTooManyFIDs      = errors.New("too many fids")
+	ErrTooManyInFlight  = errors.New("too many requests in flight")
+	ErrTooBig           = errors.New("message too big")
+	ErrTooManyConns     = errors.New("too many connections")
+	ErrTooManyConnsAddr = errors.New("too many connections from one address")
+)
+
+// Limits bound what a client can make a Listener do. Zero means no limit.
+// Set them with the MaxFids, MaxInFlight, MaxBuffered, MaxConnsPerAddr
+// and MaxConns options.
+type Limits struct {
+	// MaxFids is how many fids a connection may have at once. A Tattach
+	// or Twalk for one more gets ErrTooManyFIDs.
+	MaxFids int
+	// MaxInFlight is how many requests, i.e. tags, a connection may have
+	// in flight at once. The server reads that many requests ahead of
+	// the one it is answering; one more gets ErrTooManyInFlight.
+	MaxInFlight int
+	// MaxBuffered is how many bytes of messages a connection may have
+	// read and not yet answered. The server stops reading from a
+	// connection that would go over it until enough have been answered.
+	// A message bigger than that on its own can never be read, so it
+	// gets ErrTooBig, and the connection is closed, as the rest of its
+	// stream cannot be trusted.
+	MaxBuffered int
+	// MaxConnsPerAddr is how many connections one remote host may have
+	// at once, and MaxConns how many the Listener serves in all. Accept
+	// closes connections over them and returns ErrTooManyConnsAddr or
+	// ErrTooManyConns.
+	MaxConnsPerAddr int
+	MaxConns        int
+}
+
+// Stats is a snapshot of what a Listener is doing.
+type Stats struct {
+	Conns  int   // connections being served
+	Fids   int   // fids in use, on connections with a FidTable
+	Panics int64 // panics recovered from
+
+	// Timeouts is how many connections have been closed for going over
+	// ReadTimeout, WriteTimeout or SessionTimeout.
+	Timeouts int64
+
+	// Waits is how many messages have waited for the Scheduler.
+	Waits int64
+
+	// BufferFull is how many times a connection has stopped reading
+	// for MaxBuffered.
+	BufferFull int64
+
+	// How many times each limit has been hit.
+	TooManyFIDs, TooManyInFlight, TooBig, TooManyConns int64
+
+	Limits Limits
+}
+
+// What counters counts: the limits hit, and timeouts.
+const (
+	tooManyFIDs = iota
+	tooManyInFlight
+	tooBig
+	tooManyConns
+	timeouts
+	bufferFull
+	nCounters
+)
+
+// counters are the Listener's counts; use add and get.
+type counters [nCounters]int64
+
+func (c *counters) add(i int) {
+	if c != nil {
+		atomic.AddInt64(&c[i], 1)
+	}
+}
+
+func (c *counters) get(i int) int64 {
+	return atomic.LoadInt64(&c[i])
+}
+
+func limit(name string, n int, set func(*Limits)) ListenerOpt {
+	return func(l *Listener) error {
+		if n < 0 {
+			return fmt.Errorf("%v: %d is negative", name, n)
+		}
+		set(&l.Limits)
+		return nil
+	}
+}
+
+// MaxFids limits the number of fids per connection. It gives each
+// connection a FidTable, to count them.
+func MaxFids(n int) ListenerOpt {
+	return limit("MaxFids", n, func(l *Limits) { l.MaxFids = n })
+}
+
+// MaxInFlight limits the number of requests in flight per connection. It
+// gives each connection a FidTable, to count them.
+func MaxInFlight(n int) ListenerOpt {
+	return limit("MaxInFlight", n, func(l *Limits) { l.MaxInFlight = n })
+}
+
+// MaxBuffered limits the bytes of messages each connection has read and
+// not yet answered.
+func MaxBuffered(n int) ListenerOpt {
+	return limit("MaxBuffered", n, func(l *Limits) { l.MaxBuffered = n })
+}
+
+// MaxConnsPerAddr limits the number of connections from one remote host.
+func MaxConnsPerAddr(n int) ListenerOpt {
+	return limit("MaxConnsPerAddr", n, func(l *Limits) { l.MaxConnsPerAddr = n })
+}
+
+// MaxConns limits the number of connections.
+func MaxConns(n int) ListenerOpt {
+	return limit("MaxConns", n, func(l *Limits) { l.MaxConns = n })
+}
+
+// Stats returns the Listener's counts and limits.
+func (l *Listener) Stats() Stats {
+	s := Stats{
+		Panics:          l.Panics(),
+		TooManyFIDs:     l.counters.get(tooManyFIDs),
+		TooManyInFlight: l.counters.get(tooManyInFlight),
+		TooBig:          l.counters.get(tooBig),
+		TooManyConns:    l.counters.get(tooManyConns),
+		Timeouts:        l.counters.get(timeouts),
+		BufferFull:      l.counters.get(bufferFull),
+		Limits:          l.Limits,
+	}
+	if l.Scheduler != nil {
+		s.Waits = l.Scheduler.Waits()
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	s.Conns = len(l.conns)
+	for c := range l.conns {
+		if c.server.Fids != nil {
+			s.Fids += c.server.Fids.Len()
+		}
+	}
+	return s
+}
+
+// buffer adds n bytes to those the connection has read and not yet
+// answered, once there is room for them under MaxBuffered. It returns
+// false if done is closed first.
+func (c *conn) buffer(n int64, done <-chan struct{}) bool {
+	max := int64(c.listener.Limits.MaxBuffered)
+	counted := false
+	for {
+		c.mu.Lock()
+		if max <= 0 || c.buffered == 0 || c.buffered+n <= max {
+			c.buffered += n
+			c.mu.Unlock()
+			return true
+		}
+		if c.freed == nil {
+			c.freed = make(chan struct{})
+		}
+		freed := c.freed
+		c.mu.Unlock()
+		if !counted {
+			c.listener.counters.add(bufferFull)
+			counted = true
+		}
+		select {
+		case <-freed:
+		case <-done:
+			return false
+		}
+	}
+}
+
+// unbuffer takes n bytes, of a message that has been answered, from
+// those the connection has buffered.
+func (c *conn) unbuffer(n int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.buffered -= n
+	if c.freed != nil {
+		close(c.freed)
+		c.freed = nil
+	}
+}
+
+// remoteHost is the host part of a remote address, which is what
+// MaxConnsPerAddr counts by.
+func remoteHost(a net.Addr) string {
+	if a == nil {
+		return ""
+	}
+	h, _, err := net.SplitHostPort(a.String())
+	if err != nil {
+		return a.String()
+	}
+	return h
+}
+
+// trackConn adds c to the Listener's connections, unless that would go
+// over a limit.
+func (l *Listener) trackConn(c *conn) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.conns == nil {
+		l.conns = make(map[*conn]struct{})
+		l.hosts = make(map[string]int)
+	}
+	switch {
+	case l.Limits.MaxConns > 0 && len(l.conns) >= l.Limits.MaxConns:
+		l.counters.add(tooManyConns)
+		return ErrTooManyConns
+	case l.Limits.MaxConnsPerAddr > 0 && l.hosts[c.host] >= l.Limits.MaxConnsPerAddr:
+		l.counters.add(tooManyConns)
+		return ErrTooManyConnsAddr
+	}
+	l.lastID++
+	c.id = l.lastID
+	l.conns[c] = struct{}{}
+	l.hosts[c.host]++
+	return nil
+}
+
+func (l *Listener) untrackConn(c *conn) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.conns[c]; !ok {
+		return
+	}
+	delete(l.conns, c)
+	if l.hosts[c.host]--; l.hosts[c.host] == 0 {
+		delete(l.hosts, c.host)
+	}
+}
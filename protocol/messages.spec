@@ -0,0 +1,211 @@
+# messages.spec describes every 9P message q9p knows about. gen.go reads it
+# and writes genout.go: the Go types for the messages and structs, the MType
+# constants, RPCNames, Dialects, NineServer, Dispatch, the Marshal and
+# Unmarshal functions, the Msg types and Decode, and the client stubs.
+# To add a message, add it here and run go generate.
+#
+# Everything on the wire is little-endian, as in intro(5). The lines are
+#
+#	dialect <version> [suffix]
+#		Everything after this belongs to that version of the protocol.
+#		The first dialect is the one NineServer and Dispatch serve; the
+#		messages of later ones get types, codecs and client stubs only.
+#	type <Name> <int>
+#		A named integer: uint8, uint16, uint32, uint64 or int32.
+#	struct <Name> [func]	# doc comment
+#		A struct, with a member on each indented line. With func it also
+#		gets Marshal<func> and Unmarshal<func>, which encode it as a record
+#		with a 16-bit size in front, as stat(5) does.
+#	msg <name> <number> [reply|client] [Interface]
+#		The T message numbered <number> and its R message, numbered one
+#		more. Indented lines starting with T or R give their members.
+#		reply means there is only an R message. client means NineServer
+#		does not serve it, so Dispatch answers it with Rerror. A message
+#		NineServer does serve names the interface, e.g. Walker, holding
+#		just its NineServer method; NineServer is made of those.
+#	extend <name>
+#		Only in a dialect with a suffix: a copy of the named msg or struct,
+#		named with the suffix, with more members, e.g. TattachU or DirU.
+#		It gets a type and codecs; it shares the MType of the original.
+#
+# A member is <Name> <type> [label]. The type is an int, a named type,
+# string, a struct, []<type> for a list with a 16-bit count, data for
+# bytes with a 32-bit count, or stat for bytes with a 16-bit count. The
+# label is what fcall(2) calls the member when it prints a message; it
+# defaults to the name in lower case. Names must be exported, and T and R
+# may use the same ones. A # comment after a member is copied to its Go
+# declaration.
+
+dialect 9P2000
+
+type MType uint8
+type Mode uint8
+type NumEntries uint16
+type Tag uint16
+type FID uint32
+type MaxSize uint32
+type Count int32
+type Perm uint32
+type Offset uint64
+
+struct QID	# File identifier
+	Type uint8	# type of the file (high 8 bits of the mode)
+	Version uint32	# version number for the path
+	Path uint64	# server's unique identification of the file
+
+struct Dir dir	# Dir describes a file
+	Type uint16
+	Dev uint32
+	QID QID qid	# file's QID
+	Mode uint32	# permissions and flags
+	Atime uint32	# last access time in seconds
+	Mtime uint32	# last modified time in seconds
+	Length uint64	# file length in bytes
+	Name string	# file name
+	User string uid	# owner name
+	Group string gid	# group name
+	ModUser string muid	# name of the last user that modified the file
+
+msg version 100 Versioner
+	T TMsize MaxSize msize
+	T TVersion string version
+	R RMsize MaxSize msize
+	R RVersion string version
+
+msg auth 102 client
+	T AFID FID afid
+	T Uname string
+	T Aname string
+	R AQID QID aqid
+
+msg attach 104 Attacher
+	T SFID FID fid
+	T AFID FID afid
+	T Uname string
+	T Aname string
+	R QID QID qid
+
+msg error 106 reply
+	R Error string ename
+
+msg flush 108 Flusher
+	T OTag Tag oldtag
+
+msg walk 110 Walker
+	T SFID FID fid
+	T NewFID FID newfid
+	T Paths []string wname
+	R QIDs []QID wqid
+
+msg open 112 Opener
+	T OFID FID fid
+	T Omode Mode mode
+	R OQID QID qid
+	R IOUnit MaxSize
+
+msg create 114 Creator
+	T OFID FID fid
+	T Name string
+	T CreatePerm Perm perm
+	T Omode Mode mode
+	R OQID QID qid
+	R IOUnit MaxSize
+
+msg read 116 Reader
+	T OFID FID fid
+	T Off Offset offset
+	T Len Count count
+	R Data data
+
+msg write 118 Writer
+	T OFID FID fid
+	T Off Offset offset
+	T Data data
+	R RLen Count count
+
+msg clunk 120 Clunker
+	T OFID FID fid
+
+msg remove 122 Remover
+	T OFID FID fid
+
+msg stat 124 Statter
+	T OFID FID fid
+	R B stat
+
+msg wstat 126 Wstatter
+	T OFID FID fid
+	T B stat
+
+dialect 9P2000.u U
+
+extend auth
+	T NUname uint32 n_uname
+
+extend attach
+	T NUname uint32 n_uname
+
+extend error
+	R Errno uint32
+
+extend create
+	T Extension string
+
+extend Dir
+	Extension string
+	NUid uint32 n_uid
+	NGid uint32 n_gid
+	NMuid uint32 n_muid
+
+dialect 9P2000.L
+
+msg lerror 6 reply
+	R Ecode uint32
+
+msg statfs 8
+	T OFID FID fid
+	R FSType uint32 type
+	R BSize uint32
+	R Blocks uint64
+	R BFree uint64
+	R BAvail uint64
+	R Files uint64
+	R FFree uint64
+	R FSID uint64
+	R NameLen uint32
+
+msg lopen 12
+	T OFID FID fid
+	T Flags uint32
+	R OQID QID qid
+	R IOUnit MaxSize
+
+msg lcreate 14
+	T OFID FID fid
+	T Name string
+	T Flags uint32
+	T LMode uint32 mode
+	T GID uint32
+	R OQID QID qid
+	R IOUnit MaxSize
+
+msg readdir 40
+	T OFID FID fid
+	T Off Offset offset
+	T Len Count count
+	R Data data
+
+msg fsync 50
+	T OFID FID fid
+
+msg mkdir 72
+	T DFID FID dfid
+	T Name string
+	T LMode uint32 mode
+	T GID uint32
+	R OQID QID qid
+
+msg unlinkat 76
+	T DFID FID dfid
+	T Name string
+	T Flags uint32
@@ -0,0 +1,333 @@
+// Copyright 2019 The Ninep Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protocol
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// latencyBuckets are the upper bounds, in seconds, of the latency
+// histogram buckets.
+var latencyBuckets = []float64{.0001, .00025, .0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Metrics counts the messages a Listener or Client sends and answers,
+// and serves the counts in the Prometheus text format. Give a Listener or
+// Client one by setting its Metrics field; several may share one.
+//
+// The metrics, each named with the Metrics' namespace in front, are
+//
+//	_requests_total{type}             requests, by T message type
+//	_request_duration_seconds{type}   a histogram of their latency
+//	_errors_total{errno}              Rerrors, by errno name, e.g. ENOENT
+//	_error_strings_total{error}       Rerrors, by string
+//	_connections                      connections open
+//	_fids                             fids in use
+//	_inflight                         requests not yet answered
+//	_read_bytes_total                 bytes in Rread
+//	_written_bytes_total              bytes Rwrite says were written
+//
+// Errors are counted by errno, which has only a few series however many
+// strings a server has; an Rerror string that is not one of NewError's
+// counts as EIO, as NewError makes it. They are counted by string too,
+// but only the first MaxErrorStrings strings get a series of their own;
+// the rest count as "(other)", so a server that puts file names in its
+// errors cannot make the series grow without end.
+//
+// Fids are counted from the messages: a successful Tattach or Twalk to a
+// new fid makes one, and Tclunk or Tremove, whether it fails or not, or a
+// Tversion ends them. A Tclunk or Tremove of a fid there is not, which
+// fails with EBADF, ends none.
+type Metrics struct {
+	Namespace string
+
+	mu           sync.Mutex
+	requests     map[MType]*histogram
+	errors       map[string]int64 // by errnoName
+	strings      map[string]int64 // by error string
+	conns        int64
+	fids         int64
+	inflight     int64
+	readBytes    int64
+	writtenBytes int64
+}
+
+// MaxErrorStrings is how many error strings a Metrics counts on their
+// own.
+const MaxErrorStrings = 100
+
+// otherErrors is the label of the errors past MaxErrorStrings.
+const otherErrors = "(other)"
+
+type histogram struct {
+	counts []int64 // by latencyBuckets, not cumulative; the last is +Inf
+	sum    float64
+	n      int64
+}
+
+// NewMetrics returns a Metrics whose metrics are named namespace_..., e.g.
+// "q9p_server".
+func NewMetrics(namespace string) *Metrics {
+	return &Metrics{
+		Namespace: namespace,
+		requests:  make(map[MType]*histogram),
+		errors:    make(map[string]int64),
+		strings:   make(map[string]int64),
+	}
+}
+
+// A meter feeds one connection's messages into a Metrics. A nil meter
+// does nothing.
+type meter struct {
+	m     *Metrics
+	clock Clock
+	fids  int64 // fids this connection has in the fids gauge
+}
+
+// A request is what a meter knows about a message it has not seen the
+// reply to yet.
+type request struct {
+	t      MType
+	start  time.Time
+	newfid bool // a successful reply makes a fid
+	walk   int  // names walked, which a successful Rwalk has as many QIDs for
+}
+
+// meter returns a meter for a new connection, or nil if m is nil.
+func (m *Metrics) meter(clock Clock) *meter {
+	if m == nil {
+		return nil
+	}
+	m.mu.Lock()
+	m.conns++
+	m.mu.Unlock()
+	return &meter{m: m, clock: clockOr(clock)}
+}
+
+// begin notes a request of type t; src is the rest of it, from the tag.
+func (mt *meter) begin(t MType, src []byte) request {
+	if mt == nil {
+		return request{}
+	}
+	r := request{t: t, start: mt.clock.Now()}
+	switch t {
+	case Tattach:
+		r.newfid = true
+	case Twalk:
+		var m TwalkMsg
+		if decodeTwalk(src, &m) == nil {
+			r.newfid, r.walk = m.NewFID != m.SFID, len(m.Paths)
+		}
+	}
+	mt.m.mu.Lock()
+	mt.m.inflight++
+	mt.m.mu.Unlock()
+	return r
+}
+
+// end notes the reply, a whole message, to a request.
+func (mt *meter) end(r request, reply []byte) {
+	if mt == nil {
+		return
+	}
+	d := mt.clock.Now().Sub(r.start)
+	var rt MType
+	if len(reply) >= 7 {
+		rt = MType(reply[4])
+	}
+
+	m := mt.m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.inflight--
+	h, ok := m.requests[r.t]
+	if !ok {
+		h = &histogram{counts: make([]int64, len(latencyBuckets)+1)}
+		m.requests[r.t] = h
+	}
+	h.n++
+	h.sum += d.Seconds()
+	i := sort.SearchFloat64s(latencyBuckets, d.Seconds())
+	h.counts[i]++
+
+	var fids int64
+	var errno uint32
+	switch rt {
+	case Rerror:
+		var e RerrorMsg
+		if DecodeRerror(reply, &e) == nil {
+			if errno = lookupError(e.Error, 0).Errno; errno == 0 {
+				errno = EIO
+			}
+			m.errors[errnoName(errno)]++
+			m.countString(e.Error)
+		}
+	case Rlerror:
+		var e RlerrorMsg
+		if DecodeRlerror(reply, &e) == nil {
+			errno = e.Ecode
+			m.errors[errnoName(errno)]++
+		}
+	case Rversion:
+		fids = -mt.fids
+	case Rattach:
+		fids = 1
+	case Rwalk:
+		var w RwalkMsg
+		if r.newfid && DecodeRwalk(reply, &w) == nil && len(w.QIDs) == r.walk {
+			fids = 1
+		}
+	case Rread:
+		m.readBytes += int64(len(reply) - 11)
+	case Rwrite:
+		var w RwriteMsg
+		if DecodeRwrite(reply, &w) == nil {
+			m.writtenBytes += int64(w.RLen)
+		}
+	}
+	// The fid is gone even if the Tclunk or Tremove failed, unless it
+	// failed for want of the fid.
+	if (r.t == Tclunk || r.t == Tremove) && errno != EBADF {
+		fids = -1
+	}
+	if mt.fids+fids < 0 {
+		fids = -mt.fids
+	}
+	mt.fids += fids
+	m.fids += fids
+}
+
+// countString counts an error with string s. m.mu is held.
+func (m *Metrics) countString(s string) {
+	if _, ok := m.strings[s]; !ok && len(m.strings) >= MaxErrorStrings {
+		s = otherErrors
+	}
+	m.strings[s]++
+}
+
+// close notes that the connection has gone, and its fids with it.
+func (mt *meter) close() {
+	if mt == nil {
+		return
+	}
+	mt.m.mu.Lock()
+	defer mt.m.mu.Unlock()
+	mt.m.conns--
+	mt.m.fids -= mt.fids
+	mt.fids = 0
+}
+
+// ServeHTTP writes the metrics in the Prometheus text exposition format.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	m.WriteTo(w)
+}
+
+// WriteTo writes the metrics in the Prometheus text exposition format.
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	var b strings.Builder
+	m.mu.Lock()
+	ns := m.Namespace
+
+	var types []int
+	for t := range m.requests {
+		types = append(types, int(t))
+	}
+	sort.Ints(types)
+	fmt.Fprintf(&b, "# HELP %v_requests_total Requests, by message type.\n", ns)
+	fmt.Fprintf(&b, "# TYPE %v_requests_total counter\n", ns)
+	for _, t := range types {
+		fmt.Fprintf(&b, "%v_requests_total{type=%q} %d\n", ns, RPCNames[MType(t)], m.requests[MType(t)].n)
+	}
+	fmt.Fprintf(&b, "# HELP %v_request_duration_seconds Request latency, by message type.\n", ns)
+	fmt.Fprintf(&b, "# TYPE %v_request_duration_seconds histogram\n", ns)
+	for _, t := range types {
+		h, name := m.requests[MType(t)], RPCNames[MType(t)]
+		var n int64
+		for i, le := range latencyBuckets {
+			n += h.counts[i]
+			fmt.Fprintf(&b, "%v_request_duration_seconds_bucket{type=%q,le=\"%v\"} %d\n", ns, name, le, n)
+		}
+		fmt.Fprintf(&b, "%v_request_duration_seconds_bucket{type=%q,le=\"+Inf\"} %d\n", ns, name, h.n)
+		fmt.Fprintf(&b, "%v_request_duration_seconds_sum{type=%q} %v\n", ns, name, h.sum)
+		fmt.Fprintf(&b, "%v_request_duration_seconds_count{type=%q} %d\n", ns, name, h.n)
+	}
+
+	var errs []string
+	for e := range m.errors {
+		errs = append(errs, e)
+	}
+	sort.Strings(errs)
+	fmt.Fprintf(&b, "# HELP %v_errors_total Rerrors, by errno.\n", ns)
+	fmt.Fprintf(&b, "# TYPE %v_errors_total counter\n", ns)
+	for _, e := range errs {
+		fmt.Fprintf(&b, "%v_errors_total{errno=\"%v\"} %d\n", ns, escapeLabel(e), m.errors[e])
+	}
+
+	errs = errs[:0]
+	for e := range m.strings {
+		errs = append(errs, e)
+	}
+	sort.Strings(errs)
+	fmt.Fprintf(&b, "# HELP %v_error_strings_total Rerrors, by string.\n", ns)
+	fmt.Fprintf(&b, "# TYPE %v_error_strings_total counter\n", ns)
+	for _, e := range errs {
+		fmt.Fprintf(&b, "%v_error_strings_total{error=\"%v\"} %d\n", ns, escapeLabel(e), m.strings[e])
+	}
+
+	for _, v := range []struct {
+		name, typ, help string
+		n               int64
+	}{
+		{"connections", "gauge", "Connections open.", m.conns},
+		{"fids", "gauge", "Fids in use.", m.fids},
+		{"inflight", "gauge", "Requests not yet answered.", m.inflight},
+		{"read_bytes_total", "counter", "Bytes read, i.e. in Rread.", m.readBytes},
+		{"written_bytes_total", "counter", "Bytes written, i.e. counted in Rwrite.", m.writtenBytes},
+	} {
+		fmt.Fprintf(&b, "# HELP %v_%v %v\n# TYPE %v_%v %v\n%v_%v %d\n", ns, v.name, v.help, ns, v.name, v.typ, ns, v.name, v.n)
+	}
+	m.mu.Unlock()
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// errnoNames are the names of the errnos in errorTable.
+var errnoNames = map[uint32]string{
+	EPERM:        "EPERM",
+	ENOENT:       "ENOENT",
+	EIO:          "EIO",
+	EBADF:        "EBADF",
+	EACCES:       "EACCES",
+	EEXIST:       "EEXIST",
+	ENOTDIR:      "ENOTDIR",
+	EISDIR:       "EISDIR",
+	EINVAL:       "EINVAL",
+	ENOSPC:       "ENOSPC",
+	EROFS:        "EROFS",
+	ENAMETOOLONG: "ENAMETOOLONG",
+	ENOTEMPTY:    "ENOTEMPTY",
+}
+
+// errnoName is the label for errno: its name, or "other" for one that is
+// not in errorTable, which only an Rlerror can have.
+func errnoName(errno uint32) string {
+	if n, ok := errnoNames[errno]; ok {
+		return n
+	}
+	return "other"
+}
+
+// escapeLabel escapes a label value as the text format wants: backslash,
+// double quote and newline.
+func escapeLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
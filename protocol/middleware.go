@@ -0,0 +1,121 @@
+// Copyright 2019 The Ninep Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protocol
+
+import (
+	"fmt"
+	"time"
+)
+
+// A Middleware wraps a NineServer in another, which usually does
+// something around each call to the one it wraps: logging, counting,
+// checking permissions, or making calls fail for a test. Most are made
+// with Intercept, so that they need not write out every method.
+type Middleware func(next NineServer) NineServer
+
+// Chain returns a Middleware that applies m in order, so that m[0] is the
+// outermost and sees each call first.
+func Chain(m ...Middleware) Middleware {
+	return func(next NineServer) NineServer {
+		for i := len(m) - 1; i >= 0; i-- {
+			next = m[i](next)
+		}
+		return next
+	}
+}
+
+// An Unwrapper is a NineServer that wraps another, as the ones Middleware
+// makes usually do. A connection looks for FidTableSetter, SpanSetter and
+// io.Closer on the NineServer it has and on each that it wraps, so a
+// NineServer that wraps another only needs Unwrap to pass them on.
+type Unwrapper interface {
+	Unwrap() NineServer
+}
+
+// layers returns ns and each NineServer it wraps, outermost first. The
+// value an Adapt NineServer was made of counts as one.
+func layers(ns NineServer) []interface{} {
+	var l []interface{}
+	for v := interface{}(ns); v != nil; {
+		l = append(l, v)
+		switch w := v.(type) {
+		case Unwrapper:
+			v = w.Unwrap()
+		case *adapter:
+			v = w.v
+		default:
+			v = nil
+		}
+	}
+	return l
+}
+
+// A Call is one call of a NineServer method, as an Interceptor sees it.
+type Call struct {
+	// Method is the name of the method, e.g. Rwalk.
+	Method string
+	// Args is the arguments, in order.
+	Args []interface{}
+	// Results is the results, not counting the error, in order. It and
+	// the fields below are set only once the call returns.
+	Results []interface{}
+	// Err is the error the call returned.
+	Err error
+	// Start is when the call started, and Duration how long it took.
+	Start    time.Time
+	Duration time.Duration
+}
+
+// String prints the call as Method(args).
+func (c *Call) String() string {
+	s := c.Method + "("
+	for i, a := range c.Args {
+		if i > 0 {
+			s += ", "
+		}
+		s += fmt.Sprintf("%v", a)
+	}
+	return s + ")"
+}
+
+// An Interceptor is a pair of hooks run around every call of a NineServer
+// method. Either may be nil.
+type Interceptor struct {
+	// Before runs before the call. If it returns an error the method is
+	// not called and the call fails with that error; After still runs.
+	Before func(c *Call) error
+	// After runs once the call returns. It may change c.Err, which is
+	// what the caller gets.
+	After func(c *Call)
+}
+
+// Intercept returns a Middleware that runs i's hooks around every call.
+func Intercept(i Interceptor) Middleware {
+	return func(next NineServer) NineServer {
+		return &interceptor{next: next, i: i}
+	}
+}
+
+// Unwrap returns the NineServer s wraps.
+func (s *interceptor) Unwrap() NineServer {
+	return s.next
+}
+
+func (s *interceptor) before(method string, args ...interface{}) (*Call, error) {
+	c := &Call{Method: method, Args: args, Start: time.Now()}
+	if s.i.Before == nil {
+		return c, nil
+	}
+	return c, s.i.Before(c)
+}
+
+func (s *interceptor) after(c *Call, err error, results ...interface{}) error {
+	c.Duration = time.Since(c.Start)
+	c.Results, c.Err = results, err
+	if s.i.After != nil {
+		s.i.After(c)
+	}
+	return c.Err
+}
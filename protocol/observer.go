@@ -0,0 +1,282 @@
+// Copyright 2019 The Ninep Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protocol
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// An Observer is told what happens on a Listener's connections, or to a
+// Client, as Events. Set a Listener's or Client's Observer field to give
+// it one. Observe may be called from many goroutines at once, and should
+// not block: it is called on the way to and from the network.
+type Observer interface {
+	Observe(e Event)
+}
+
+// ObserverFunc makes an Observer of a func.
+type ObserverFunc func(e Event)
+
+func (f ObserverFunc) Observe(e Event) { f(e) }
+
+// An Event is one of the types below. Its fields say what happened, and
+// String says it in a line for a log. Conn, where there is one, is the
+// connection's id, as in Listener.Conns; it is 0 for a Client's events.
+type Event interface {
+	String() string
+}
+
+// ConnOpened is sent when a Listener starts serving a connection.
+type ConnOpened struct {
+	Conn   uint64
+	Remote string
+}
+
+// ConnClosed is sent when a Listener stops serving a connection. Err is
+// why: a read or write error, a timeout, a limit, or a kill in the
+// control tree.
+type ConnClosed struct {
+	Conn   uint64
+	Remote string
+	Err    error
+}
+
+// MessageReceived is sent for each message read from the network: T
+// messages by a Listener, R messages by a Client. Size is its length in
+// bytes. Duration, for an R message, is how long since its T message was
+// sent.
+type MessageReceived struct {
+	Conn     uint64
+	Type     MType
+	Tag      Tag
+	Size     int
+	Duration time.Duration
+}
+
+// MessageSent is sent for each message written to the network, as it
+// is written: R messages by a Listener, T messages by a Client. Duration, for an R
+// message, is how long since its T message was received.
+type MessageSent struct {
+	Conn     uint64
+	Type     MType
+	Tag      Tag
+	Size     int
+	Duration time.Duration
+}
+
+// DispatchError is sent when a Listener answers a message with an error.
+// If the NineServer panicked, Panic is what it panicked with, Stack is
+// where, and Err is ErrPanic.
+type DispatchError struct {
+	Conn  uint64
+	Type  MType
+	Tag   Tag
+	Err   error
+	Panic interface{}
+	Stack string
+}
+
+// ClientDead is sent when a Client loses its connection.
+type ClientDead struct {
+	Err error
+}
+
+// AcceptError is sent when a Listener's net.Listener fails to accept a
+// connection, or the Listener refuses one. Retry, if not zero, is how
+// long before it tries again.
+type AcceptError struct {
+	Remote string
+	Err    error
+	Retry  time.Duration
+}
+
+func connName(id uint64) string {
+	if id == 0 {
+		return "client"
+	}
+	return fmt.Sprintf("conn %d", id)
+}
+
+func (e ConnOpened) String() string {
+	return fmt.Sprintf("conn %d from %v: opened", e.Conn, e.Remote)
+}
+
+func (e ConnClosed) String() string {
+	return fmt.Sprintf("conn %d from %v: closed: %v", e.Conn, e.Remote, e.Err)
+}
+
+func (e MessageReceived) String() string {
+	s := fmt.Sprintf("%v: <- %v tag %d, %d bytes", connName(e.Conn), RPCNames[e.Type], e.Tag, e.Size)
+	if e.Duration != 0 {
+		s += fmt.Sprintf(", %v", e.Duration)
+	}
+	return s
+}
+
+func (e MessageSent) String() string {
+	s := fmt.Sprintf("%v: -> %v tag %d, %d bytes", connName(e.Conn), RPCNames[e.Type], e.Tag, e.Size)
+	if e.Duration != 0 {
+		s += fmt.Sprintf(", %v", e.Duration)
+	}
+	return s
+}
+
+func (e DispatchError) String() string {
+	if e.Panic != nil {
+		return fmt.Sprintf("%v: %v tag %d: panic: %v\n%v", connName(e.Conn), RPCNames[e.Type], e.Tag, e.Panic, e.Stack)
+	}
+	return fmt.Sprintf("%v: %v tag %d: %v", connName(e.Conn), RPCNames[e.Type], e.Tag, e.Err)
+}
+
+func (e ClientDead) String() string {
+	return fmt.Sprintf("client: dead: %v", e.Err)
+}
+
+func (e AcceptError) String() string {
+	s := "accept"
+	if e.Remote != "" {
+		s += " " + e.Remote
+	}
+	s += fmt.Sprintf(": %v", e.Err)
+	if e.Retry != 0 {
+		s += fmt.Sprintf("; retrying in %v", e.Retry)
+	}
+	return s
+}
+
+// Observe makes a Tracer an Observer, which traces each Event as its
+// String. It is how a Listener's or Client's Trace is used when it has no
+// Observer.
+func (t Tracer) Observe(e Event) {
+	t("%v", e)
+}
+
+// LogObserver returns an Observer that prints each Event as a line on l,
+// or with log.Printf if l is nil.
+func LogObserver(l *log.Logger) Observer {
+	return ObserverFunc(func(e Event) {
+		if l == nil {
+			log.Printf("%v", e)
+		} else {
+			l.Printf("%v", e)
+		}
+	})
+}
+
+// JSONObserver returns an Observer that writes each Event to w as a line
+// of JSON: an object with the time, the event's type, and its fields,
+// named as they are but starting in lower case. Message types are given
+// by name, errors as their strings and durations in seconds. Write errors
+// are ignored.
+//
+//	{"time":"2019-06-01T12:00:00.000001Z","event":"MessageSent","conn":1,"type":"Rread","tag":3,"size":40,"duration":0.000123}
+func JSONObserver(w io.Writer) Observer {
+	var mu sync.Mutex
+	return ObserverFunc(func(e Event) {
+		b := jsonEvent(time.Now(), e)
+		mu.Lock()
+		defer mu.Unlock()
+		w.Write(b)
+	})
+}
+
+// jsonEvent returns e as JSONObserver writes it, at time now.
+func jsonEvent(now time.Time, e Event) []byte {
+	var b bytes.Buffer
+	v := reflect.Indirect(reflect.ValueOf(e))
+	fmt.Fprintf(&b, `{"time":%q,"event":%q`, now.UTC().Format(time.RFC3339Nano), v.Type().Name())
+	if v.Kind() == reflect.Struct {
+		for i := 0; i < v.NumField(); i++ {
+			f := v.Type().Field(i)
+			if f.PkgPath != "" {
+				continue
+			}
+			var x interface{}
+			switch fv := v.Field(i).Interface().(type) {
+			case nil:
+			case error:
+				x = fv.Error()
+			case MType:
+				x = RPCNames[fv]
+			case time.Duration:
+				x = fv.Seconds()
+			default:
+				x = fv
+			}
+			j, err := json.Marshal(x)
+			if err != nil {
+				j, _ = json.Marshal(fmt.Sprint(x))
+			}
+			r, n := utf8.DecodeRuneInString(f.Name)
+			fmt.Fprintf(&b, ",%q:%s", string(unicode.ToLower(r))+f.Name[n:], j)
+		}
+	}
+	b.WriteString("}\n")
+	return b.Bytes()
+}
+
+// observe tells the Listener's Observer of e. Without an Observer it is
+// traced with Trace, or, without that, logged if "trace on" was written
+// to the control tree.
+func (l *Listener) observe(e Event) {
+	switch {
+	case l.Observer != nil:
+		l.Observer.Observe(e)
+	case l.Trace != nil:
+		l.Trace.Observe(e)
+	case l.tracingOn():
+		LogObserver(nil).Observe(e)
+	}
+}
+
+// observe tells the Client's Observer, or Trace, of e, and reports
+// whether there was one.
+func (c *Client) observe(e Event) bool {
+	switch {
+	case c.Observer != nil:
+		c.Observer.Observe(e)
+	case c.Trace != nil:
+		c.Trace.Observe(e)
+	default:
+		return false
+	}
+	return true
+}
+
+// tracingOn reports whether "trace on" was written to the control tree.
+func (l *Listener) tracingOn() bool {
+	return atomic.LoadInt32(&l.tracing) != 0
+}
+
+// setReason notes why the connection is being closed, for its
+// ConnClosed, unless there is a reason already.
+func (c *conn) setReason(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.reason == nil {
+		c.reason = err
+	}
+}
+
+// closeReason returns the reason set for closing the connection, or err
+// if there is none.
+func (c *conn) closeReason(err error) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.reason != nil {
+		return c.reason
+	}
+	return err
+}
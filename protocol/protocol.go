@@ -4,47 +4,18 @@
 
 package protocol
 
-import "bytes"
-
-// 9P2000 message types
-const (
-	Tversion MType = 100 + iota
-	Rversion
-	Tauth
-	Rauth
-	Tattach
-	Rattach
-	Terror
-	Rerror
-	Tflush
-	Rflush
-	Twalk
-	Rwalk
-	Topen
-	Ropen
-	Tcreate
-	Rcreate
-	Tread
-	Rread
-	Twrite
-	Rwrite
-	Tclunk
-	Rclunk
-	Tremove
-	Rremove
-	Tstat
-	Rstat
-	Twstat
-	Rwstat
-	Tlast
+import (
+	"bytes"
+	"time"
 )
 
 const (
-	MSIZE   = 2*1048576 + IOHDRSZ // default message size (1048576+IOHdrSz)
-	IOHDRSZ = 24                  // the non-data size of the Twrite messages
-	PORT    = 564                 // default port for 9P file servers
-	NumFID  = 1 << 16
-	QIDLen  = 13
+	MSIZE    = 2*1048576 + IOHDRSZ // default message size (1048576+IOHdrSz)
+	IOHDRSZ  = 24                  // the non-data size of the Twrite messages
+	PORT     = 564                 // default port for 9P file servers
+	NumFID   = 1 << 16
+	QIDLen   = 13
+	MAXWELEM = 16 // most names in one Twalk
 )
 
 // QID types
@@ -95,194 +66,31 @@ const (
 	NumTags = 1<<16 - 2
 )
 
-// Error values
+// Error values, as sent in the errno of 9P2000.u and 9P2000.L. They are
+// Linux's, whatever system the server runs on.
 const (
-	EPERM   = 1
-	ENOENT  = 2
-	EIO     = 5
-	EACCES  = 13
-	EEXIST  = 17
-	ENOTDIR = 20
-	EINVAL  = 22
+	EPERM        = 1
+	ENOENT       = 2
+	EIO          = 5
+	EBADF        = 9
+	EACCES       = 13
+	EEXIST       = 17
+	ENOTDIR      = 20
+	EISDIR       = 21
+	EINVAL       = 22
+	ENOSPC       = 28
+	EROFS        = 30
+	ENAMETOOLONG = 36
+	ENOTEMPTY    = 39
 )
 
-// Types contained in 9p messages.
-type (
-	MType      uint8
-	Mode       uint8
-	NumEntries uint16
-	Tag        uint16
-	FID        uint32
-	MaxSize    uint32
-	Count      int32
-	Perm       uint32
-	Offset     uint64
-	Data       []byte
-	// Some []byte fields are encoded with a 16-bit length, e.g. stat data.
-	// We use this type to tag such fields. The parameters are still []byte,
-	// this was just the only way I could think of to make the stub generator do the right
-	// thing.
-	DataCnt16 byte // []byte with a 16-bit count.
-)
-
-// Error represents a 9P2000 error
-type Error struct {
-	Err string
-}
-
-// File identifier
-type QID struct {
-	Type    uint8  // type of the file (high 8 bits of the mode)
-	Version uint32 // version number for the path
-	Path    uint64 // server's unique identification of the file
-}
-
-// Dir describes a file
-type Dir struct {
-	Type    uint16
-	Dev     uint32
-	QID     QID    // file's QID
-	Mode    uint32 // permissions and flags
-	Atime   uint32 // last access time in seconds
-	Mtime   uint32 // last modified time in seconds
-	Length  uint64 // file length in bytes
-	Name    string // file name
-	User    string // owner name
-	Group   string // group name
-	ModUser string // name of the last user that modified the file
-}
-
 type Dispatcher func(s *Server, b *bytes.Buffer, t MType) error
 
-// N.B. In all packets, the wire order is assumed to be the order in which you
-// put struct members.
-// In an earlier version of this code we got really fancy and made it so you
-// could have identically named fields in the R and T packets. It's only an issue
-// in a trivial number of packets so we place the burden on you, the user, to make
-// the names different. Also, you can't name struct members with the same names as the
-// type. Sorry. But it keeps gen.go so much simpler.
-
-type TversionPkt struct {
-	TMsize   MaxSize
-	TVersion string
-}
-
-type RversionPkt struct {
-	RMsize   MaxSize
-	RVersion string
-}
-
-type TattachPkt struct {
-	SFID  FID
-	AFID  FID
-	Uname string
-	Aname string
-}
-
-type RattachPkt struct {
-	QID QID
-}
-
-type TflushPkt struct {
-	OTag Tag
-}
-
-type RflushPkt struct {
-}
-
-type TwalkPkt struct {
-	SFID   FID
-	NewFID FID
-	Paths  []string
-}
-
-type RwalkPkt struct {
-	QIDs []QID
-}
-
-type TopenPkt struct {
-	OFID  FID
-	Omode Mode
-}
-
-type RopenPkt struct {
-	OQID   QID
-	IOUnit MaxSize
-}
-
-type TcreatePkt struct {
-	OFID       FID
-	Name       string
-	CreatePerm Perm
-	Omode      Mode
-}
-
-type RcreatePkt struct {
-	OQID   QID
-	IOUnit MaxSize
-}
-
-type TclunkPkt struct {
-	OFID FID
-}
-
-type RclunkPkt struct {
-}
-
-type TremovePkt struct {
-	OFID FID
-}
-
-type RremovePkt struct {
-}
-
-type TstatPkt struct {
-	OFID FID
-}
-
-type RstatPkt struct {
-	B []DataCnt16
-}
-
-type TwstatPkt struct {
-	OFID FID
-	B    []DataCnt16
-}
-
-type RwstatPkt struct {
-}
-
-type TreadPkt struct {
-	OFID FID
-	Off  Offset
-	Len  Count
-}
-
-type RreadPkt struct {
-	Data []byte
-}
-
-type TwritePkt struct {
-	OFID FID
-	Off  Offset
-	Data []byte
-}
-
-type RwritePkt struct {
-	RLen Count
-}
-
-type RerrorPkt struct {
-	Error string
-}
-
-type DirPkt struct {
-	D Dir
-}
-
 type RPCCall struct {
 	b     []byte
 	Reply chan []byte
+	req   request   // for Client.Metrics
+	sent  time.Time // for MessageReceived.Duration
 }
 
 type RPCReply struct {
@@ -292,52 +100,7 @@ type RPCReply struct {
 /* rpc servers */
 type ClientOpt func(*Client) error
 type ListenerOpt func(*Listener) error
-type Tracer func(string, ...interface{})
 
-type NineServer interface {
-	Rversion(MaxSize, string) (MaxSize, string, error)
-	Rattach(FID, FID, string, string) (QID, error)
-	Rwalk(FID, FID, []string) ([]QID, error)
-	Ropen(FID, Mode) (QID, MaxSize, error)
-	Rcreate(FID, string, Perm, Mode) (QID, MaxSize, error)
-	Rstat(FID) ([]byte, error)
-	Rwstat(FID, []byte) error
-	Rclunk(FID) error
-	Rremove(FID) error
-	Rread(FID, Offset, Count) ([]byte, error)
-	Rwrite(FID, Offset, []byte) (Count, error)
-	Rflush(Otag Tag) error
-}
-
-var (
-	RPCNames = map[MType]string{
-		Tversion: "Tversion",
-		Rversion: "Rversion",
-		Tauth:    "Tauth",
-		Rauth:    "Rauth",
-		Tattach:  "Tattach",
-		Rattach:  "Rattach",
-		Terror:   "Terror",
-		Rerror:   "Rerror",
-		Tflush:   "Tflush",
-		Rflush:   "Rflush",
-		Twalk:    "Twalk",
-		Rwalk:    "Rwalk",
-		Topen:    "Topen",
-		Ropen:    "Ropen",
-		Tcreate:  "Tcreate",
-		Rcreate:  "Rcreate",
-		Tread:    "Tread",
-		Rread:    "Rread",
-		Twrite:   "Twrite",
-		Rwrite:   "Rwrite",
-		Tclunk:   "Tclunk",
-		Rclunk:   "Rclunk",
-		Tremove:  "Tremove",
-		Rremove:  "Rremove",
-		Tstat:    "Tstat",
-		Rstat:    "Rstat",
-		Twstat:   "Twstat",
-		Rwstat:   "Rwstat",
-	}
-)
+// A Tracer gets a Listener's or Client's Events as printf-style lines, if
+// it has no Observer. It is kept for older code; see Observer.
+type Tracer func(string, ...interface{})
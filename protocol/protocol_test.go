@@ -6,11 +6,21 @@ package protocol
 
 import (
 	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
 	"net"
+	"net/http/httptest"
 	"os"
 	"reflect"
+	"strings"
+	"sync"
+	"syscall"
 	"testing"
+	"time"
 )
 
 var (
@@ -200,6 +210,1937 @@ func testDecode(t *testing.T) {
 }
 */
 
+func TestDecode(t *testing.T) {
+	var tests = []struct {
+		n string
+		f func(b *bytes.Buffer)
+		s string
+	}{
+		{
+			"Tversion",
+			func(b *bytes.Buffer) { MarshalTversionPkt(b, Tag(0xaa55), 8192, "9P2000") },
+			"Tversion tag 43605 msize 8192 version '9P2000'",
+		},
+		{
+			"Twalk",
+			func(b *bytes.Buffer) { MarshalTwalkPkt(b, Tag(1), 0, 1, []string{"usr", "glenda"}) },
+			"Twalk tag 1 fid 0 newfid 1 nwname 2 0:usr 1:glenda",
+		},
+		{
+			"Rwalk",
+			func(b *bytes.Buffer) { MarshalRwalkPkt(b, Tag(1), []QID{{Type: QTDIR, Version: 1, Path: 0xaa55}}) },
+			"Rwalk tag 1 nwqid 1 0:(000000000000aa55 1 d)",
+		},
+		{
+			"Tcreate",
+			func(b *bytes.Buffer) { MarshalTcreatePkt(b, Tag(3), 74, "y", DMDIR|0755, 0) },
+			"Tcreate tag 3 fid 74 name y perm d-rwxr-xr-x mode 0",
+		},
+		{
+			"Rstat",
+			func(b *bytes.Buffer) {
+				var d bytes.Buffer
+				Marshaldir(&d, Dir{Name: "lib", User: "glenda", Group: "sys", QID: QID{Type: QTDIR, Path: 7}, Mode: DMDIR | 0775, Mtime: 1445544644})
+				MarshalRstatPkt(b, Tag(1), d.Bytes())
+			},
+			"Rstat tag 1 stat 'lib' 'glenda' 'sys' '' q (0000000000000007 0 d) m 020000000775 at 0 mt 1445544644 l 0 t 0 d 0",
+		},
+		{
+			"Rread",
+			func(b *bytes.Buffer) { MarshalRreadPkt(b, Tag(2), []byte("hi\n")) },
+			"Rread tag 2 count 3 'hi\n'",
+		},
+		{
+			"Rerror",
+			func(b *bytes.Buffer) { MarshalRerrorPkt(b, Tag(2), "file does not exist") },
+			"Rerror tag 2 ename file does not exist",
+		},
+		{
+			"Rflush",
+			func(b *bytes.Buffer) { MarshalRflushPkt(b, Tag(9)) },
+			"Rflush tag 9",
+		},
+	}
+
+	for _, v := range tests {
+		var b bytes.Buffer
+		v.f(&b)
+		want := append([]byte{}, b.Bytes()...)
+		m, err := Decode(want)
+		if err != nil {
+			t.Errorf("%v: Decode: want nil, got %v", v.n, err)
+			continue
+		}
+		if RPCNames[m.Type()] != v.n {
+			t.Errorf("%v: Decode: got type %v", v.n, RPCNames[m.Type()])
+		}
+		if m.String() != v.s {
+			t.Errorf("%v: String: got\n%q, want\n%q", v.n, m.String(), v.s)
+		}
+		m.Encode(&b)
+		if !reflect.DeepEqual(want, b.Bytes()) {
+			t.Errorf("%v: Encode: got %v, want %v", v.n, b.Bytes(), want)
+		}
+		if _, err := json.Marshal(m); err != nil {
+			t.Errorf("%v: json.Marshal: want nil, got %v", v.n, err)
+		}
+	}
+
+	if _, err := Decode([]byte{7, 0, 0, 0, 99, 0, 0}); err == nil {
+		t.Errorf("Decode of unknown type: want err, got nil")
+	}
+	if _, err := Decode([]byte{19, 0, 0, 0, 100, 0, 0}); err == nil {
+		t.Errorf("Decode of short message: want err, got nil")
+	}
+}
+
+func TestAppend(t *testing.T) {
+	qids := []QID{{Type: QTDIR, Version: 1, Path: 0xaa55}, {Path: 7}}
+	var tests = []struct {
+		n string
+		m func(b *bytes.Buffer)
+		a []byte
+		d func(src []byte) (Msg, error)
+	}{
+		{
+			"Tversion",
+			func(b *bytes.Buffer) { MarshalTversionPkt(b, 1, 8192, "9P2000") },
+			AppendTversion(nil, 1, 8192, "9P2000"),
+			func(src []byte) (Msg, error) { var m TversionMsg; return &m, DecodeTversion(src, &m) },
+		},
+		{
+			"Twalk",
+			func(b *bytes.Buffer) { MarshalTwalkPkt(b, 2, 0, 1, []string{"usr", "glenda"}) },
+			AppendTwalk(make([]byte, 0, 4), 2, 0, 1, []string{"usr", "glenda"}),
+			func(src []byte) (Msg, error) { var m TwalkMsg; return &m, DecodeTwalk(src, &m) },
+		},
+		{
+			"Rwalk",
+			func(b *bytes.Buffer) { MarshalRwalkPkt(b, 3, qids) },
+			AppendRwalk(nil, 3, qids),
+			func(src []byte) (Msg, error) { var m RwalkMsg; return &m, DecodeRwalk(src, &m) },
+		},
+		{
+			"Twrite",
+			func(b *bytes.Buffer) { MarshalTwritePkt(b, 4, 5, 6, []byte("hi\n")) },
+			AppendTwrite(nil, 4, 5, 6, []byte("hi\n")),
+			func(src []byte) (Msg, error) { var m TwriteMsg; return &m, DecodeTwrite(src, &m) },
+		},
+		{
+			"Rclunk",
+			func(b *bytes.Buffer) { MarshalRclunkPkt(b, 5) },
+			AppendRclunk(nil, 5),
+			func(src []byte) (Msg, error) { var m RclunkMsg; return &m, DecodeRclunk(src, &m) },
+		},
+	}
+
+	for _, v := range tests {
+		var b bytes.Buffer
+		v.m(&b)
+		if !reflect.DeepEqual(b.Bytes(), v.a) {
+			t.Errorf("%v: Append: got %v, want %v", v.n, v.a, b.Bytes())
+			continue
+		}
+		want, err := Decode(b.Bytes())
+		if err != nil {
+			t.Fatalf("%v: Decode: want nil, got %v", v.n, err)
+		}
+		m, err := v.d(v.a)
+		if err != nil {
+			t.Errorf("%v: Decode%v: want nil, got %v", v.n, v.n, err)
+			continue
+		}
+		if !reflect.DeepEqual(m, want) {
+			t.Errorf("%v: Decode%v: got %v, want %v", v.n, v.n, m, want)
+		}
+		if _, err := v.d(v.a[:len(v.a)-1]); err == nil {
+			t.Errorf("%v: Decode%v of a short message: want err, got nil", v.n, v.n)
+		}
+	}
+
+	var m RreadMsg
+	if err := DecodeRread(AppendTread(nil, 1, 2, 3, 4), &m); err == nil {
+		t.Errorf("DecodeRread of a Tread: want err, got nil")
+	}
+	src := AppendRread(nil, 1, []byte("abc"))
+	if err := DecodeRread(src, &m); err != nil {
+		t.Fatalf("DecodeRread: want nil, got %v", err)
+	}
+	src[len(src)-1] = 'C'
+	if string(m.Data) != "abC" {
+		t.Errorf("DecodeRread: Data is %q, want it to alias the message", m.Data)
+	}
+}
+
+func TestIntercept(t *testing.T) {
+	var calls []string
+	logger := func(name string) Middleware {
+		return Intercept(Interceptor{
+			Before: func(c *Call) error {
+				calls = append(calls, name+" before "+c.String())
+				return nil
+			},
+			After: func(c *Call) {
+				calls = append(calls, fmt.Sprintf("%v after %v %v %v", name, c.Method, c.Results, c.Err))
+			},
+		})
+	}
+	deny := Intercept(Interceptor{
+		Before: func(c *Call) error {
+			if c.Method == "Rremove" {
+				return fmt.Errorf("permission denied")
+			}
+			return nil
+		},
+		After: func(c *Call) {
+			if c.Method == "Rclunk" {
+				c.Err = fmt.Errorf("injected")
+			}
+		},
+	})
+	e := newEcho()
+	ns := Chain(logger("a"), logger("b"), deny)(e)
+
+	if _, iounit, err := ns.Ropen(1, OREAD); err != nil || iounit != 4000 {
+		t.Errorf("Ropen: got %v, %v, want 4000, nil", iounit, err)
+	}
+	want := []string{
+		"a before Ropen(1, 0)",
+		"b before Ropen(1, 0)",
+		"b after Ropen [(0000000000000000 0 ) 4000] <nil>",
+		"a after Ropen [(0000000000000000 0 ) 4000] <nil>",
+	}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("Ropen: got calls %q, want %q", calls, want)
+	}
+
+	removedFID2 = false
+	if err := ns.Rremove(2); err == nil || removedFID2 {
+		t.Errorf("Rremove: want the call denied, got %v", err)
+	}
+	if err := ns.Rclunk(22); err == nil || err.Error() != "injected" {
+		t.Errorf("Rclunk: want injected error, got %v", err)
+	}
+}
+
+// hooked has the hooks a connection calls on its NineServer. It is not
+// a NineServer itself, so it has to be Adapted.
+type hooked struct {
+	reader
+	fids   *FidTable
+	closed chan bool
+}
+
+func (h *hooked) SetFidTable(t *FidTable) { h.fids = t }
+func (h *hooked) Close() error {
+	h.closed <- true
+	return nil
+}
+
+// wrapped is a Middleware written out by hand, not made with Intercept.
+type wrapped struct {
+	NineServer
+}
+
+func (w wrapped) Unwrap() NineServer { return w.NineServer }
+
+func TestUnwrap(t *testing.T) {
+	h := &hooked{closed: make(chan bool, 1)}
+	mw := Chain(Intercept(Interceptor{}), func(next NineServer) NineServer { return wrapped{next} })
+	l, err := NewListener(func() NineServer { return mw(Adapt(h)) }, func(l *Listener) error {
+		l.FidTable = true
+		l.CtlAname = DefaultCtlAname
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("NewListener: want nil, got %v", err)
+	}
+	p, p2 := net.Pipe()
+	if err := l.Accept(p2); err != nil {
+		t.Fatalf("Accept: want nil, got %v", err)
+	}
+	if h.fids == nil {
+		t.Errorf("SetFidTable was not called through the ctl tree, the Middleware and Adapt")
+	}
+	p.Close()
+	select {
+	case <-h.closed:
+	case <-time.After(5 * time.Second):
+		t.Errorf("Close was not called through the ctl tree, the Middleware and Adapt")
+	}
+}
+
+// dirs is a NineServer where every name is a directory and every call
+// works, for testing the FidTable. It counts the calls that reach it.
+type dirs struct {
+	*echo
+	calls int
+	fids  *FidTable
+}
+
+func (d *dirs) SetFidTable(t *FidTable) { d.fids = t }
+func (d *dirs) Rattach(fid FID, afid FID, uname string, aname string) (QID, error) {
+	d.calls++
+	return QID{Type: QTDIR}, d.fids.SetAux(fid, aname)
+}
+func (d *dirs) Rwalk(fid FID, newfid FID, paths []string) ([]QID, error) {
+	d.calls++
+	q := make([]QID, len(paths))
+	for i := range q {
+		q[i] = QID{Type: QTDIR, Path: uint64(i)}
+	}
+	if len(paths) > 0 && paths[len(paths)-1] == "missing" {
+		q = q[:len(q)-1]
+	}
+	return q, nil
+}
+func (d *dirs) Ropen(fid FID, mode Mode) (QID, MaxSize, error) {
+	d.calls++
+	return QID{Type: QTDIR}, 0, nil
+}
+func (d *dirs) Rread(fid FID, o Offset, c Count) ([]byte, error) {
+	d.calls++
+	return nil, nil
+}
+func (d *dirs) Rwrite(fid FID, o Offset, b []byte) (Count, error) {
+	d.calls++
+	return Count(len(b)), nil
+}
+func (d *dirs) Rclunk(fid FID) error {
+	d.calls++
+	return nil
+}
+
+func TestFidTable(t *testing.T) {
+	d := &dirs{echo: newEcho()}
+	l, err := NewListener(func() NineServer { return d }, func(l *Listener) error {
+		l.FidTable = true
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("NewListener: want nil, got %v", err)
+	}
+	c, err := l.newConn(nil)
+	if err != nil {
+		t.Fatalf("newConn: want nil, got %v", err)
+	}
+	s := c.server
+	if d.fids != s.Fids {
+		t.Fatalf("SetFidTable was not called")
+	}
+
+	many := make([]string, MAXWELEM+1)
+	for _, v := range []struct {
+		n   string
+		b   []byte
+		err error
+	}{
+		{"Tattach", AppendTattach(nil, 1, 0, NOFID, "glenda", "main"), nil},
+		{"Tattach reusing fid", AppendTattach(nil, 1, 0, NOFID, "glenda", ""), ErrDupFID},
+		{"Twalk from unknown fid", AppendTwalk(nil, 1, 9, 1, nil), ErrUnknownFID},
+		{"Twalk clone", AppendTwalk(nil, 1, 0, 1, nil), nil},
+		{"Twalk to fid in use", AppendTwalk(nil, 1, 0, 1, []string{"a"}), ErrDupFID},
+		{"Twalk too far", AppendTwalk(nil, 1, 0, 2, many), ErrTooManyNames},
+		{"Twalk partly", AppendTwalk(nil, 1, 0, 2, []string{"a", "missing"}), nil},
+		{"Tread from partly walked fid", AppendTread(nil, 1, 2, 0, 10), ErrUnknownFID},
+		{"Tread from unopened fid", AppendTread(nil, 1, 1, 0, 10), ErrFIDNotOpen},
+		{"Topen", AppendTopen(nil, 1, 1, OREAD), nil},
+		{"Topen again", AppendTopen(nil, 1, 1, OREAD), ErrFIDOpen},
+		{"Twalk from open fid", AppendTwalk(nil, 1, 1, 3, nil), ErrCloneOpen},
+		{"Tread", AppendTread(nil, 1, 1, 0, 10), nil},
+		{"Twrite to OREAD fid", AppendTwrite(nil, 1, 1, 0, []byte("hi")), ErrNotOpenWrite},
+		{"Tflush of a tag not in flight", AppendTflush(nil, 1, 5), nil},
+		{"Tclunk", AppendTclunk(nil, 1, 1), nil},
+		{"Tclunk again", AppendTclunk(nil, 1, 1), ErrUnknownFID},
+		{"Tversion", AppendTversion(nil, NOTAG, 8192, "9P2000"), nil},
+		{"Twalk after Tversion", AppendTwalk(nil, 1, 0, 1, nil), ErrUnknownFID},
+	} {
+		calls := d.calls
+		r, err := dispatch(s, v.b)
+		if err != nil {
+			t.Fatalf("%v: want nil, got %v", v.n, err)
+		}
+		e, isErr := r.(*RerrorMsg)
+		switch {
+		case v.err == nil && isErr:
+			t.Errorf("%v: got %v, want success", v.n, e.Error)
+		case v.err != nil && (!isErr || e.Error != v.err.Error()):
+			t.Errorf("%v: got %v, want Rerror %v", v.n, r, v.err)
+		case v.err != nil && d.calls != calls:
+			t.Errorf("%v: the NineServer saw a bad message", v.n)
+		}
+		if v.n == "Tattach" {
+			if f, ok := s.Fids.Get(0); !ok || f.Aux != "main" || f.QID.Type != QTDIR {
+				t.Errorf("Tattach: fid 0 is %v, %v; want a directory with Aux main", f, ok)
+			}
+		}
+	}
+	if n := s.Fids.Len(); n != 0 {
+		t.Errorf("after Tversion: %d fids, want 0", n)
+	}
+
+	s.Fids.tags[7] = true
+	if _, ok := s.Fids.check(bytes.NewBuffer(AppendTclunk(nil, 7, 0)[5:]), Tclunk); ok {
+		t.Errorf("Tclunk with a tag in flight: want it rejected")
+	}
+}
+
+// dispatch runs the message b through s and decodes the reply.
+func dispatch(s *Server, b []byte) (Msg, error) {
+	bb := bytes.NewBuffer(b[5:])
+	if err := s.D(s, bb, MType(b[4])); err != nil {
+		return nil, err
+	}
+	return Decode(bb.Bytes())
+}
+
+// hello is about as small as a server can be: one file, named hello.
+type hello struct {
+	BaseServer
+}
+
+func (hello) Rwalk(fid FID, newfid FID, paths []string) ([]QID, error) {
+	if len(paths) == 1 && paths[0] == "hello" {
+		return []QID{{Path: 1}}, nil
+	}
+	return BaseServer{}.Rwalk(fid, newfid, paths)
+}
+func (hello) Ropen(fid FID, mode Mode) (QID, MaxSize, error) {
+	return QID{Path: 1}, 0, nil
+}
+func (hello) Rread(fid FID, o Offset, c Count) ([]byte, error) {
+	return []byte("hello, world\n"), nil
+}
+
+// reader only reads.
+type reader struct{}
+
+func (reader) Rread(fid FID, o Offset, c Count) ([]byte, error) {
+	return []byte("read"), nil
+}
+
+// rpc writes the message b to c and returns the reply.
+func rpc(c net.Conn, b []byte) (Msg, error) {
+	if _, err := c.Write(b); err != nil {
+		return nil, err
+	}
+	return readReply(c)
+}
+
+// readReply reads one message from c.
+func readReply(c net.Conn) (Msg, error) {
+	r := make([]byte, 4)
+	if _, err := io.ReadFull(c, r); err != nil {
+		return nil, err
+	}
+	r = append(r, make([]byte, int(r[0])|int(r[1])<<8|int(r[2])<<16|int(r[3])<<24-4)...)
+	if _, err := io.ReadFull(c, r[4:]); err != nil {
+		return nil, err
+	}
+	return Decode(r)
+}
+
+func TestBaseServer(t *testing.T) {
+	for _, v := range []struct {
+		n    string
+		ns   NineServer
+		want []string
+	}{
+		{"hello", hello{}, []string{
+			"Rversion tag 65535 msize 8192 version '9P2000'",
+			"Rattach tag 1 qid (0000000000000000 0 d)",
+			"Rwalk tag 1 nwqid 1 0:(0000000000000001 0 )",
+			"Rerror tag 1 ename file does not exist",
+			"Ropen tag 1 qid (0000000000000001 0 ) iounit 0",
+			"Rread tag 1 count 13 'hello, world\n'",
+			"Rerror tag 1 ename write prohibited",
+			"Rerror tag 1 ename stat prohibited",
+			"Rclunk tag 1",
+		}},
+		{"reader", Adapt(reader{}), []string{
+			"Rversion tag 65535 msize 8192 version '9P2000'",
+			"Rattach tag 1 qid (0000000000000000 0 d)",
+			"Rerror tag 1 ename file does not exist",
+			"Rerror tag 1 ename file does not exist",
+			"Rerror tag 1 ename permission denied",
+			"Rread tag 1 count 4 'read'",
+			"Rerror tag 1 ename write prohibited",
+			"Rerror tag 1 ename stat prohibited",
+			"Rclunk tag 1",
+		}},
+	} {
+		s := &Server{NS: v.ns, D: Dispatch}
+		for i, b := range [][]byte{
+			AppendTversion(nil, NOTAG, 8192, "9P2000"),
+			AppendTattach(nil, 1, 0, NOFID, "glenda", ""),
+			AppendTwalk(nil, 1, 0, 1, []string{"hello"}),
+			AppendTwalk(nil, 1, 0, 2, []string{"goodbye"}),
+			AppendTopen(nil, 1, 1, OREAD),
+			AppendTread(nil, 1, 1, 0, 100),
+			AppendTwrite(nil, 1, 1, 0, []byte("hi")),
+			AppendTstat(nil, 1, 1),
+			AppendTclunk(nil, 1, 1),
+		} {
+			r, err := dispatch(s, b)
+			if err != nil {
+				t.Fatalf("%v: %v: want nil, got %v", v.n, RPCNames[MType(b[4])], err)
+			}
+			if r.String() != v.want[i] {
+				t.Errorf("%v: %v: got %q, want %q", v.n, RPCNames[MType(b[4])], r, v.want[i])
+			}
+		}
+	}
+
+	if _, v, _ := (BaseServer{}).Rversion(8192, "9P1999"); v != "unknown" {
+		t.Errorf("Rversion(9P1999): got %v, want unknown", v)
+	}
+	h := hello{}
+	if Adapt(h) != NineServer(h) {
+		t.Errorf("Adapt of a NineServer: want it back unchanged")
+	}
+
+	// An Adapted server is served like any other.
+	l, err := NewListener(func() NineServer { return Adapt(reader{}) })
+	if err != nil {
+		t.Fatalf("NewListener: want nil, got %v", err)
+	}
+	p, p2 := net.Pipe()
+	defer p.Close()
+	if err := l.Accept(p2); err != nil {
+		t.Fatalf("Accept: want nil, got %v", err)
+	}
+	for _, v := range []struct {
+		b    []byte
+		want string
+	}{
+		{AppendTversion(nil, NOTAG, 8192, "9P2000"), "Rversion tag 65535 msize 8192 version '9P2000'"},
+		{AppendTattach(nil, 1, 0, NOFID, "glenda", ""), "Rattach tag 1 qid (0000000000000000 0 d)"},
+		{AppendTread(nil, 1, 0, 0, 100), "Rread tag 1 count 4 'read'"},
+	} {
+		if r, err := rpc(p, v.b); err != nil || r.String() != v.want {
+			t.Errorf("reader through a Listener: got %v, %v, want %v", r, err, v.want)
+		}
+	}
+}
+
+// keeper keeps the byte slices it is called with, as they are and copied.
+type keeper struct {
+	BaseServer
+	kept, copies [][]byte
+}
+
+func (k *keeper) keep(b []byte) {
+	k.kept = append(k.kept, b)
+	k.copies = append(k.copies, append([]byte(nil), b...))
+}
+
+func (k *keeper) Rwrite(fid FID, o Offset, b []byte) (Count, error) {
+	k.keep(b)
+	return Count(len(b)), nil
+}
+
+func (k *keeper) Rwstat(fid FID, b []byte) error {
+	k.keep(b)
+	return nil
+}
+
+// TestKeptSlices checks what a server that keeps Rwrite's Data or
+// Rwstat's B after the call has. They point into the message's buffer,
+// which Dispatch then builds the reply in, so only a copy is promised to
+// be what was sent. That the slices themselves are still intact is
+// checked too: it holds only because each reply is shorter than what
+// comes before the data in its message, and a change that breaks it
+// should be a deliberate one.
+func TestKeptSlices(t *testing.T) {
+	k := &keeper{}
+	s := &Server{NS: k, D: Dispatch}
+	want := [][]byte{[]byte("hello, world"), []byte("x"), []byte("0123456789")}
+	for i, b := range [][]byte{
+		AppendTwrite(nil, 1, 1, 0, want[0]),
+		AppendTwrite(nil, 1, 1, 0, want[1]),
+		AppendTwstat(nil, 1, 1, want[2]),
+	} {
+		r, err := dispatch(s, b)
+		if err != nil {
+			t.Fatalf("%v: want nil, got %v", RPCNames[MType(b[4])], err)
+		}
+		if r.Type() != MType(b[4])+1 {
+			t.Fatalf("%v: got %v, want its R message", RPCNames[MType(b[4])], r)
+		}
+		if !bytes.Equal(k.copies[i], want[i]) {
+			t.Errorf("%v: copy is %q, want %q", RPCNames[MType(b[4])], k.copies[i], want[i])
+		}
+	}
+	for i := range want {
+		if !bytes.Equal(k.kept[i], want[i]) {
+			t.Errorf("slice %d kept after the call is %q, want %q", i, k.kept[i], want[i])
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	for _, v := range []struct {
+		err   error
+		s     string
+		errno uint32
+	}{
+		{os.ErrNotExist, "file does not exist", ENOENT},
+		{&os.PathError{Op: "open", Path: "/x", Err: syscall.ENOENT}, "file does not exist", ENOENT},
+		{&os.PathError{Op: "open", Path: "/x", Err: syscall.EACCES}, "permission denied", EACCES},
+		{syscall.EPERM, "permission denied", EACCES},
+		{&os.SyscallError{Syscall: "mkdir", Err: syscall.EEXIST}, "file already exists", EEXIST},
+		{syscall.ENOTEMPTY, "directory not empty", ENOTEMPTY},
+		{ErrUnknownFID, "unknown fid", EBADF},
+		{ErrNoWrite, "write prohibited", EIO},
+		{&Error{Err: "as is", Errno: EROFS}, "as is", EROFS},
+	} {
+		e := NewError(v.err)
+		if e.Err != v.s || e.Errno != v.errno {
+			t.Errorf("NewError(%v): got %q, %d, want %q, %d", v.err, e.Err, e.Errno, v.s, v.errno)
+		}
+	}
+
+	for _, v := range []struct {
+		b    []byte
+		want error
+	}{
+		{AppendRerror(nil, 1, "file does not exist"), os.ErrNotExist},
+		{AppendRerror(nil, 1, "/x: permission denied"), os.ErrPermission},
+		{AppendRerror(nil, 1, "unknown fid"), ErrUnknownFID},
+		{AppendRlerror(nil, 1, ENOTDIR), syscall.ENOTDIR},
+		{AppendRlerror(nil, 1, EPERM), os.ErrPermission},
+	} {
+		err := replyError(v.b)
+		if !errors.Is(err, v.want) {
+			t.Errorf("replyError(%v): got %v, want it to be %v", v.b, err, v.want)
+		}
+	}
+	if err := replyError(AppendRerror(nil, 1, "frobnicated")); errors.Unwrap(err) != nil {
+		t.Errorf("replyError(frobnicated): got %v, want an Error that unwraps to nil", errors.Unwrap(err))
+	}
+	if err := replyError(AppendRclunk(nil, 1)); err != nil {
+		t.Errorf("replyError(Rclunk): got %v, want nil", err)
+	}
+
+	// And over a connection.
+	p, p2 := net.Pipe()
+	c, err := NewClient(func(c *Client) error {
+		c.FromNet, c.ToNet = p, p
+		c.Trace = t.Logf
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := NewListener(func() NineServer { return hello{} })
+	if err != nil {
+		t.Fatalf("NewListener: want nil, got %v", err)
+	}
+	if err := l.Accept(p2); err != nil {
+		t.Fatalf("Accept: want nil, got %v", err)
+	}
+	if _, err := c.CallTattach(0, NOFID, "glenda", ""); err != nil {
+		t.Fatalf("CallTattach: want nil, got %v", err)
+	}
+	if _, err := c.CallTwalk(0, 1, []string{"goodbye"}); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("CallTwalk(goodbye): got %v, want os.ErrNotExist", err)
+	}
+}
+
+type panicky struct {
+	BaseServer
+}
+
+func (panicky) Rread(fid FID, o Offset, c Count) ([]byte, error) {
+	var b []byte
+	return b[:c], nil
+}
+
+func TestPanic(t *testing.T) {
+	var mu sync.Mutex
+	var panics []DispatchError
+	l, err := NewListener(func() NineServer { return panicky{} }, func(l *Listener) error {
+		l.MaxPanics = 2
+		l.Observer = ObserverFunc(func(e Event) {
+			if e, ok := e.(DispatchError); ok && e.Panic != nil {
+				mu.Lock()
+				panics = append(panics, e)
+				mu.Unlock()
+			}
+		})
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("NewListener: want nil, got %v", err)
+	}
+	p, p2 := net.Pipe()
+	defer p.Close()
+	if err := l.Accept(p2); err != nil {
+		t.Fatalf("Accept: want nil, got %v", err)
+	}
+
+	for i, v := range []struct {
+		b    []byte
+		want string
+	}{
+		{AppendTattach(nil, 1, 0, NOFID, "glenda", ""), "Rattach tag 1 qid (0000000000000000 0 d)"},
+		{AppendTread(nil, 2, 0, 0, 10), "Rerror tag 2 ename internal server error"},
+		{AppendTclunk(nil, 3, 0), "Rclunk tag 3"},
+		{AppendTread(nil, 4, 0, 0, 10), "Rerror tag 4 ename internal server error"},
+	} {
+		r, err := rpc(p, v.b)
+		if err != nil {
+			t.Fatalf("%d: want nil, got %v", i, err)
+		}
+		if r.String() != v.want {
+			t.Errorf("%d: got %q, want %q", i, r, v.want)
+		}
+	}
+	if _, err := rpc(p, AppendTclunk(nil, 5, 0)); err == nil {
+		t.Errorf("after MaxPanics panics: want the connection closed, got a reply")
+	}
+	if n := l.Panics(); n != 2 {
+		t.Errorf("Panics: got %d, want 2", n)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(panics) != 2 || panics[0].Err != ErrPanic || panics[0].Type != Tread || panics[0].Tag != 2 || !strings.Contains(panics[0].Stack, "panicky") {
+		t.Errorf("want 2 panics observed, the first of Tread tag 2 with its stack, got %+v", panics)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	if _, err := NewListener(func() NineServer { return hello{} }, MaxFids(-1)); err == nil {
+		t.Errorf("MaxFids(-1): want err, got nil")
+	}
+	closed := make(chan bool, 2)
+	l, err := NewListener(func() NineServer {
+		return Chain()(struct {
+			hello
+			io.Closer
+		}{Closer: &hooked{closed: closed}})
+	}, MaxFids(2), MaxBuffered(64), MaxConns(1))
+	if err != nil {
+		t.Fatalf("NewListener: want nil, got %v", err)
+	}
+	p, p2 := net.Pipe()
+	defer p.Close()
+	if err := l.Accept(p2); err != nil {
+		t.Fatalf("Accept: want nil, got %v", err)
+	}
+	_, p3 := net.Pipe()
+	if err := l.Accept(p3); err != ErrTooManyConns {
+		t.Errorf("Accept over MaxConns: got %v, want %v", err, ErrTooManyConns)
+	}
+	select {
+	case <-closed:
+	default:
+		t.Errorf("Accept over MaxConns: want the NineServer made for it closed, it was not")
+	}
+
+	for i, v := range []struct {
+		b    []byte
+		want string
+	}{
+		{AppendTattach(nil, 1, 0, NOFID, "glenda", ""), "Rattach tag 1 qid (0000000000000000 0 d)"},
+		{AppendTwalk(nil, 1, 0, 1, nil), "Rwalk tag 1 nwqid 0"},
+		{AppendTwalk(nil, 1, 0, 2, nil), "Rerror tag 1 ename too many fids"},
+		{AppendTclunk(nil, 1, 1), "Rclunk tag 1"},
+		{AppendTwalk(nil, 1, 0, 2, nil), "Rwalk tag 1 nwqid 0"},
+	} {
+		r, err := rpc(p, v.b)
+		if err != nil {
+			t.Fatalf("%d: want nil, got %v", i, err)
+		}
+		if r.String() != v.want {
+			t.Errorf("%d: got %q, want %q", i, r, v.want)
+		}
+	}
+	// The server stops reading at the size, and a pipe has no buffer, so
+	// the write does not finish until the connection is closed.
+	go p.Write(AppendTwrite(nil, 1, 2, 0, make([]byte, 64)))
+	if m, err := readReply(p); err != nil || m.String() != "Rerror tag 1 ename message too big" {
+		t.Errorf("Twrite over MaxBuffered: got %v, %v, want message too big", m, err)
+	}
+	if _, err := rpc(p, AppendTclunk(nil, 1, 2)); err == nil {
+		t.Errorf("after a message over MaxBuffered: want the connection closed, got a reply")
+	}
+
+	st := l.Stats()
+	if st.TooManyFIDs != 1 || st.TooBig != 1 || st.TooManyConns != 1 || st.Limits.MaxFids != 2 {
+		t.Errorf("Stats: got %+v, want 1 of each limit hit and MaxFids 2", st)
+	}
+}
+
+// gate is hello, with Rattach waiting until c is closed.
+type gate struct {
+	hello
+	c chan struct{}
+}
+
+func (g gate) Rattach(fid FID, afid FID, uname string, aname string) (QID, error) {
+	<-g.c
+	return g.hello.Rattach(fid, afid, uname, aname)
+}
+
+func TestInFlight(t *testing.T) {
+	g := gate{c: make(chan struct{})}
+	l, err := NewListener(func() NineServer { return g }, MaxInFlight(2))
+	if err != nil {
+		t.Fatalf("NewListener: want nil, got %v", err)
+	}
+	p, p2 := net.Pipe()
+	defer p.Close()
+	if err := l.Accept(p2); err != nil {
+		t.Fatalf("Accept: want nil, got %v", err)
+	}
+
+	// The first Tattach holds up the rest, which are read while it
+	// waits: the second has its tag, which is in flight, and the fourth
+	// is one more than MaxInFlight.
+	for _, b := range [][]byte{
+		AppendTattach(nil, 1, 0, NOFID, "glenda", ""),
+		AppendTattach(nil, 1, 1, NOFID, "glenda", ""),
+		AppendTattach(nil, 3, 2, NOFID, "glenda", ""),
+		AppendTattach(nil, 4, 3, NOFID, "glenda", ""),
+	} {
+		if _, err := p.Write(b); err != nil {
+			t.Fatalf("Write: want nil, got %v", err)
+		}
+	}
+	for i := 0; l.Stats().TooManyInFlight == 0; i++ {
+		if i == 500 {
+			t.Fatalf("fourth Tattach never refused")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if c := l.Conns(); len(c) != 1 || !reflect.DeepEqual(c[0].Tags, []Tag{1, 3}) {
+		t.Errorf("Conns: got %+v, want tags 1 and 3 in flight", c)
+	}
+	close(g.c)
+
+	for _, want := range []string{
+		"Rattach tag 1 qid (0000000000000000 0 d)",
+		"Rerror tag 1 ename duplicate tag",
+		"Rattach tag 3 qid (0000000000000000 0 d)",
+		"Rerror tag 4 ename too many requests in flight",
+	} {
+		if r, err := readReply(p); err != nil || r.String() != want {
+			t.Errorf("got %v, %v, want %v", r, err, want)
+		}
+	}
+	if r, err := rpc(p, AppendTattach(nil, 1, 4, NOFID, "glenda", "")); err != nil || r.Type() != Rattach {
+		t.Errorf("Tattach with tag 1 once it has been answered: got %v, %v, want Rattach", r, err)
+	}
+}
+
+func TestBuffered(t *testing.T) {
+	g := gate{c: make(chan struct{})}
+	// Each Tattach is 25 bytes, so four fit.
+	l, err := NewListener(func() NineServer { return g }, MaxInFlight(8), MaxBuffered(100))
+	if err != nil {
+		t.Fatalf("NewListener: want nil, got %v", err)
+	}
+	p, p2 := net.Pipe()
+	defer p.Close()
+	if err := l.Accept(p2); err != nil {
+		t.Fatalf("Accept: want nil, got %v", err)
+	}
+
+	// The first Tattach holds up the rest; the fifth is not read until
+	// some of those before it have been answered.
+	go func() {
+		for i := 0; i < 5; i++ {
+			p.Write(AppendTattach(nil, Tag(i+1), FID(i), NOFID, "glenda", ""))
+		}
+	}()
+	for i := 0; l.Stats().BufferFull == 0; i++ {
+		if i == 500 {
+			t.Fatalf("fifth Tattach read while four were buffered")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if c := l.Conns(); len(c) != 1 || !reflect.DeepEqual(c[0].Tags, []Tag{1, 2, 3, 4}) {
+		t.Errorf("Conns: got %+v, want tags 1 to 4 in flight", c)
+	}
+	close(g.c)
+
+	for i := 1; i <= 5; i++ {
+		if r, err := readReply(p); err != nil || r.Type() != Rattach {
+			t.Errorf("Tattach %d: got %v, %v, want Rattach", i, r, err)
+		}
+	}
+	if st := l.Stats(); st.BufferFull != 1 || st.TooBig != 0 {
+		t.Errorf("Stats: got %+v, want BufferFull 1 and TooBig 0", st)
+	}
+}
+
+// counts is hello, keeping the counts it is asked to read.
+type counts struct {
+	hello
+	n []Count
+}
+
+func (c *counts) Rread(fid FID, o Offset, n Count) ([]byte, error) {
+	c.n = append(c.n, n)
+	return nil, nil
+}
+
+func TestReadCount(t *testing.T) {
+	ns := &counts{}
+	l, err := NewListener(func() NineServer { return ns })
+	if err != nil {
+		t.Fatalf("NewListener: want nil, got %v", err)
+	}
+	p, p2 := net.Pipe()
+	defer p.Close()
+	if err := l.Accept(p2); err != nil {
+		t.Fatalf("Accept: want nil, got %v", err)
+	}
+	for _, b := range [][]byte{
+		AppendTread(nil, 1, 1, 0, 1<<30),
+		AppendTversion(nil, NOTAG, 8192, "9P2000"),
+		AppendTread(nil, 1, 1, 0, 100),
+		AppendTread(nil, 1, 1, 0, 8192),
+		AppendTread(nil, 1, 1, 0, -1),
+	} {
+		if _, err := rpc(p, b); err != nil {
+			t.Fatalf("%v: want nil, got %v", RPCNames[MType(b[4])], err)
+		}
+	}
+	want := []Count{MSIZE - IOHDRSZ, 100, 8192 - IOHDRSZ, 8192 - IOHDRSZ}
+	if !reflect.DeepEqual(ns.n, want) {
+		t.Errorf("counts read: got %v, want %v", ns.n, want)
+	}
+}
+
+// fakeClock is a Clock whose time only passes when Advance is called.
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+// newFakeClock returns a fakeClock set to the start of 2019. The zero
+// time will not do, as it has no UnixNano.
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+type fakeTimer struct {
+	c    *fakeClock
+	when time.Time
+	f    func()
+	on   bool
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) AfterFunc(d time.Duration, f func()) Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &fakeTimer{c: c, when: c.now.Add(d), f: f, on: true}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.c.mu.Lock()
+	defer t.c.mu.Unlock()
+	on := t.on
+	t.on = false
+	return on
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	t.c.mu.Lock()
+	defer t.c.mu.Unlock()
+	on := t.on
+	t.when, t.on = t.c.now.Add(d), true
+	return on
+}
+
+// Advance moves the clock on by d and runs the timers that are due.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	var due []func()
+	for _, t := range c.timers {
+		if t.on && !t.when.After(c.now) {
+			t.on = false
+			due = append(due, t.f)
+		}
+	}
+	c.mu.Unlock()
+	for _, f := range due {
+		f()
+	}
+}
+
+// wait waits until n timers are set, as the code under test sets them in
+// its own goroutines. made waits until n have been made at all.
+func (c *fakeClock) wait(t *testing.T, n int) {
+	for start := time.Now(); time.Since(start) < 5*time.Second; time.Sleep(time.Millisecond) {
+		c.mu.Lock()
+		var on int
+		for _, t := range c.timers {
+			if t.on {
+				on++
+			}
+		}
+		c.mu.Unlock()
+		if on == n {
+			return
+		}
+	}
+	t.Fatalf("waited for %d timers to be set, gave up", n)
+}
+
+func (c *fakeClock) made(t *testing.T, n int) {
+	for start := time.Now(); time.Since(start) < 5*time.Second; time.Sleep(time.Millisecond) {
+		c.mu.Lock()
+		made := len(c.timers)
+		c.mu.Unlock()
+		if made == n {
+			return
+		}
+	}
+	t.Fatalf("waited for %d timers to be made, gave up", n)
+}
+
+func TestTimeout(t *testing.T) {
+	for _, v := range []struct {
+		n      string
+		opt    ListenerOpt
+		timers int
+		write  bool
+	}{
+		{"read", func(l *Listener) error { l.ReadTimeout = time.Second; return nil }, 1, false},
+		{"write", func(l *Listener) error { l.WriteTimeout = time.Second; return nil }, 1, true},
+		{"session", func(l *Listener) error { l.SessionTimeout = time.Second; return nil }, 1, false},
+	} {
+		clock := newFakeClock()
+		l, err := NewListener(func() NineServer { return hello{} }, v.opt, func(l *Listener) error {
+			l.Clock = clock
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("%v: NewListener: want nil, got %v", v.n, err)
+		}
+		p, p2 := net.Pipe()
+		if err := l.Accept(p2); err != nil {
+			t.Fatalf("%v: Accept: want nil, got %v", v.n, err)
+		}
+		if v.write {
+			// Send a message but don't read the reply.
+			if _, err := p.Write(AppendTattach(nil, 1, 0, NOFID, "glenda", "")); err != nil {
+				t.Fatalf("%v: Write: want nil, got %v", v.n, err)
+			}
+		}
+		clock.wait(t, v.timers)
+		clock.Advance(999 * time.Millisecond)
+		if st := l.Stats(); st.Timeouts != 0 {
+			t.Errorf("%v: before the timeout: got %d timeouts, want 0", v.n, st.Timeouts)
+		}
+		clock.Advance(time.Millisecond)
+		if v.write {
+			// The reply was cut off.
+			if _, err := readReply(p); err == nil {
+				t.Errorf("%v: after the timeout: want no reply, got one", v.n)
+			}
+		}
+		if _, err := rpc(p, AppendTattach(nil, 1, 0, NOFID, "glenda", "")); err == nil {
+			t.Errorf("%v: after the timeout: want the connection closed, got a reply", v.n)
+		}
+		if st := l.Stats(); st.Timeouts != 1 {
+			t.Errorf("%v: after the timeout: got %d timeouts, want 1", v.n, st.Timeouts)
+		}
+		p.Close()
+	}
+}
+
+func TestKeepAlive(t *testing.T) {
+	quiet := func(string, ...interface{}) {}
+	newClient := func(rwc net.Conn, clock Clock) *Client {
+		c, err := NewClient(func(c *Client) error {
+			c.FromNet, c.ToNet = rwc, rwc
+			c.Trace = quiet
+			c.KeepAlive = time.Second
+			c.Clock = clock
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	// A server that answers keeps the client alive.
+	clock := newFakeClock()
+	l, err := NewListener(func() NineServer { return hello{} })
+	if err != nil {
+		t.Fatalf("NewListener: want nil, got %v", err)
+	}
+	p, p2 := net.Pipe()
+	defer p.Close()
+	if err := l.Accept(p2); err != nil {
+		t.Fatalf("Accept: want nil, got %v", err)
+	}
+	c := newClient(p, clock)
+	// Each round makes a timer to wake up, and one for the Tflush that
+	// the server stops by answering.
+	for i := 0; i < 3; i++ {
+		clock.made(t, 2*i+1)
+		clock.Advance(time.Second)
+	}
+	clock.made(t, 7)
+	select {
+	case <-c.done:
+		t.Fatalf("client with a live server: want alive, got dead")
+	default:
+	}
+
+	// One that doesn't does not.
+	clock = newFakeClock()
+	p, p2 = net.Pipe()
+	defer p2.Close()
+	go io.Copy(ioutil.Discard, p2)
+	c = newClient(p, clock)
+	clock.made(t, 1)
+	clock.Advance(time.Second)
+	clock.made(t, 2)
+	clock.Advance(time.Second)
+	select {
+	case <-c.done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("client with a dead server: want dead, still alive")
+	}
+
+	// One that is slow to answer a request is not taken for dead: no
+	// Tflush is sent while the request waits, so each round makes just
+	// the timer to wake up.
+	clock = newFakeClock()
+	g := gate{c: make(chan struct{})}
+	l, err = NewListener(func() NineServer { return g })
+	if err != nil {
+		t.Fatalf("NewListener: want nil, got %v", err)
+	}
+	p, p2 = net.Pipe()
+	if err := l.Accept(p2); err != nil {
+		t.Fatalf("Accept: want nil, got %v", err)
+	}
+	c = newClient(p, clock)
+	attached := make(chan error, 1)
+	go func() {
+		_, err := c.CallTattach(0, NOFID, "glenda", "")
+		attached <- err
+	}()
+	for i := 0; c.waiting() == 0; i++ {
+		if i == 500 {
+			t.Fatalf("Tattach never sent")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	for i := 0; i < 3; i++ {
+		clock.made(t, i+1)
+		clock.Advance(time.Second)
+	}
+	clock.made(t, 4)
+	if c.dead() {
+		t.Fatalf("client with a slow server: want alive, got dead")
+	}
+	close(g.c)
+	if err := <-attached; err != nil {
+		t.Errorf("CallTattach: want nil, got %v", err)
+	}
+
+	// The timer to wake up is stopped when the client dies.
+	p.Close()
+	<-c.done
+	clock.wait(t, 0)
+}
+
+// TestScheduleShort checks that a Twrite too short to have a count is
+// charged no bytes, not a negative number of them.
+func TestScheduleShort(t *testing.T) {
+	s := NewScheduler(Rate{}, Rate{Bytes: 100})
+	limit := newLimiter(s.PerConn)
+	c := &conn{listener: &Listener{Clock: newFakeClock()}, limiter: limit}
+	c.schedule(s, bytes.NewBuffer([]byte{1, 0}), Twrite)
+	if limit.bytes.tokens != 100 {
+		t.Errorf("short Twrite: %v bytes left, want 100", limit.bytes.tokens)
+	}
+}
+
+func TestScheduler(t *testing.T) {
+	// acquire runs s.acquire in a goroutine and returns a chan that is
+	// closed when it returns.
+	acquire := func(s *Scheduler, clock Clock, conn *limiter, uname string, typ MType, n int) chan struct{} {
+		done := make(chan struct{})
+		go func() {
+			s.acquire(clock, conn, uname, typ, n, nil)
+			close(done)
+		}()
+		return done
+	}
+	isDone := func(c chan struct{}) bool {
+		select {
+		case <-c:
+			return true
+		case <-time.After(10 * time.Millisecond):
+			return false
+		}
+	}
+
+	// Two ops a second per user, in bursts of two.
+	clock := newFakeClock()
+	s := NewScheduler(Rate{Ops: 2}, Rate{})
+	conn := newLimiter(s.PerConn)
+	s.acquire(clock, conn, "glenda", Twalk, 0, nil)
+	s.acquire(clock, conn, "glenda", Tstat, 0, nil)
+	s.acquire(clock, newLimiter(s.PerConn), "rob", Tstat, 0, nil)
+	third := acquire(s, clock, conn, "glenda", Tclunk, 0)
+	clock.made(t, 1)
+	if isDone(third) {
+		t.Fatalf("third op in a second: want it to wait, it did not")
+	}
+	s.acquire(clock, conn, "glenda", Tflush, 0, nil)
+	clock.Advance(500 * time.Millisecond)
+	if !isDone(third) {
+		t.Fatalf("third op after half a second: want it through, it is not")
+	}
+
+	// Metadata goes first.
+	bulk := acquire(s, clock, conn, "glenda", Tread, 0)
+	clock.made(t, 2)
+	meta := acquire(s, clock, newLimiter(s.PerConn), "glenda", Tstat, 0)
+	clock.made(t, 3)
+	clock.Advance(500 * time.Millisecond)
+	if !isDone(meta) {
+		t.Fatalf("Tstat: want it through, it is not")
+	}
+	if isDone(bulk) {
+		t.Fatalf("Tread with a Tstat waiting: want it through after the Tstat, it went with it")
+	}
+	clock.made(t, 4)
+	clock.Advance(500 * time.Millisecond)
+	if !isDone(bulk) {
+		t.Fatalf("Tread: want it through, it is not")
+	}
+
+	// A hundred bytes a second per connection; a big read runs up a debt.
+	s = NewScheduler(Rate{}, Rate{Bytes: 100})
+	conn = newLimiter(s.PerConn)
+	s.acquire(clock, conn, "glenda", Tread, 1000, nil)
+	next := acquire(s, clock, conn, "glenda", Twrite, 10)
+	clock.made(t, 5)
+	clock.Advance(8 * time.Second)
+	if isDone(next) {
+		t.Fatalf("Twrite 8s after 1000 bytes: want it to wait, it did not")
+	}
+	clock.Advance(time.Second)
+	if !isDone(next) {
+		t.Fatalf("Twrite 9s after 1000 bytes: want it through, it is not")
+	}
+	if n := s.Waits(); n != 1 {
+		t.Errorf("Waits: got %d, want 1", n)
+	}
+
+	// An op every two seconds: a bucket holds one, not half of one.
+	s = NewScheduler(Rate{}, Rate{Ops: 0.5})
+	conn = newLimiter(s.PerConn)
+	s.acquire(clock, conn, "glenda", Tstat, 0, nil)
+	next = acquire(s, clock, conn, "glenda", Tstat, 0)
+	clock.made(t, 6)
+	clock.Advance(time.Second)
+	if isDone(next) {
+		t.Fatalf("op 1s after one at half an op a second: want it to wait, it did not")
+	}
+	clock.Advance(time.Second)
+	if !isDone(next) {
+		t.Fatalf("op 2s after one at half an op a second: want it through, it is not")
+	}
+
+	// A message gives up waiting when it is cancelled.
+	cancel := make(chan struct{})
+	var ok bool
+	next = make(chan struct{})
+	go func() {
+		ok = s.acquire(clock, conn, "glenda", Tstat, 0, cancel)
+		close(next)
+	}()
+	clock.made(t, 7)
+	close(cancel)
+	if !isDone(next) || ok {
+		t.Fatalf("cancelled op: want it to give up, it did not")
+	}
+
+	// Users whose buckets are full are dropped, once there are enough
+	// of them.
+	s = NewScheduler(Rate{Ops: 1}, Rate{})
+	for i := 0; i <= minEvict; i++ {
+		s.acquire(clock, newLimiter(s.PerConn), fmt.Sprint("user", i), Tstat, 0, nil)
+	}
+	if n := len(s.users); n != minEvict+1 {
+		t.Errorf("%d users with empty buckets: got %d kept, want all", minEvict+1, n)
+	}
+	clock.Advance(time.Second)
+	s.evict(clock.Now())
+	if n := len(s.users); n != 0 || s.evictAt != minEvict {
+		t.Errorf("users with full buckets: got %d kept, next sweep at %d, want none kept, sweep at %d", n, s.evictAt, minEvict)
+	}
+
+	// And through a Listener.
+	clock = newFakeClock()
+	l, err := NewListener(func() NineServer { return hello{} }, Schedule(Rate{}, Rate{Ops: 1}), func(l *Listener) error {
+		l.Clock = clock
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("NewListener: want nil, got %v", err)
+	}
+	p, p2 := net.Pipe()
+	defer p.Close()
+	if err := l.Accept(p2); err != nil {
+		t.Fatalf("Accept: want nil, got %v", err)
+	}
+	if _, err := rpc(p, AppendTattach(nil, 1, 0, NOFID, "glenda", "")); err != nil {
+		t.Fatalf("Tattach: want nil, got %v", err)
+	}
+	reply := make(chan error)
+	go func() {
+		_, err := rpc(p, AppendTclunk(nil, 1, 0))
+		reply <- err
+	}()
+	clock.made(t, 1)
+	clock.Advance(time.Second)
+	if err := <-reply; err != nil {
+		t.Fatalf("Tclunk: want nil, got %v", err)
+	}
+	if st := l.Stats(); st.Waits != 1 {
+		t.Errorf("Stats: got %d waits, want 1", st.Waits)
+	}
+
+	// A connection that closes while a message waits is let go.
+	q, q2 := net.Pipe()
+	if err := l.Accept(q2); err != nil {
+		t.Fatalf("Accept: want nil, got %v", err)
+	}
+	if _, err := rpc(q, AppendTattach(nil, 1, 0, NOFID, "glenda", "")); err != nil {
+		t.Fatalf("Tattach: want nil, got %v", err)
+	}
+	if _, err := q.Write(AppendTclunk(nil, 1, 0)); err != nil {
+		t.Fatalf("Write: want nil, got %v", err)
+	}
+	clock.made(t, 2)
+	q.Close()
+	for i := 0; l.Stats().Conns != 1; i++ {
+		if i == 500 {
+			t.Fatalf("connection closed while waiting: still served")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Shutdown stops messages waiting.
+	refused := make(chan string)
+	go func() {
+		r, err := rpc(p, AppendTclunk(nil, 1, 0))
+		refused <- fmt.Sprint(r, err)
+	}()
+	clock.made(t, 3)
+	l.Shutdown()
+	if got, want := <-refused, "Rerror tag 1 ename server shutting down <nil>"; got != want {
+		t.Errorf("Tclunk waiting at Shutdown: got %v, want %v", got, want)
+	}
+	if _, err := readReply(p); err != io.EOF {
+		t.Errorf("read after Shutdown refused a message: got %v, want EOF", err)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	sm, cm := NewMetrics("q9p_server"), NewMetrics("q9p_client")
+	p, p2 := net.Pipe()
+	defer p.Close()
+	c, err := NewClient(func(c *Client) error {
+		c.FromNet, c.ToNet = p, p
+		c.Trace = func(string, ...interface{}) {}
+		c.Metrics = cm
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := NewListener(func() NineServer { return hello{} }, func(l *Listener) error {
+		l.Metrics = sm
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("NewListener: want nil, got %v", err)
+	}
+	if err := l.Accept(p2); err != nil {
+		t.Fatalf("Accept: want nil, got %v", err)
+	}
+
+	if _, err := c.CallTattach(0, NOFID, "glenda", ""); err != nil {
+		t.Fatalf("CallTattach: want nil, got %v", err)
+	}
+	for _, f := range []FID{1, 2} {
+		if _, err := c.CallTwalk(0, f, []string{"hello"}); err != nil {
+			t.Fatalf("CallTwalk(hello): want nil, got %v", err)
+		}
+	}
+	if _, err := c.CallTwalk(0, 3, []string{"goodbye"}); err == nil {
+		t.Fatalf("CallTwalk(goodbye): want err, got nil")
+	}
+	if err := c.CallTclunk(1); err != nil {
+		t.Fatalf("CallTclunk: want nil, got %v", err)
+	}
+	if _, _, err := c.CallTopen(2, OREAD); err != nil {
+		t.Fatalf("CallTopen: want nil, got %v", err)
+	}
+	if _, err := c.CallTread(2, 0, 100); err != nil {
+		t.Fatalf("CallTread: want nil, got %v", err)
+	}
+	// A Tremove that fails still clunks its fid.
+	if _, err := c.CallTwalk(0, 4, []string{"hello"}); err != nil {
+		t.Fatalf("CallTwalk(hello): want nil, got %v", err)
+	}
+	if err := c.CallTremove(4); err == nil {
+		t.Fatalf("CallTremove: want err, got nil")
+	}
+
+	for _, m := range []*Metrics{sm, cm} {
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+		out := w.Body.String()
+		for _, want := range []string{
+			"# TYPE %v_requests_total counter\n",
+			"%v_requests_total{type=\"Twalk\"} 4\n",
+			"%v_request_duration_seconds_count{type=\"Tattach\"} 1\n",
+			"%v_request_duration_seconds_bucket{type=\"Tclunk\",le=\"+Inf\"} 1\n",
+			"%v_errors_total{errno=\"ENOENT\"} 1\n",
+			"%v_errors_total{errno=\"EIO\"} 1\n",
+			"%v_error_strings_total{error=\"file does not exist\"} 1\n",
+			"%v_error_strings_total{error=\"remove prohibited\"} 1\n",
+			"%v_connections 1\n",
+			"%v_fids 2\n",
+			"%v_inflight 0\n",
+			"%v_read_bytes_total 13\n",
+		} {
+			want = fmt.Sprintf(want, m.Namespace)
+			if !strings.Contains(out, want) {
+				t.Errorf("%v: want %q in\n%v", m.Namespace, want, out)
+			}
+		}
+	}
+
+	// Past MaxErrorStrings, new strings are counted together.
+	m := NewMetrics("q9p")
+	for i := 0; i <= MaxErrorStrings; i++ {
+		m.countString(fmt.Sprintf("no file %d", i))
+	}
+	m.countString("no file 0")
+	if len(m.strings) != MaxErrorStrings+1 || m.strings["no file 0"] != 2 || m.strings[otherErrors] != 1 {
+		t.Errorf("after %d error strings: got %d series, %d of the first and %d other; want %d, 2 and 1",
+			MaxErrorStrings+1, len(m.strings), m.strings["no file 0"], m.strings[otherErrors], MaxErrorStrings+1)
+	}
+
+	if got, want := escapeLabel("a \"b\"\\\n"), `a \"b\"\\\n`; got != want {
+		t.Errorf("escapeLabel: got %v, want %v", got, want)
+	}
+}
+
+func TestCtl(t *testing.T) {
+	l, err := NewListener(func() NineServer { return hello{} }, func(l *Listener) error {
+		l.FidTable = true
+		l.CtlAname = DefaultCtlAname
+		l.CtlUsers = []string{"rob"}
+		l.CtlAuth = func(c net.Conn, uname string) bool { return c != nil && uname == "rob" }
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("NewListener: want nil, got %v", err)
+	}
+	p, p2 := net.Pipe()
+	defer p.Close()
+	if err := l.Accept(p2); err != nil {
+		t.Fatalf("Accept: want nil, got %v", err)
+	}
+	if _, err := rpc(p, AppendTattach(nil, 1, 0, NOFID, "glenda", "")); err != nil {
+		t.Fatalf("Tattach: want nil, got %v", err)
+	}
+
+	q, q2 := net.Pipe()
+	defer q.Close()
+	if err := l.Accept(q2); err != nil {
+		t.Fatalf("Accept: want nil, got %v", err)
+	}
+	c, err := NewClient(func(c *Client) error {
+		c.FromNet, c.ToNet = q, q
+		c.Trace = func(string, ...interface{}) {}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.CallTattach(0, NOFID, "rob", DefaultCtlAname); err != nil {
+		t.Fatalf("CallTattach(%v): want nil, got %v", DefaultCtlAname, err)
+	}
+	var fid FID = 1
+	read := func(path ...string) (string, error) {
+		fid++
+		if _, err := c.CallTwalk(0, fid, path); err != nil {
+			return "", err
+		}
+		defer c.CallTclunk(fid)
+		if _, _, err := c.CallTopen(fid, OREAD); err != nil {
+			return "", err
+		}
+		b, err := c.CallTread(fid, 0, 8192)
+		return string(b), err
+	}
+	for _, v := range []struct {
+		path []string
+		want string
+	}{
+		{[]string{"conns", "1", "uname"}, "glenda\n"},
+		{[]string{"conns", "1", "remote"}, "pipe\n"},
+		{[]string{"conns", "1", "fids"}, "0\n"},
+		{[]string{"conns", "2", "stats"}, "fids 2\nopen 0\ntags 1\n"},
+		{[]string{"ctl"}, "trace off\n"},
+	} {
+		got, err := read(v.path...)
+		if err != nil || got != v.want {
+			t.Errorf("read %v: got %q, %v, want %q, nil", v.path, got, err, v.want)
+		}
+	}
+	if st, err := read("stats"); err != nil || !strings.HasPrefix(st, "conns 2\nfids 3\n") {
+		t.Errorf("read stats: got %q, %v, want conns 2 and fids 3 first", st, err)
+	}
+	b, err := read("conns")
+	if err != nil {
+		t.Fatalf("read conns: want nil, got %v", err)
+	}
+	var names []string
+	for bb := bytes.NewBuffer([]byte(b)); bb.Len() > 0; {
+		d, err := Unmarshaldir(bb)
+		if err != nil {
+			t.Fatalf("read conns: %v", err)
+		}
+		names = append(names, d.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"1", "2"}) {
+		t.Errorf("read conns: got %v, want [1 2]", names)
+	}
+
+	// Directories are read in whole entries: each read here has room
+	// for one and a half.
+	fid++
+	if _, err := c.CallTwalk(0, fid, nil); err != nil {
+		t.Fatalf("walk to the root: want nil, got %v", err)
+	}
+	if _, _, err := c.CallTopen(fid, OREAD); err != nil {
+		t.Fatalf("open the root: want nil, got %v", err)
+	}
+	all, err := c.CallTread(fid, 0, 8192)
+	if err != nil {
+		t.Fatalf("read the root: want nil, got %v", err)
+	}
+	n := Count(len(all) / 3 * 3 / 2)
+	names = nil
+	var o Offset
+	for {
+		b, err := c.CallTread(fid, o, n)
+		if err != nil {
+			t.Fatalf("read the root at %d: want nil, got %v", o, err)
+		}
+		if len(b) == 0 {
+			break
+		}
+		o += Offset(len(b))
+		bb := bytes.NewBuffer(b)
+		d, err := Unmarshaldir(bb)
+		if err != nil || bb.Len() != 0 {
+			t.Fatalf("read the root: got %v and %d bytes more, want one whole entry", err, bb.Len())
+		}
+		names = append(names, d.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"conns", "ctl", "stats"}) {
+		t.Errorf("read the root: got %v, want [conns ctl stats]", names)
+	}
+	if _, err := c.CallTread(fid, 1, 8192); err == nil {
+		t.Errorf("read the root at offset 1: want err, got nil")
+	}
+	if _, err := c.CallTread(fid, 0, 10); err == nil {
+		t.Errorf("read the root with count 10: want err, got nil")
+	}
+	c.CallTclunk(fid)
+	if q, err := c.CallTwalk(0, 99, []string{"conns", "3"}); err != nil || len(q) != 1 {
+		t.Errorf("walk to conns/3: got %v, %v, want 1 QID and nil", q, err)
+	}
+
+	fid++
+	if _, err := c.CallTwalk(0, fid, []string{"ctl"}); err != nil {
+		t.Fatalf("walk to ctl: want nil, got %v", err)
+	}
+	if _, _, err := c.CallTopen(fid, OWRITE); err != nil {
+		t.Fatalf("open ctl: want nil, got %v", err)
+	}
+	if _, err := c.CallTwrite(fid, 0, []byte("frob\n")); err == nil {
+		t.Errorf("write frob to ctl: want err, got nil")
+	}
+	for _, cmd := range []string{"trace on\n", "kill 1\n"} {
+		if _, err := c.CallTwrite(fid, 0, []byte(cmd)); err != nil {
+			t.Errorf("write %q to ctl: want nil, got %v", cmd, err)
+		}
+	}
+	ctl := fid
+	if got, err := read("ctl"); err != nil || got != "trace on\n" {
+		t.Errorf("read ctl after trace on: got %q, %v, want trace on", got, err)
+	}
+	if _, err := rpc(p, AppendTclunk(nil, 1, 0)); err == nil {
+		t.Errorf("after kill 1: want conn 1 closed, got a reply")
+	}
+	if _, err := c.CallTwrite(ctl, 0, []byte("trace off\n")); err != nil {
+		t.Errorf("write trace off to ctl: want nil, got %v", err)
+	}
+
+	// Only CtlUsers may attach, and with none, nobody may; nor may they
+	// on a connection CtlAuth does not vouch for, or without CtlAuth.
+	if _, err := c.CallTattach(100, NOFID, "glenda", DefaultCtlAname); !errors.Is(err, os.ErrPermission) {
+		t.Errorf("CallTattach as glenda, not in CtlUsers: got %v, want os.ErrPermission", err)
+	}
+	l.CtlAuth = func(net.Conn, string) bool { return false }
+	if _, err := c.CallTattach(100, NOFID, "rob", DefaultCtlAname); !errors.Is(err, os.ErrPermission) {
+		t.Errorf("CallTattach as rob, refused by CtlAuth: got %v, want os.ErrPermission", err)
+	}
+	l.CtlAuth = nil
+	if _, err := c.CallTattach(100, NOFID, "rob", DefaultCtlAname); !errors.Is(err, os.ErrPermission) {
+		t.Errorf("CallTattach as rob, with no CtlAuth: got %v, want os.ErrPermission", err)
+	}
+	l.CtlUsers = nil
+	if _, err := c.CallTattach(100, NOFID, "rob", DefaultCtlAname); !errors.Is(err, os.ErrPermission) {
+		t.Errorf("CallTattach as rob, with no CtlUsers: got %v, want os.ErrPermission", err)
+	}
+}
+
+// TestCtlOpen checks that the control tree refuses I/O on fids that are
+// not open for it, on a Listener with no FidTable to do so.
+func TestCtlOpen(t *testing.T) {
+	l, err := NewListener(func() NineServer { return hello{} }, func(l *Listener) error {
+		l.CtlAname = DefaultCtlAname
+		l.CtlUsers = []string{"rob"}
+		l.CtlAuth = func(net.Conn, string) bool { return true }
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("NewListener: want nil, got %v", err)
+	}
+	p, p2 := net.Pipe()
+	defer p.Close()
+	if err := l.Accept(p2); err != nil {
+		t.Fatalf("Accept: want nil, got %v", err)
+	}
+	for i, v := range []struct {
+		b    []byte
+		want string
+	}{
+		{AppendTattach(nil, 1, 0, NOFID, "rob", DefaultCtlAname), "Rattach tag 1 qid (0000000000000000 0 d)"},
+		{AppendTwalk(nil, 1, 0, 1, []string{"ctl"}), "Rwalk tag 1 nwqid 1 0:(0000000000000002 0 )"},
+		{AppendTwrite(nil, 1, 1, 0, []byte("trace on\n")), "Rerror tag 1 ename fid not open"},
+		{AppendTread(nil, 1, 1, 0, 100), "Rerror tag 1 ename fid not open"},
+		{AppendTopen(nil, 1, 1, OREAD), "Ropen tag 1 qid (0000000000000002 0 ) iounit 0"},
+		{AppendTwrite(nil, 1, 1, 0, []byte("trace on\n")), "Rerror tag 1 ename fid not open for writing"},
+		{AppendTwalk(nil, 1, 0, 2, []string{"ctl"}), "Rwalk tag 1 nwqid 1 0:(0000000000000002 0 )"},
+		{AppendTopen(nil, 1, 2, OWRITE), "Ropen tag 1 qid (0000000000000002 0 ) iounit 0"},
+		{AppendTread(nil, 1, 2, 0, 100), "Rerror tag 1 ename fid not open for reading"},
+	} {
+		r, err := rpc(p, v.b)
+		if err != nil {
+			t.Fatalf("%d: want nil, got %v", i, err)
+		}
+		if r.String() != v.want {
+			t.Errorf("%d: got %q, want %q", i, r, v.want)
+		}
+	}
+	if l.tracingOn() {
+		t.Errorf("write to ctl through fids not open for it: want tracing off, got on")
+	}
+}
+
+// spanner is hello, with a span of its own in each Rread.
+type spanner struct {
+	hello
+	span *Span
+}
+
+func (s *spanner) SetSpan(sp *Span) {
+	s.span = sp
+}
+
+func (s *spanner) Rread(fid FID, o Offset, c Count) ([]byte, error) {
+	sp := s.span.Child("lookup")
+	defer sp.End()
+	return s.hello.Rread(fid, o, c)
+}
+
+func TestSpans(t *testing.T) {
+	d, err := ioutil.TempDir("", "spans")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(d)
+	path := d + "/spans.json"
+	spans, err := NewSpanLog(path, 0, 0)
+	if err != nil {
+		t.Fatalf("NewSpanLog: want nil, got %v", err)
+	}
+	l, err := NewListener(func() NineServer { return &spanner{} }, func(l *Listener) error {
+		l.Spans = spans
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("NewListener: want nil, got %v", err)
+	}
+	p, p2 := net.Pipe()
+	if err := l.Accept(p2); err != nil {
+		t.Fatalf("Accept: want nil, got %v", err)
+	}
+	c, err := NewClient(func(c *Client) error {
+		c.FromNet, c.ToNet = p, p
+		c.Trace = func(string, ...interface{}) {}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.CallTattach(0, NOFID, "glenda", ""); err != nil {
+		t.Fatalf("CallTattach: want nil, got %v", err)
+	}
+	if _, err := c.CallTwalk(0, 1, []string{"hello"}); err != nil {
+		t.Fatalf("CallTwalk: want nil, got %v", err)
+	}
+	if _, _, err := c.CallTopen(1, OREAD); err != nil {
+		t.Fatalf("CallTopen: want nil, got %v", err)
+	}
+	if _, err := c.CallTread(1, 0, 100); err != nil {
+		t.Fatalf("CallTread: want nil, got %v", err)
+	}
+	// The spans of a message end after its reply is written, so wait
+	// for the connection to go.
+	p.Close()
+	for i := 0; len(l.Conns()) > 0; i++ {
+		if i == 100 {
+			t.Fatalf("connection still open after closing the pipe")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := spans.Close(); err != nil {
+		t.Fatalf("Close: want nil, got %v", err)
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var events []traceEvent
+	if err := json.Unmarshal(b, &events); err != nil {
+		t.Fatalf("%v is not JSON: %v\n%s", path, err, b)
+	}
+	byName := make(map[string][]traceEvent)
+	for _, e := range events {
+		byName[e.Name] = append(byName[e.Name], e)
+	}
+	if e := byName["thread_name"]; len(e) != 1 || e[0].Ph != "M" || e[0].Args["name"] != "conn 1 pipe" {
+		t.Errorf("thread_name: got %+v, want one naming conn 1", e)
+	}
+	for _, n := range []string{"receive", "handler", "write"} {
+		if len(byName[n]) != 4 {
+			t.Errorf("%v: got %d spans, want 4", n, len(byName[n]))
+		}
+	}
+	r := byName["Tread"]
+	if len(r) != 1 {
+		t.Fatalf("Tread: got %d spans, want 1", len(r))
+	}
+	// The Client picks the tag.
+	want := map[string]interface{}{"conn": 1.0, "type": "Tread", "tag": r[0].Args["tag"], "fid": 1.0}
+	if _, ok := r[0].Args["tag"].(float64); !ok || !reflect.DeepEqual(r[0].Args, want) || r[0].Ph != "X" || r[0].Tid != 1 {
+		t.Errorf("Tread: got %+v, want a complete span on thread 1 with args %v", r[0], want)
+	}
+	lk := byName["lookup"]
+	if len(lk) != 1 || lk[0].Ts < r[0].Ts || lk[0].Ts+lk[0].Dur > r[0].Ts+r[0].Dur {
+		t.Errorf("lookup: got %+v, want one span within %+v", lk, r[0])
+	}
+
+	// A full file is rotated, and the old one is still JSON.
+	spans, err = NewSpanLog(path, 200, 1)
+	if err != nil {
+		t.Fatalf("NewSpanLog: want nil, got %v", err)
+	}
+	// Each file names the threads open when it was started.
+	spans.name(1, "conn 1")
+	spans.name(2, "conn 2")
+	spans.unname(2)
+	for i := 0; i < 3; i++ {
+		spans.span(1, fmt.Sprintf("span %d", i)).End()
+	}
+	if err := spans.Close(); err != nil {
+		t.Fatalf("Close: want nil, got %v", err)
+	}
+	for _, p := range []string{path, path + ".1"} {
+		b, err := ioutil.ReadFile(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		events = nil
+		if err := json.Unmarshal(b, &events); err != nil || len(events) == 0 {
+			t.Errorf("%v: got %d events, %v, want some and nil", p, len(events), err)
+		}
+	}
+	if len(events) == 0 || events[0].Name != "thread_name" || events[0].Tid != 1 || events[0].Args["name"] != "conn 1" {
+		t.Errorf("%v.1: got %+v, want it to start naming thread 1", path, events)
+	}
+	for _, e := range events[1:] {
+		if e.Name == "thread_name" {
+			t.Errorf("%v.1: got %+v, want only thread 1 named", path, e)
+		}
+	}
+	if _, err := os.Stat(path + ".2"); err == nil {
+		t.Errorf("%v.2: want only one old file kept", path)
+	}
+}
+
+// events collects Events.
+type events struct {
+	mu sync.Mutex
+	e  []Event
+}
+
+func (ev *events) Observe(e Event) {
+	ev.mu.Lock()
+	defer ev.mu.Unlock()
+	ev.e = append(ev.e, e)
+}
+
+// strings returns the events as strings, leaving out durations and the
+// like, which change from run to run.
+func (ev *events) strings() []string {
+	ev.mu.Lock()
+	defer ev.mu.Unlock()
+	var s []string
+	for _, e := range ev.e {
+		switch e := e.(type) {
+		case MessageReceived:
+			e.Duration = 0
+			s = append(s, e.String())
+		case MessageSent:
+			e.Duration = 0
+			s = append(s, e.String())
+		default:
+			s = append(s, e.String())
+		}
+	}
+	return s
+}
+
+func TestObserver(t *testing.T) {
+	var se, ce events
+	l, err := NewListener(func() NineServer { return hello{} }, func(l *Listener) error {
+		l.Observer = &se
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("NewListener: want nil, got %v", err)
+	}
+	p, p2 := net.Pipe()
+	if err := l.Accept(p2); err != nil {
+		t.Fatalf("Accept: want nil, got %v", err)
+	}
+	c, err := NewClient(func(c *Client) error {
+		c.FromNet, c.ToNet = p, p
+		c.Observer = &ce
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.CallTattach(0, NOFID, "glenda", ""); err != nil {
+		t.Fatalf("CallTattach: want nil, got %v", err)
+	}
+	if _, err := c.CallTwalk(0, 1, []string{"goodbye"}); err == nil {
+		t.Fatalf("CallTwalk(goodbye): want err, got nil")
+	}
+	p.Close()
+	for i := 0; len(l.Conns()) > 0; i++ {
+		if i == 100 {
+			t.Fatalf("connection still open after closing the pipe")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	want := []string{
+		"conn 1 from pipe: opened",
+		"conn 1: <- Tattach tag 1, 25 bytes",
+		"conn 1: -> Rattach tag 1, 20 bytes",
+		"conn 1: <- Twalk tag 2, 26 bytes",
+		"conn 1: Twalk tag 2: file does not exist",
+		"conn 1: -> Rerror tag 2, 28 bytes",
+		"conn 1 from pipe: closed: EOF",
+	}
+	if got := se.strings(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Listener events: got\n%v\nwant\n%v", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	want = []string{
+		"client: -> Tattach tag 1, 25 bytes",
+		"client: <- Rattach tag 1, 20 bytes",
+		"client: -> Twalk tag 2, 26 bytes",
+		"client: <- Rerror tag 2, 28 bytes",
+		"client: dead: readNetPackets: short read: io: read/write on closed pipe",
+	}
+	if got := ce.strings(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Client events: got\n%v\nwant\n%v", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	// A Tracer gets the events as lines.
+	var lines []string
+	Tracer(func(f string, args ...interface{}) {
+		lines = append(lines, fmt.Sprintf(f, args...))
+	}).Observe(ConnOpened{Conn: 2, Remote: "net!host!564"})
+	if len(lines) != 1 || lines[0] != "conn 2 from net!host!564: opened" {
+		t.Errorf("Tracer: got %q, want the event as a line", lines)
+	}
+
+	var b bytes.Buffer
+	LogObserver(log.New(&b, "", 0)).Observe(AcceptError{Err: ErrTooManyConns, Remote: "pipe"})
+	if got, want := b.String(), "accept pipe: too many connections\n"; got != want {
+		t.Errorf("LogObserver: got %q, want %q", got, want)
+	}
+
+	now := time.Date(2019, 6, 1, 12, 0, 0, 1000, time.UTC)
+	for _, v := range []struct {
+		e    Event
+		want string
+	}{
+		{MessageSent{Conn: 1, Type: Rread, Tag: 3, Size: 40, Duration: 123 * time.Microsecond},
+			`{"time":"2019-06-01T12:00:00.000001Z","event":"MessageSent","conn":1,"type":"Rread","tag":3,"size":40,"duration":0.000123}`},
+		{DispatchError{Conn: 1, Type: Twalk, Tag: 2, Err: ErrNoWalk},
+			`{"time":"2019-06-01T12:00:00.000001Z","event":"DispatchError","conn":1,"type":"Twalk","tag":2,"err":"file does not exist","panic":null,"stack":""}`},
+		{ClientDead{},
+			`{"time":"2019-06-01T12:00:00.000001Z","event":"ClientDead","err":null}`},
+	} {
+		var m map[string]interface{}
+		got := jsonEvent(now, v.e)
+		if string(got) != v.want+"\n" || json.Unmarshal(got, &m) != nil {
+			t.Errorf("jsonEvent(%v): got %s, want %s", v.e, got, v.want)
+		}
+	}
+}
+
 func TestTags(t *testing.T) {
 	c, err := NewClient()
 	if err != nil {
@@ -237,7 +2178,7 @@ func (e *echo) Rflush(o Tag) error {
 
 func (e *echo) Rwalk(fid FID, newfid FID, paths []string) ([]QID, error) {
 	//fmt.Printf("walk(%d, %d, %d, %v\n", fid, newfid, len(paths), paths)
-	if len(paths) > 1 {
+	if len(paths) != 1 {
 		return nil, nil
 	}
 	switch paths[0] {
@@ -327,15 +2268,14 @@ func TestTManyRPCs(t *testing.T) {
 	if err != nil {
 		t.Fatalf("%v", err)
 	}
-	t.Logf("Client is %v", c.String())
 
 	e := newEcho()
-	s, err := NewServer(e, func(s *Server) error {
-		s.Trace = print
+	s, err := NewListener(func() NineServer { return e }, func(l *Listener) error {
+		l.Trace = print
 		return nil
 	})
 	if err != nil {
-		t.Fatalf("NewServer: want nil, got %v", err)
+		t.Fatalf("NewListener: want nil, got %v", err)
 	}
 
 	if err := s.Accept(p2); err != nil {
@@ -365,17 +2305,15 @@ func TestTMessages(t *testing.T) {
 	if err != nil {
 		t.Fatalf("%v", err)
 	}
-	t.Logf("Client is %v", c.String())
 
 	e := newEcho()
-	s, err := NewServer(e, func(s *Server) error {
-		s.Trace = print // t.Logf
-		s.NS = e
+	s, err := NewListener(func() NineServer { return e }, func(l *Listener) error {
+		l.Trace = print // t.Logf
 		return nil
 	})
 
 	if err != nil {
-		t.Fatalf("NewServer: want nil, got %v", err)
+		t.Fatalf("NewListener: want nil, got %v", err)
 	}
 
 	if err := s.Accept(p2); err != nil {
@@ -488,6 +2426,128 @@ func TestTMessages(t *testing.T) {
 	}
 }
 
+// sum is a test extension: Tsum fid, Rsum sum.
+const (
+	tsum MType = 200
+	rsum MType = 201
+)
+
+type sumMsg struct {
+	t   MType
+	tag Tag
+	v   uint32
+}
+
+func (m *sumMsg) Type() MType { return m.t }
+func (m *sumMsg) Tag() Tag    { return m.tag }
+func (m *sumMsg) Encode(b *bytes.Buffer) {
+	b.Reset()
+	b.Write([]byte{11, 0, 0, 0, uint8(m.t), uint8(m.tag), uint8(m.tag >> 8),
+		uint8(m.v), uint8(m.v >> 8), uint8(m.v >> 16), uint8(m.v >> 24)})
+}
+func (m *sumMsg) String() string { return fmt.Sprintf("%d tag %d %d", m.t, m.tag, m.v) }
+
+func decodeSum(t MType) func(b *bytes.Buffer) (Msg, error) {
+	return func(b *bytes.Buffer) (Msg, error) {
+		if b.Len() != 6 {
+			return nil, fmt.Errorf("sum: %d bytes, want 6", b.Len())
+		}
+		u := b.Next(6)
+		return &sumMsg{t: t, tag: Tag(u[0]) | Tag(u[1])<<8,
+			v: uint32(u[2]) | uint32(u[3])<<8 | uint32(u[4])<<16 | uint32(u[5])<<24}, nil
+	}
+}
+
+func newSumRegistry(t *testing.T) *Registry {
+	r := NewRegistry()
+	err := r.Register(&Extension{
+		Name:    "sum",
+		T:       tsum,
+		R:       rsum,
+		DecodeT: decodeSum(tsum),
+		DecodeR: decodeSum(rsum),
+		Handler: func(s *Server, m Msg) (Msg, error) {
+			v := m.(*sumMsg).v
+			if v == 0 {
+				return nil, fmt.Errorf("no sum for fid 0")
+			}
+			return &sumMsg{t: rsum, tag: m.Tag(), v: v * 2}, nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("Register: want nil, got %v", err)
+	}
+	return r
+}
+
+func TestExtension(t *testing.T) {
+	r := newSumRegistry(t)
+	if err := r.Register(&Extension{Name: "sum2", T: tsum, R: rsum + 2, DecodeT: decodeSum(tsum), DecodeR: decodeSum(rsum)}); err == nil {
+		t.Errorf("Register with a used type: want err, got nil")
+	}
+	if err := r.Register(&Extension{Name: "walk", T: Twalk, R: Rwalk, DecodeT: decodeSum(tsum), DecodeR: decodeSum(rsum)}); err == nil {
+		t.Errorf("Register with Twalk: want err, got nil")
+	}
+
+	for _, v := range []struct {
+		n      string
+		client *Registry
+		server *Registry
+		ok     bool
+	}{
+		{"both", r, newSumRegistry(t), true},
+		{"client only", r, nil, false},
+		{"server only", nil, newSumRegistry(t), false},
+		{"neither", NewRegistry(), NewRegistry(), false},
+	} {
+		p, p2 := net.Pipe()
+		c, err := NewClient(func(c *Client) error {
+			c.FromNet, c.ToNet = p, p
+			c.Trace = t.Logf
+			c.Extensions = v.client
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("%v: %v", v.n, err)
+		}
+		l, err := NewListener(func() NineServer { return newEcho() }, func(l *Listener) error {
+			l.Extensions = v.server
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("%v: NewListener: want nil, got %v", v.n, err)
+		}
+		if err := l.Accept(p2); err != nil {
+			t.Fatalf("%v: Accept: want nil, got %v", v.n, err)
+		}
+
+		_, ver, err := c.Version(8000, "9P2000")
+		if err != nil {
+			t.Fatalf("%v: Version: want nil, got %v", v.n, err)
+		}
+		if ver != "9P2000" {
+			t.Errorf("%v: Version: got %q, want \"9P2000\"", v.n, ver)
+		}
+
+		m, err := c.CallExtension(&sumMsg{t: tsum, v: 21})
+		if !v.ok {
+			if err == nil {
+				t.Errorf("%v: CallExtension: want err, got %v", v.n, m)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%v: CallExtension: want nil, got %v", v.n, err)
+		}
+		if m.Type() != rsum || m.(*sumMsg).v != 42 {
+			t.Errorf("%v: CallExtension: got %v, want Rsum 42", v.n, m)
+		}
+		if _, err := c.CallExtension(&sumMsg{t: tsum}); err == nil {
+			t.Errorf("%v: CallExtension for fid 0: want err, got nil", v.n)
+		}
+	}
+}
+
 func BenchmarkNull(b *testing.B) {
 	p, p2 := net.Pipe()
 
@@ -502,23 +2562,18 @@ func BenchmarkNull(b *testing.B) {
 	if err != nil {
 		b.Fatalf("%v", err)
 	}
-	b.Logf("Client is %v", c.String())
 
 	e := newEcho()
-	s, err := NewServer(e, func(s *Server) error {
-		s.NS = e
-		return nil
-	})
+	s, err := NewListener(func() NineServer { return e })
 
 	if err != nil {
-		b.Fatalf("NewServer: want nil, got %v", err)
+		b.Fatalf("NewListener: want nil, got %v", err)
 	}
 
 	if err := s.Accept(p2); err != nil {
 		b.Fatalf("Accept: want nil, got %v", err)
 	}
 
-	b.Logf("%d iterations", b.N)
 	for i := 0; i < b.N; i++ {
 		if _, err := c.CallTread(FID(2), 0, 5); err != nil {
 			b.Fatalf("CallTread: want nil, got %v", err)
@@ -526,3 +2581,62 @@ func BenchmarkNull(b *testing.B) {
 	}
 
 }
+
+// The Tread benchmarks do what a client and server do between them for
+// one Tread: encode and decode the Tread, then the Rread, each into a
+// new buffer as CallTread does. Marshal uses the bytes.Buffer functions,
+// Append the []byte ones.
+var benchData = make([]byte, 4096)
+
+func BenchmarkTreadMarshal(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var t, r bytes.Buffer
+		MarshalTreadPkt(&t, 1, 2, 0, Count(len(benchData)))
+		t.Next(5)
+		fid, off, n, tag, err := UnmarshalTreadPkt(&t)
+		if err != nil {
+			b.Fatalf("UnmarshalTreadPkt: want nil, got %v", err)
+		}
+		_, _ = fid, off
+		MarshalRreadPkt(&r, tag, benchData[:n])
+		r.Next(5)
+		if _, _, err := UnmarshalRreadPkt(&r); err != nil {
+			b.Fatalf("UnmarshalRreadPkt: want nil, got %v", err)
+		}
+	}
+}
+
+func BenchmarkTreadAppend(b *testing.B) {
+	b.ReportAllocs()
+	var tm TreadMsg
+	var rm RreadMsg
+	for i := 0; i < b.N; i++ {
+		t := AppendTread(nil, 1, 2, 0, Count(len(benchData)))
+		if err := DecodeTread(t, &tm); err != nil {
+			b.Fatalf("DecodeTread: want nil, got %v", err)
+		}
+		r := AppendRread(nil, tm.MTag, benchData[:tm.Len])
+		if err := DecodeRread(r, &rm); err != nil {
+			b.Fatalf("DecodeRread: want nil, got %v", err)
+		}
+	}
+}
+
+// BenchmarkSrvRread measures the server's side of a Tread: Dispatch,
+// decoding and the reply.
+func BenchmarkSrvRread(b *testing.B) {
+	b.ReportAllocs()
+	s := &Server{NS: newEcho(), D: Dispatch}
+	var buf []byte
+	for i := 0; i < b.N; i++ {
+		buf = AppendTread(buf[:0], 1, 2, 0, 5)
+		bb := bytes.NewBuffer(buf[5:])
+		if err := s.D(s, bb, Tread); err != nil {
+			b.Fatalf("Dispatch: want nil, got %v", err)
+		}
+		if MType(bb.Bytes()[4]) != Rread {
+			b.Fatalf("Dispatch: got %v, want Rread", RPCNames[MType(bb.Bytes()[4])])
+		}
+	}
+}
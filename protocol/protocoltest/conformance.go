@@ -0,0 +1,506 @@
+// Copyright 2019 The Ninep Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protocoltest
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"sevki.org/q9p/protocol"
+)
+
+// testFids checks that fids in use cannot be used again, fids not in use
+// cannot be used at all, and Tversion and Tclunk let go of them.
+func testFids(t *testing.T, c *Conn) {
+	if _, err := c.call(protocol.AppendTattach(nil, c.Tag(), 0, protocol.NOFID, "glenda", "")); err == nil {
+		t.Errorf("Tattach to fid in use: want an error, got none")
+	}
+	if _, err := c.Stat(9); err == nil {
+		t.Errorf("Tstat of unknown fid: want an error, got none")
+	}
+	if _, err := c.Walk(9, 10); err == nil {
+		t.Errorf("Twalk from unknown fid: want an error, got none")
+	}
+	if err := c.Clunk(9); err == nil {
+		t.Errorf("Tclunk of unknown fid: want an error, got none")
+	}
+
+	c.WalkAll(0, 1)
+	if _, err := c.Walk(0, 1); err == nil {
+		t.Errorf("Twalk to newfid in use: want an error, got none")
+	}
+	if _, err := c.Walk(1, 1); err != nil {
+		t.Errorf("Twalk of fid to itself: want nil, got %v", err)
+	}
+	if _, err := c.Read(1, 0, 10); err == nil {
+		t.Errorf("Tread of unopened fid: want an error, got none")
+	}
+	if _, err := c.Open(1, protocol.OREAD); err != nil {
+		t.Fatalf("Topen of the root: want nil, got %v", err)
+	}
+	if _, err := c.Open(1, protocol.OREAD); err == nil {
+		t.Errorf("Topen of open fid: want an error, got none")
+	}
+	if _, err := c.Create(1, "x", 0644, protocol.OREAD); err == nil {
+		t.Errorf("Tcreate on open fid: want an error, got none")
+	}
+	if _, err := c.Walk(1, 2); err == nil {
+		t.Errorf("Twalk from open fid: want an error, got none")
+	}
+	if _, err := c.Write(1, 0, []byte("x")); err == nil {
+		t.Errorf("Twrite to fid open for reading: want an error, got none")
+	}
+
+	// Tclunk lets go of the fid whatever its answer.
+	c.Clunk(1)
+	if _, err := c.Stat(1); err == nil {
+		t.Errorf("Tstat of clunked fid: want an error, got none")
+	}
+	c.WalkAll(0, 1)
+
+	many := make([]string, protocol.MAXWELEM+1)
+	for i := range many {
+		many[i] = "a"
+	}
+	if _, err := c.Walk(0, 2, many...); err == nil {
+		t.Errorf("Twalk of %d names: want an error, got none", len(many))
+	}
+	if _, err := c.Stat(2); err == nil {
+		t.Errorf("Tstat of fid from failed walk: want an error, got none")
+	}
+
+	// Tversion clunks every fid.
+	c.must(protocol.AppendTversion(nil, protocol.NOTAG, Msize, "9P2000"))
+	for _, fid := range []protocol.FID{0, 1} {
+		if _, err := c.Stat(fid); err == nil {
+			t.Errorf("Tstat of fid %d after Tversion: want an error, got none", fid)
+		}
+	}
+	c.Attach(0)
+}
+
+// testFlush checks that a Tflush is answered with Rflush, after the reply
+// to the message it flushes, if that is answered at all.
+func testFlush(t *testing.T, c *Conn) {
+	tag := c.Tag()
+	r := c.RPC(protocol.AppendTflush(nil, tag, tag-1))
+	if _, ok := r.(*protocol.RflushMsg); !ok {
+		t.Errorf("Tflush of a tag not in flight: got %v, want Rflush", r)
+	}
+
+	for i := 0; i < 10; i++ {
+		old, tag := c.Tag(), c.Tag()
+		b := protocol.AppendTstat(nil, old, 0)
+		b = protocol.AppendTflush(b, tag, old)
+		// The pipe has no buffer, and the server may answer the
+		// Tstat before it reads the Tflush.
+		sent := make(chan error, 1)
+		go func() {
+			_, err := c.c.Write(b)
+			sent <- err
+		}()
+		r := c.Recv()
+		if r.Tag() == old {
+			if r.Type() != protocol.Rstat && r.Type() != protocol.Rerror {
+				t.Fatalf("Tstat: got %v", r)
+			}
+			r = c.Recv()
+		}
+		if r.Tag() != tag || r.Type() != protocol.Rflush {
+			t.Fatalf("Tflush: got %v tag %d, want Rflush tag %d", r, r.Tag(), tag)
+		}
+		if err := <-sent; err != nil {
+			t.Fatalf("sending Tstat and Tflush: %v", err)
+		}
+	}
+	// The connection still works.
+	c.RPC(protocol.AppendTstat(nil, c.Tag(), 0))
+}
+
+// testWalk checks walks of many names, and what a walk that fails part
+// of the way does.
+func testWalk(t *testing.T, c *Conn) {
+	c.mkdir("sub")
+	c.mkfile("f", "")
+
+	d := c.in(1)
+	if d.Type&protocol.QTDIR == 0 {
+		t.Errorf("Twalk to %v: got %v, want a directory", c.dir, d)
+	}
+	q, err := c.Walk(0, 2, c.dir, "sub")
+	if err != nil || len(q) != 2 || q[0] != d || q[1].Type&protocol.QTDIR == 0 {
+		t.Fatalf("Twalk to %v/sub: got %v, %v; want %v and a directory", c.dir, q, err, d)
+	}
+
+	// A walk that fails at the first name is an error.
+	if q, err := c.Walk(0, 3, "nonexistent"); err == nil {
+		t.Errorf("Twalk to nonexistent: got %v, want an error", q)
+	}
+	// One that fails later gives the QIDs up to there, and leaves
+	// newfid alone.
+	for _, w := range []struct {
+		names []string
+		n     int
+	}{
+		{[]string{c.dir, "nonexistent"}, 1},
+		{[]string{c.dir, "f", "x"}, 2},
+		{[]string{c.dir, "sub", "nonexistent", "x"}, 2},
+	} {
+		q, err := c.Walk(0, 3, w.names...)
+		if err != nil || len(q) != w.n {
+			t.Errorf("Twalk to %v: got %v, %v; want %d QIDs", w.names, q, err, w.n)
+		}
+		if _, err := c.Stat(3); err == nil {
+			t.Errorf("Tstat of fid from partial walk to %v: want an error, got none", w.names)
+		}
+	}
+	if q, err := c.Walk(2, 2, "nonexistent"); err == nil {
+		t.Errorf("Twalk of fid to itself to nonexistent: got %v, want an error", q)
+	}
+	if q, err := c.Walk(2, 2, "..", "nonexistent"); err != nil || len(q) != 1 {
+		t.Errorf("Twalk of fid to itself to ../nonexistent: got %v, %v; want 1 QID", q, err)
+	}
+	if st, err := c.Stat(2); err != nil || st.Name != "sub" {
+		t.Errorf("Tstat of fid after partial walk: got %v, %v; want sub", st.Name, err)
+	}
+
+	// A fid can be walked to itself, and .. is the parent.
+	if q := c.WalkAll(2, 2, ".."); q != d {
+		t.Errorf("Twalk of %v/sub to ..: got %v, want %v", c.dir, q, d)
+	}
+	if st, err := c.Stat(2); err != nil || st.QID != d {
+		t.Errorf("Tstat after Twalk of fid to itself: got %v, %v; want %v", st.QID, err, d)
+	}
+	// ... except at the root, which is its own parent. The root has
+	// changed since it was attached, so its version may have.
+	if q := c.WalkAll(0, 4, ".."); q.Path != c.root.Path {
+		t.Errorf("Twalk of root to ..: got %v, want the root, %v", q, c.root)
+	}
+	if q := c.WalkAll(0, 5, "..", c.dir); q != d {
+		t.Errorf("Twalk to ../%v: got %v, want %v", c.dir, q, d)
+	}
+
+	f := c.in(6, "f")
+	if f.Type&protocol.QTDIR != 0 {
+		t.Errorf("Twalk to %v/f: got %v, want a file", c.dir, f)
+	}
+	if q, err := c.Walk(6, 7, "x"); err == nil {
+		t.Errorf("Twalk from a file: got %v, want an error", q)
+	}
+}
+
+// testClone checks that a walk of no names makes a new fid for the same
+// file, which can go its own way.
+func testClone(t *testing.T, c *Conn) {
+	q, err := c.Walk(0, 1)
+	if err != nil || len(q) != 0 {
+		t.Fatalf("Twalk clone of root: got %v, %v; want no QIDs", q, err)
+	}
+	root, err := c.Stat(0)
+	if err != nil {
+		t.Fatalf("Tstat of root: %v", err)
+	}
+	if st, err := c.Stat(1); err != nil || st.QID != root.QID {
+		t.Errorf("Tstat of clone: got %v, %v; want %v", st.QID, err, root.QID)
+	}
+
+	d := c.WalkAll(1, 1, c.dir)
+	if st, err := c.Stat(0); err != nil || st.QID != root.QID {
+		t.Errorf("Tstat of root after walking its clone: got %v, %v; want %v", st.QID, err, root.QID)
+	}
+	c.WalkAll(1, 2)
+	if st, err := c.Stat(2); err != nil || st.QID != d {
+		t.Errorf("Tstat of clone of %v: got %v, %v; want %v", c.dir, st.QID, err, d)
+	}
+	if err := c.Clunk(1); err != nil {
+		t.Errorf("Tclunk: want nil, got %v", err)
+	}
+	if st, err := c.Stat(2); err != nil || st.QID != d {
+		t.Errorf("Tstat of clone after clunking what it was cloned from: got %v, %v; want %v", st.QID, err, d)
+	}
+	if _, err := c.Open(2, protocol.OREAD); err != nil {
+		t.Errorf("Topen of clone: want nil, got %v", err)
+	}
+}
+
+// testOpen checks the open modes, and OTRUNC.
+func testOpen(t *testing.T, c *Conn) {
+	c.mkfile("f", "hello")
+
+	read := func(fid protocol.FID, want string) {
+		t.Helper()
+		if b, err := c.Read(fid, 0, 100); err != nil || string(b) != want {
+			t.Errorf("Tread: got %q, %v; want %q", b, err, want)
+		}
+	}
+
+	c.in(1, "f")
+	if q, err := c.Open(1, protocol.OREAD); err != nil || q.Type&protocol.QTDIR != 0 {
+		t.Fatalf("Topen OREAD: got %v, %v; want a file", q, err)
+	}
+	read(1, "hello")
+	if n, err := c.Write(1, 0, []byte("x")); err == nil {
+		t.Errorf("Twrite to fid open OREAD: wrote %d, want an error", n)
+	}
+
+	c.in(2, "f")
+	if _, err := c.Open(2, protocol.OWRITE); err != nil {
+		t.Fatalf("Topen OWRITE: %v", err)
+	}
+	if b, err := c.Read(2, 0, 100); err == nil {
+		t.Errorf("Tread from fid open OWRITE: got %q, want an error", b)
+	}
+	if n, err := c.Write(2, 5, []byte(" world")); err != nil || n != 6 {
+		t.Errorf("Twrite: got %d, %v; want 6", n, err)
+	}
+
+	c.in(3, "f")
+	if _, err := c.Open(3, protocol.ORDWR); err != nil {
+		t.Fatalf("Topen ORDWR: %v", err)
+	}
+	read(3, "hello world")
+	if n, err := c.Write(3, 0, []byte("H")); err != nil || n != 1 {
+		t.Errorf("Twrite: got %d, %v; want 1", n, err)
+	}
+	read(3, "Hello world")
+
+	c.in(4, "f")
+	if _, err := c.Open(4, protocol.OWRITE|protocol.OTRUNC); err != nil {
+		t.Fatalf("Topen OWRITE|OTRUNC: %v", err)
+	}
+	if st, err := c.Stat(4); err != nil || st.Length != 0 {
+		t.Errorf("Tstat after Topen OTRUNC: got length %d, %v; want 0", st.Length, err)
+	}
+	read(1, "")
+
+	c.in(5)
+	if _, err := c.Open(5, protocol.OWRITE); err == nil {
+		t.Errorf("Topen of a directory OWRITE: want an error, got none")
+	}
+	c.in(6)
+	if q, err := c.Open(6, protocol.OREAD); err != nil || q.Type&protocol.QTDIR == 0 {
+		t.Errorf("Topen of a directory OREAD: got %v, %v; want a directory", q, err)
+	}
+}
+
+// testCreate checks that Tcreate makes a file or directory, leaves the fid
+// open on it, and will not make one that is there already.
+func testCreate(t *testing.T, c *Conn) {
+	c.in(1)
+	q, err := c.Create(1, "f", 0644, protocol.ORDWR)
+	if err != nil {
+		t.Fatalf("Tcreate: %v", err)
+	}
+	if q.Type&protocol.QTDIR != 0 {
+		t.Errorf("Tcreate of a file: got %v", q)
+	}
+	if n, err := c.Write(1, 0, []byte("hi")); err != nil || n != 2 {
+		t.Errorf("Twrite to created fid: got %d, %v; want 2", n, err)
+	}
+	if b, err := c.Read(1, 0, 10); err != nil || string(b) != "hi" {
+		t.Errorf("Tread of created fid: got %q, %v; want hi", b, err)
+	}
+	if st, err := c.Stat(1); err != nil || st.Name != "f" || st.QID.Path != q.Path {
+		t.Errorf("Tstat of created fid: got %v, %v; want f, %v", st, err, q)
+	}
+	if q2 := c.in(2, "f"); q2.Path != q.Path {
+		t.Errorf("Twalk to created file: got %v, want %v", q2, q)
+	}
+
+	c.in(3)
+	if _, err := c.Create(3, "f", 0644, protocol.ORDWR); err == nil {
+		t.Errorf("Tcreate of a file that is there: want an error, got none")
+	}
+	if b, err := c.Read(1, 0, 10); err != nil || string(b) != "hi" {
+		t.Errorf("Tread after Tcreate of a file that is there: got %q, %v; want hi", b, err)
+	}
+	for _, name := range []string{".", "..", ""} {
+		if _, err := c.Create(3, name, protocol.Perm(protocol.DMDIR|0755), protocol.OREAD); err == nil {
+			t.Errorf("Tcreate of %q: want an error, got none", name)
+		}
+	}
+
+	q, err = c.Create(3, "sub", protocol.Perm(protocol.DMDIR|0755), protocol.OREAD)
+	if err != nil {
+		t.Fatalf("Tcreate of a directory: %v", err)
+	}
+	if q.Type&protocol.QTDIR == 0 {
+		t.Errorf("Tcreate of a directory: got %v", q)
+	}
+	if b, err := c.Read(3, 0, Msize-24); err != nil || len(b) != 0 {
+		t.Errorf("Tread of created directory: got %d bytes, %v; want 0", len(b), err)
+	}
+	c.in(4)
+	if _, err := c.Create(4, "sub", protocol.Perm(protocol.DMDIR|0755), protocol.OREAD); err == nil {
+		t.Errorf("Tcreate of a directory that is there: want an error, got none")
+	}
+
+	c.in(5, "f")
+	if _, err := c.Create(5, "g", 0644, protocol.ORDWR); err == nil {
+		t.Errorf("Tcreate in a file: want an error, got none")
+	}
+}
+
+// testRemove checks that Tremove removes the file, and clunks the fid
+// whether it does or not.
+func testRemove(t *testing.T, c *Conn) {
+	c.mkfile("f", "")
+	c.mkdir("sub")
+	c.in(100, "sub")
+	if _, err := c.Create(100, "g", 0644, protocol.ORDWR); err != nil {
+		t.Fatalf("Tcreate: %v", err)
+	}
+	c.Clunk(100)
+
+	c.in(1, "f")
+	if err := c.Remove(1); err != nil {
+		t.Errorf("Tremove: want nil, got %v", err)
+	}
+	if _, err := c.Stat(1); err == nil {
+		t.Errorf("Tstat after Tremove: want an error, got none")
+	}
+	if q, err := c.Walk(0, 2, c.dir, "f"); err == nil && len(q) == 2 {
+		t.Errorf("Twalk to removed file: got %v", q)
+	}
+
+	c.in(3, "sub")
+	if err := c.Remove(3); err == nil {
+		t.Errorf("Tremove of a directory that is not empty: want an error, got none")
+	}
+	if _, err := c.Stat(3); err == nil {
+		t.Errorf("Tstat after failed Tremove: want an error, got none")
+	}
+	c.in(4, "sub", "g")
+	if err := c.Remove(4); err != nil {
+		t.Errorf("Tremove: want nil, got %v", err)
+	}
+	c.in(5, "sub")
+	if err := c.Remove(5); err != nil {
+		t.Errorf("Tremove of an empty directory: want nil, got %v", err)
+	}
+}
+
+// testDirRead checks that directory reads give whole entries, at offsets
+// that follow on from each other, and start again at offset 0.
+func testDirRead(t *testing.T, c *Conn) {
+	var want []string
+	for i := 0; i < 20; i++ {
+		n := fmt.Sprintf("file%02d", i)
+		c.mkfile(n, "")
+		want = append(want, n)
+	}
+	c.in(1, "file00")
+	st, err := c.Stat(1)
+	if err != nil {
+		t.Fatalf("Tstat: %v", err)
+	}
+	var b bytes.Buffer
+	protocol.Marshaldir(&b, st)
+	size := protocol.Count(b.Len())
+
+	c.in(2)
+	if _, err := c.Open(2, protocol.OREAD); err != nil {
+		t.Fatalf("Topen of a directory: %v", err)
+	}
+	// Room for all of them, for some, and for one and a half.
+	for _, n := range []protocol.Count{Msize - 24, 4 * size, size + size/2} {
+		if got := names(c.ReadDir(2, n)); !reflect.DeepEqual(got, want) {
+			t.Errorf("reading directory %d bytes at a time: got %v, want %v", n, got, want)
+		}
+	}
+
+	// Starting again before the end.
+	first, err := c.Read(2, 0, size+size/2)
+	if err != nil {
+		t.Fatalf("Tread of directory: %v", err)
+	}
+	if _, err := c.Read(2, protocol.Offset(len(first)), size+size/2); err != nil {
+		t.Fatalf("Tread of directory: %v", err)
+	}
+	if again, err := c.Read(2, 0, size+size/2); err != nil || !bytes.Equal(again, first) {
+		t.Errorf("Tread of directory at offset 0 again: got %q, %v; want %q", again, err, first)
+	}
+}
+
+// testWstat checks that Twstat changes what it is asked to, and nothing
+// it is told not to touch.
+func testWstat(t *testing.T, c *Conn) {
+	c.mkfile("f", "hello")
+	c.in(1, "f")
+	stat := func() protocol.Dir {
+		t.Helper()
+		st, err := c.Stat(1)
+		if err != nil {
+			t.Fatalf("Tstat: %v", err)
+		}
+		return st
+	}
+	wstat := func(d protocol.Dir) {
+		t.Helper()
+		if err := c.Wstat(1, d); err != nil {
+			t.Fatalf("Twstat %v: %v", d, err)
+		}
+	}
+	// Twstat may change the mtime, and a chmod the mode's other bits,
+	// so only what is asked for is checked against before.
+	check := func(what string, want protocol.Dir) {
+		t.Helper()
+		got := stat()
+		if got.Name != want.Name || got.Mode&0777 != want.Mode&0777 || got.Length != want.Length || got.QID.Path != want.QID.Path {
+			t.Errorf("Twstat of %v: got %v, want %v", what, got, want)
+		}
+	}
+
+	before := stat()
+	wstat(NoChange())
+	check("nothing", before)
+	if after := stat(); after.Mtime != before.Mtime || after.Mode != before.Mode {
+		t.Errorf("Twstat of nothing: got %v, want %v", after, before)
+	}
+
+	want := before
+	d := NoChange()
+	d.Length = 2
+	wstat(d)
+	want.Length = 2
+	check("length", want)
+
+	d = NoChange()
+	d.Mode = before.Mode&^0777 | 0600
+	wstat(d)
+	want.Mode = d.Mode
+	check("mode", want)
+
+	d = NoChange()
+	d.Mtime = 1000000000
+	wstat(d)
+	check("mtime", want)
+	if got := stat(); got.Mtime != d.Mtime {
+		t.Errorf("Twstat of mtime: got %v, want %v", got.Mtime, d.Mtime)
+	}
+
+	d = NoChange()
+	d.Name = "g"
+	wstat(d)
+	want.Name = "g"
+	check("name", want)
+	if got := stat(); got.Mtime != 1000000000 {
+		t.Errorf("Twstat of name changed mtime to %v", got.Mtime)
+	}
+	if q, err := c.Walk(0, 2, c.dir, "f"); err == nil && len(q) == 2 {
+		t.Errorf("Twalk to old name: got %v", q)
+	}
+	if q := c.in(3, "g"); q.Path != before.QID.Path {
+		t.Errorf("Twalk to new name: got %v, want %v", q, before.QID)
+	}
+	if _, err := c.Open(3, protocol.OREAD); err != nil {
+		t.Fatalf("Topen: %v", err)
+	}
+	if b, err := c.Read(3, 0, 10); err != nil || string(b) != "he" {
+		t.Errorf("Tread after Twstats: got %q, %v; want he", b, err)
+	}
+}
@@ -0,0 +1,150 @@
+// Copyright 2019 The Ninep Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protocoltest
+
+import (
+	"errors"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+)
+
+// ErrReset is what a write to a FaultyConn returns once it has reset the
+// connection.
+var ErrReset = errors.New("connection reset by fault")
+
+// Faults is what a FaultyConn does wrong with what is written to it. The
+// zero Faults does nothing wrong. Faults that need randomness get it from
+// Seed, so the same Faults do the same wrong things to the same writes.
+//
+// Messages are counted from 1 by following the size fields of what is
+// written, so the counting assumes the writer writes 9P messages.
+type Faults struct {
+	Seed int64
+
+	// Fragment, if not zero, splits each write into pieces of 1 to
+	// Fragment bytes, written one at a time, so the reader gets them in
+	// as many reads.
+	Fragment int
+
+	// Latency, if not zero, is the most each piece is delayed.
+	Latency time.Duration
+
+	// ResetAfter, if not zero, is how many bytes get through before the
+	// connection is closed; the write that reaches it writes up to it and
+	// returns ErrReset.
+	ResetAfter int64
+
+	// Corrupt, if not zero, is the message whose size field is replaced
+	// with random bytes.
+	Corrupt int
+
+	// Duplicate, if not zero, is the message that is written twice.
+	Duplicate int
+
+	// Hold, if not zero, is the message that is held back and written
+	// after the one that follows it.
+	Hold int
+}
+
+// FaultyConn is a net.Conn that does what its Faults say to what is
+// written to it. Reads are passed through.
+type FaultyConn struct {
+	net.Conn
+	f Faults
+
+	mu      sync.Mutex
+	rand    *rand.Rand
+	written int64  // bytes written to Conn
+	msg     int    // the message being written
+	off     int64  // how far into it
+	size    []byte // its size field, as it came
+	cur     []byte // it, if it is to be duplicated
+	held    []byte // the message held back
+	reset   bool
+}
+
+// NewFaultyConn returns a FaultyConn that writes to c.
+func NewFaultyConn(c net.Conn, f Faults) *FaultyConn {
+	return &FaultyConn{Conn: c, f: f, rand: rand.New(rand.NewSource(f.Seed)), msg: 1}
+}
+
+// Write writes what the Faults make of p. It returns len(p) if all of it
+// was written, or held back, as the Faults say.
+func (c *FaultyConn) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.reset {
+		return 0, ErrReset
+	}
+	out := c.mangle(p)
+	for len(out) > 0 {
+		n := len(out)
+		if c.f.Fragment > 0 && n > c.f.Fragment {
+			n = 1 + c.rand.Intn(c.f.Fragment)
+		}
+		if c.f.ResetAfter > 0 && c.written+int64(n) >= c.f.ResetAfter {
+			n = int(c.f.ResetAfter - c.written)
+			c.reset = true
+		}
+		if c.f.Latency > 0 {
+			time.Sleep(time.Duration(c.rand.Int63n(int64(c.f.Latency))))
+		}
+		m, err := c.Conn.Write(out[:n])
+		c.written += int64(m)
+		if c.reset {
+			c.Conn.Close()
+			return 0, ErrReset
+		}
+		if err != nil {
+			return 0, err
+		}
+		out = out[n:]
+	}
+	return len(p), nil
+}
+
+// mangle returns what is to be written for p, following the messages in
+// it and doing the Faults that are done to whole messages.
+func (c *FaultyConn) mangle(p []byte) []byte {
+	var out []byte
+	for _, b := range p {
+		if c.off < 4 {
+			c.size = append(c.size, b)
+			if c.msg == c.f.Corrupt {
+				b = byte(c.rand.Intn(256))
+			}
+		}
+		c.off++
+		switch c.msg {
+		case c.f.Hold:
+			c.held = append(c.held, b)
+		case c.f.Duplicate:
+			c.cur = append(c.cur, b)
+			out = append(out, b)
+		default:
+			out = append(out, b)
+		}
+		if c.off < 4 {
+			continue
+		}
+		size := int64(c.size[0]) | int64(c.size[1])<<8 | int64(c.size[2])<<16 | int64(c.size[3])<<24
+		if c.off < size {
+			continue
+		}
+		if c.msg == c.f.Duplicate {
+			out = append(out, c.cur...)
+			c.cur = nil
+		}
+		if c.f.Hold > 0 && c.msg == c.f.Hold+1 {
+			out = append(out, c.held...)
+			c.held = nil
+		}
+		c.msg++
+		c.off, c.size = 0, c.size[:0]
+	}
+	return out
+}
@@ -0,0 +1,455 @@
+// Copyright 2019 The Ninep Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package protocoltest checks that NineServers answer as 9P says they
+// must. RunConformance is meant to be called from a server's own tests:
+//
+//	func TestConformance(t *testing.T) {
+//		protocoltest.RunConformance(t, func() protocol.NineServer {
+//			return newServer(dir)
+//		})
+//	}
+package protocoltest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"sevki.org/q9p/protocol"
+)
+
+// Msize is the msize the suite's connections ask for.
+const Msize = 8192
+
+// RunConformance runs the conformance suite on the NineServers ns makes,
+// as subtests of t. Each subtest gets a connection of its own, through
+// net.Pipe, to a Listener made with opts, and attaches to the root with
+// an empty aname as user glenda. The Listener has no FidTable unless
+// opts give it one, so the suite checks the server's own handling of
+// fids and tags; a server that leaves that to a FidTable passes FidTable.
+//
+// The subtests that change the tree do it in a directory they create in
+// the root, named for the subtest, and remove it when they are done.
+// They are skipped if the root is not a directory or the server will not
+// create one in it, so a read-only or synthetic server still gets the
+// rest of the suite.
+func RunConformance(t *testing.T, ns protocol.NsCreator, opts ...protocol.ListenerOpt) {
+	for _, c := range []struct {
+		name string
+		f    func(t *testing.T, c *Conn)
+		tree bool
+	}{
+		{"Fids", testFids, false},
+		{"Flush", testFlush, false},
+		{"Walk", testWalk, true},
+		{"Clone", testClone, true},
+		{"Open", testOpen, true},
+		{"Create", testCreate, true},
+		{"Remove", testRemove, true},
+		{"DirRead", testDirRead, true},
+		{"Wstat", testWstat, true},
+	} {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			conn := Dial(t, ns, opts...)
+			defer conn.Close()
+			conn.Attach(0)
+			if c.tree {
+				conn.scratch(t)
+				defer conn.removeAll(0, conn.dir)
+			}
+			c.f(t, conn)
+		})
+	}
+}
+
+// A Conn is a connection to a NineServer, for testing it. Its methods
+// send a T message and wait for the reply; the ones that do not return
+// an error end the test if the reply is Rerror.
+type Conn struct {
+	t   testing.TB
+	c   net.Conn
+	tag protocol.Tag
+
+	// root is the QID of the root, and dir the name of the subtest's
+	// directory in it.
+	root protocol.QID
+	dir  string
+}
+
+// Dial starts a Listener made with opts that serves the NineServers ns
+// makes, and returns a connection to it that has negotiated 9P2000.
+func Dial(t testing.TB, ns protocol.NsCreator, opts ...protocol.ListenerOpt) *Conn {
+	c := listen(t, ns, opts...)
+	r := c.must(protocol.AppendTversion(nil, protocol.NOTAG, Msize, "9P2000"))
+	if v, ok := r.(*protocol.RversionMsg); !ok || v.RVersion != "9P2000" {
+		t.Fatalf("Tversion 9P2000: got %v", r)
+	}
+	return c
+}
+
+// FidTable is a ListenerOpt that gives the Listener a FidTable.
+func FidTable(l *protocol.Listener) error {
+	l.FidTable = true
+	return nil
+}
+
+// listen is Dial without the Tversion.
+func listen(t testing.TB, ns protocol.NsCreator, opts ...protocol.ListenerOpt) *Conn {
+	t.Helper()
+	l, err := protocol.NewListener(ns, opts...)
+	if err != nil {
+		t.Fatalf("NewListener: %v", err)
+	}
+	p, p2 := net.Pipe()
+	if err := l.Accept(p2); err != nil {
+		t.Fatalf("Accept: %v", err)
+	}
+	return &Conn{t: t, c: p}
+}
+
+// Close closes the connection.
+func (c *Conn) Close() error {
+	return c.c.Close()
+}
+
+// Tag returns a tag for a message, not NOTAG, and not the last one used.
+func (c *Conn) Tag() protocol.Tag {
+	c.tag++
+	if c.tag == protocol.NOTAG {
+		c.tag = 1
+	}
+	return c.tag
+}
+
+// Send sends the message b, which must be the whole of it, size and all.
+func (c *Conn) Send(b []byte) {
+	c.t.Helper()
+	c.c.SetWriteDeadline(time.Now().Add(5 * time.Second))
+	if _, err := c.c.Write(b); err != nil {
+		c.t.Fatalf("sending %v: %v", name(b), err)
+	}
+}
+
+// Recv reads a reply.
+func (c *Conn) Recv() protocol.Msg {
+	c.t.Helper()
+	c.c.SetReadDeadline(time.Now().Add(5 * time.Second))
+	b := make([]byte, 4)
+	if _, err := io.ReadFull(c.c, b); err != nil {
+		c.t.Fatalf("reading a reply: %v", err)
+	}
+	n := int(b[0]) | int(b[1])<<8 | int(b[2])<<16 | int(b[3])<<24
+	if n < 7 || n > protocol.MSIZE {
+		c.t.Fatalf("reading a reply: size %d", n)
+	}
+	b = append(b, make([]byte, n-4)...)
+	if _, err := io.ReadFull(c.c, b[4:]); err != nil {
+		c.t.Fatalf("reading a reply: %v", err)
+	}
+	m, err := protocol.Decode(b)
+	if err != nil {
+		c.t.Fatalf("decoding reply %v: %v", name(b), err)
+	}
+	return m
+}
+
+// RPC sends the message b and returns the reply, which must have the
+// same tag and be either Rerror or the reply to b's type.
+func (c *Conn) RPC(b []byte) protocol.Msg {
+	c.t.Helper()
+	c.Send(b)
+	r := c.Recv()
+	tag := protocol.Tag(b[5]) | protocol.Tag(b[6])<<8
+	if r.Tag() != tag {
+		c.t.Fatalf("%v tag %d: got %v tag %d", name(b), tag, r, r.Tag())
+	}
+	if r.Type() != protocol.Rerror && r.Type() != protocol.MType(b[4])+1 {
+		c.t.Fatalf("%v: got %v", name(b), r)
+	}
+	return r
+}
+
+// call is RPC with an Rerror made an error.
+func (c *Conn) call(b []byte) (protocol.Msg, error) {
+	c.t.Helper()
+	r := c.RPC(b)
+	if e, ok := r.(*protocol.RerrorMsg); ok {
+		return nil, errors.New(e.Error)
+	}
+	return r, nil
+}
+
+// must is RPC, ending the test if the reply is Rerror.
+func (c *Conn) must(b []byte) protocol.Msg {
+	c.t.Helper()
+	r, err := c.call(b)
+	if err != nil {
+		c.t.Fatalf("%v: %v", name(b), err)
+	}
+	return r
+}
+
+// name names the message in b for an error.
+func name(b []byte) string {
+	if len(b) < 5 {
+		return fmt.Sprintf("%d byte message", len(b))
+	}
+	if n, ok := protocol.RPCNames[protocol.MType(b[4])]; ok {
+		return n
+	}
+	return fmt.Sprintf("message type %d", b[4])
+}
+
+// Attach attaches fid to the root.
+func (c *Conn) Attach(fid protocol.FID) protocol.QID {
+	c.t.Helper()
+	r := c.must(protocol.AppendTattach(nil, c.Tag(), fid, protocol.NOFID, "glenda", ""))
+	c.root = r.(*protocol.RattachMsg).QID
+	return c.root
+}
+
+// Walk walks fid to newfid, returning the QIDs of the names walked.
+func (c *Conn) Walk(fid, newfid protocol.FID, names ...string) ([]protocol.QID, error) {
+	c.t.Helper()
+	r, err := c.call(protocol.AppendTwalk(nil, c.Tag(), fid, newfid, names))
+	if err != nil {
+		return nil, err
+	}
+	q := r.(*protocol.RwalkMsg).QIDs
+	if len(q) > len(names) || len(q) == 0 && len(names) > 0 {
+		c.t.Fatalf("Twalk %v: %d QIDs", names, len(q))
+	}
+	return q, nil
+}
+
+// WalkAll walks fid to newfid, and fails the test unless every name is
+// walked.
+func (c *Conn) WalkAll(fid, newfid protocol.FID, names ...string) protocol.QID {
+	c.t.Helper()
+	q, err := c.Walk(fid, newfid, names...)
+	if err != nil {
+		c.t.Fatalf("Twalk %v: %v", names, err)
+	}
+	if len(q) != len(names) {
+		c.t.Fatalf("Twalk %v: walked %d names", names, len(q))
+	}
+	if len(q) == 0 {
+		return protocol.QID{}
+	}
+	return q[len(q)-1]
+}
+
+// Open opens fid.
+func (c *Conn) Open(fid protocol.FID, mode protocol.Mode) (protocol.QID, error) {
+	r, err := c.call(protocol.AppendTopen(nil, c.Tag(), fid, mode))
+	if err != nil {
+		return protocol.QID{}, err
+	}
+	return r.(*protocol.RopenMsg).OQID, nil
+}
+
+// Create creates name in the directory fid, which becomes it, open.
+func (c *Conn) Create(fid protocol.FID, name string, perm protocol.Perm, mode protocol.Mode) (protocol.QID, error) {
+	r, err := c.call(protocol.AppendTcreate(nil, c.Tag(), fid, name, perm, mode))
+	if err != nil {
+		return protocol.QID{}, err
+	}
+	return r.(*protocol.RcreateMsg).OQID, nil
+}
+
+// Read reads from fid.
+func (c *Conn) Read(fid protocol.FID, off protocol.Offset, n protocol.Count) ([]byte, error) {
+	c.t.Helper()
+	r, err := c.call(protocol.AppendTread(nil, c.Tag(), fid, off, n))
+	if err != nil {
+		return nil, err
+	}
+	d := r.(*protocol.RreadMsg).Data
+	if len(d) > int(n) {
+		c.t.Fatalf("Tread of %d bytes: got %d", n, len(d))
+	}
+	return d, nil
+}
+
+// Write writes to fid.
+func (c *Conn) Write(fid protocol.FID, off protocol.Offset, b []byte) (protocol.Count, error) {
+	r, err := c.call(protocol.AppendTwrite(nil, c.Tag(), fid, off, b))
+	if err != nil {
+		return 0, err
+	}
+	return r.(*protocol.RwriteMsg).RLen, nil
+}
+
+// Clunk clunks fid.
+func (c *Conn) Clunk(fid protocol.FID) error {
+	_, err := c.call(protocol.AppendTclunk(nil, c.Tag(), fid))
+	return err
+}
+
+// Remove removes the file fid is on, and clunks fid.
+func (c *Conn) Remove(fid protocol.FID) error {
+	_, err := c.call(protocol.AppendTremove(nil, c.Tag(), fid))
+	return err
+}
+
+// Stat returns the Dir for fid.
+func (c *Conn) Stat(fid protocol.FID) (protocol.Dir, error) {
+	r, err := c.call(protocol.AppendTstat(nil, c.Tag(), fid))
+	if err != nil {
+		return protocol.Dir{}, err
+	}
+	return protocol.Unmarshaldir(bytes.NewBuffer(r.(*protocol.RstatMsg).B))
+}
+
+// Wstat changes the Dir for fid.
+func (c *Conn) Wstat(fid protocol.FID, d protocol.Dir) error {
+	var b bytes.Buffer
+	protocol.Marshaldir(&b, d)
+	_, err := c.call(protocol.AppendTwstat(nil, c.Tag(), fid, b.Bytes()))
+	return err
+}
+
+// NoChange returns a Dir that changes nothing in a Twstat.
+func NoChange() protocol.Dir {
+	return protocol.Dir{
+		Type:   ^uint16(0),
+		Dev:    ^uint32(0),
+		QID:    protocol.QID{Type: ^uint8(0), Version: ^uint32(0), Path: ^uint64(0)},
+		Mode:   ^uint32(0),
+		Atime:  ^uint32(0),
+		Mtime:  ^uint32(0),
+		Length: ^uint64(0),
+	}
+}
+
+// ReadDir reads the directory fid, which must be open, from the start,
+// count bytes at a time. Each Rread must hold whole entries.
+func (c *Conn) ReadDir(fid protocol.FID, count protocol.Count) []protocol.Dir {
+	c.t.Helper()
+	var dirs []protocol.Dir
+	var off protocol.Offset
+	for {
+		b, err := c.Read(fid, off, count)
+		if err != nil {
+			c.t.Fatalf("Tread of directory at %d: %v", off, err)
+		}
+		if len(b) == 0 {
+			return dirs
+		}
+		d, err := entries(b)
+		if err != nil {
+			c.t.Fatalf("Tread of directory at %d: %v", off, err)
+		}
+		dirs = append(dirs, d...)
+		off += protocol.Offset(len(b))
+	}
+}
+
+// entries splits the directory entries in b.
+func entries(b []byte) ([]protocol.Dir, error) {
+	var dirs []protocol.Dir
+	for len(b) > 0 {
+		if len(b) < 2 {
+			return nil, fmt.Errorf("%d bytes left after %d entries", len(b), len(dirs))
+		}
+		n := 2 + (int(b[0]) | int(b[1])<<8)
+		if n > len(b) {
+			return nil, fmt.Errorf("entry %d is %d bytes, but only %d are left", len(dirs), n, len(b))
+		}
+		d, err := protocol.Unmarshaldir(bytes.NewBuffer(b[:n]))
+		if err != nil {
+			return nil, fmt.Errorf("entry %d: %v", len(dirs), err)
+		}
+		dirs = append(dirs, d)
+		b = b[n:]
+	}
+	return dirs, nil
+}
+
+// names returns the names in dirs, sorted.
+func names(dirs []protocol.Dir) []string {
+	var n []string
+	for _, d := range dirs {
+		n = append(n, d.Name)
+	}
+	sort.Strings(n)
+	return n
+}
+
+// scratch makes the subtest's directory, or skips the subtest.
+func (c *Conn) scratch(t *testing.T) {
+	if c.root.Type&protocol.QTDIR == 0 {
+		t.Skipf("the root is not a directory")
+	}
+	c.dir = "conformance-" + strings.Replace(t.Name(), "/", "-", -1)
+	c.WalkAll(0, 1)
+	q, err := c.Create(1, c.dir, protocol.Perm(protocol.DMDIR|0755), protocol.OREAD)
+	if err != nil {
+		t.Skipf("cannot create a directory in the root: %v", err)
+	}
+	if q.Type&protocol.QTDIR == 0 {
+		t.Skipf("created a directory in the root, but it is not one: %v", q)
+	}
+	c.Clunk(1)
+}
+
+// removeAll removes name in the directory fid, and all it holds. It uses
+// fids from 1000 up.
+func (c *Conn) removeAll(fid protocol.FID, name string) {
+	nfid := fid + 1000
+	if _, err := c.Walk(fid, nfid, name); err != nil {
+		return
+	}
+	d, err := c.Stat(nfid)
+	if err == nil && d.Mode&protocol.DMDIR != 0 {
+		if _, err := c.Walk(fid, nfid+1, name); err == nil {
+			if _, err := c.Open(nfid+1, protocol.OREAD); err == nil {
+				for _, d := range c.ReadDir(nfid+1, Msize-24) {
+					c.removeAll(nfid, d.Name)
+				}
+			}
+			c.Clunk(nfid + 1)
+		}
+	}
+	c.Remove(nfid)
+}
+
+// in walks fid to newfid, the subtest's directory or a file in it.
+func (c *Conn) in(newfid protocol.FID, names ...string) protocol.QID {
+	c.t.Helper()
+	return c.WalkAll(0, newfid, append([]string{c.dir}, names...)...)
+}
+
+// mkfile makes a file in the subtest's directory holding s.
+func (c *Conn) mkfile(name, s string) {
+	c.t.Helper()
+	c.in(100)
+	if _, err := c.Create(100, name, 0644, protocol.ORDWR); err != nil {
+		c.t.Fatalf("Tcreate %v: %v", name, err)
+	}
+	if s != "" {
+		if _, err := c.Write(100, 0, []byte(s)); err != nil {
+			c.t.Fatalf("Twrite %v: %v", name, err)
+		}
+	}
+	c.Clunk(100)
+}
+
+// mkdir makes a directory in the subtest's directory.
+func (c *Conn) mkdir(name string) {
+	c.t.Helper()
+	c.in(100)
+	if _, err := c.Create(100, name, protocol.Perm(protocol.DMDIR|0755), protocol.OREAD); err != nil {
+		c.t.Fatalf("Tcreate %v: %v", name, err)
+	}
+	c.Clunk(100)
+}
@@ -0,0 +1,184 @@
+// Copyright 2019 The Ninep Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protocoltest
+
+import (
+	"bytes"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"sevki.org/q9p/protocol"
+)
+
+func TestEntries(t *testing.T) {
+	var b, e bytes.Buffer
+	for _, n := range []string{"a", "bb", "ccc"} {
+		protocol.Marshaldir(&e, protocol.Dir{Name: n, User: "glenda"})
+		b.Write(e.Bytes())
+	}
+	d, err := entries(b.Bytes())
+	if err != nil {
+		t.Fatalf("entries: want nil, got %v", err)
+	}
+	if got, want := names(d), []string{"a", "bb", "ccc"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("entries: got %v, want %v", got, want)
+	}
+	for _, n := range []int{1, e.Len() - 1} {
+		if d, err := entries(b.Bytes()[:b.Len()-n]); err == nil {
+			t.Errorf("entries of all but %d bytes: got %v, want an error", n, d)
+		}
+	}
+}
+
+func TestParseScript(t *testing.T) {
+	for _, v := range []struct {
+		s   string
+		err string
+	}{
+		{"> Tclunk 1\n< Rclunk\n", ""},
+		{"# comment\n\n> Twalk 0 1 a \"b c\"\n-- a --\n> not a line\n", ""},
+		{"> Tclunk\n", ":1: Tclunk: no OFID"},
+		{"\n> Tclunk 1 2\n", ":2: Tclunk: too many fields"},
+		{"> Rclunk\n", ":1: Rclunk is not a message to send"},
+		{"< Twalk 0 1\n", ":1: Twalk is not a message to get"},
+		{"> Tnope\n", ":1: unknown message type Tnope"},
+		{"Tclunk 1\n", ":1: want > or <"},
+		{"> Tattach 0 NOFID \"glenda\n", ":1: unterminated string"},
+		{"> Twstat 1 {length 1\n", ":1: missing }"},
+		{"> Twstat 1 {size 1}\n", ":1: Twstat B: no stat field size"},
+		{"> Topen 1 OSOMETIMES\n", ":1: Topen Omode: strconv.ParseUint"},
+	} {
+		_, err := ParseScript("x", []byte(v.s))
+		switch {
+		case v.err == "" && err != nil:
+			t.Errorf("%q: want nil, got %v", v.s, err)
+		case v.err != "" && (err == nil || !strings.Contains(err.Error(), v.err)):
+			t.Errorf("%q: want %v, got %v", v.s, v.err, err)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	var d bytes.Buffer
+	protocol.Marshaldir(&d, protocol.Dir{Name: "hello", Mode: 0644, Length: 13, QID: protocol.QID{Path: 7}})
+	for _, v := range []struct {
+		m    protocol.Msg
+		args string
+		ok   bool
+	}{
+		{&protocol.RwalkMsg{QIDs: []protocol.QID{{Type: protocol.QTDIR, Path: 1}, {Path: 2}}}, "(QTDIR 0 1) (0 0 2)", true},
+		{&protocol.RwalkMsg{QIDs: []protocol.QID{{Type: protocol.QTDIR, Path: 1}, {Path: 2}}}, "(0x80 * *) ...", true},
+		{&protocol.RwalkMsg{QIDs: []protocol.QID{{Type: protocol.QTDIR, Path: 1}, {Path: 2}}}, "(0x80 * *)", false},
+		{&protocol.RwalkMsg{QIDs: []protocol.QID{{Type: protocol.QTDIR, Path: 1}}}, "(0x80 * *) (0 * *)", false},
+		{&protocol.RwalkMsg{}, "", true},
+		{&protocol.RwalkMsg{}, "...", true},
+		{&protocol.RerrorMsg{Error: "file does not exist"}, "*exist", true},
+		{&protocol.RerrorMsg{Error: "file does not exist"}, "\"*exist\"", false},
+		{&protocol.RerrorMsg{Error: "file does not exist"}, "*", true},
+		{&protocol.RreadMsg{Data: []byte("hi\n")}, "\"hi\\n\"", true},
+		{&protocol.RwriteMsg{RLen: -1}, "-1", true},
+		{&protocol.RopenMsg{IOUnit: 8192}, "(0 0 0) 0x2000", true},
+		{&protocol.RstatMsg{B: d.Bytes()}, "{name hello mode 0644}", true},
+		{&protocol.RstatMsg{B: d.Bytes()}, "{qid (* * 7) length 13}", true},
+		{&protocol.RstatMsg{B: d.Bytes()}, "{name hello mode 0600}", false},
+	} {
+		args, err := lex(v.args)
+		if err != nil {
+			t.Fatalf("lex(%q): %v", v.args, err)
+		}
+		if ok, err := match(v.m, args); ok != v.ok || err != nil {
+			t.Errorf("match(%v, %q): got %v, %v; want %v", Format(v.m), v.args, ok, err, v.ok)
+		}
+		// What Format makes matches.
+		if args, err := lex(strings.TrimPrefix(Format(v.m), protocol.RPCNames[v.m.Type()])); err != nil {
+			t.Errorf("lex(Format(%v)): %v", Format(v.m), err)
+		} else if ok, err := match(v.m, args); !ok || err != nil {
+			t.Errorf("match(%v, its Format): got %v, %v; want true", Format(v.m), ok, err)
+		}
+	}
+}
+
+func TestFaultyConn(t *testing.T) {
+	m1 := protocol.AppendTclunk(nil, 1, 1)
+	m2 := protocol.AppendTwalk(nil, 2, 0, 1, []string{"a", "b"})
+	m3 := protocol.AppendTread(nil, 3, 1, 0, 8192)
+	cat := func(ms ...[]byte) []byte { return bytes.Join(ms, nil) }
+	all := cat(m1, m2, m3)
+	corrupt := func(b []byte) []byte {
+		b = cat(b)
+		copy(b[len(m1):], []byte{0, 0, 0, 0})
+		return b
+	}
+	for _, v := range []struct {
+		f     Faults
+		want  []byte
+		err   error
+		reads int // the least number of reads it takes
+	}{
+		{Faults{}, all, nil, 1},
+		{Faults{Fragment: 3}, all, nil, len(all) / 3},
+		{Faults{Latency: time.Millisecond}, all, nil, 1},
+		{Faults{ResetAfter: 10}, all[:10], ErrReset, 1},
+		{Faults{Duplicate: 2}, cat(m1, m2, m2, m3), nil, 1},
+		{Faults{Hold: 1}, cat(m2, m1, m3), nil, 1},
+		{Faults{Corrupt: 2, Seed: 1}, nil, nil, 1},
+	} {
+		got, reads, err := through(v.f, all)
+		if err != v.err {
+			t.Errorf("%+v: got %v, want %v", v.f, err, v.err)
+		}
+		if v.f.Corrupt > 0 {
+			// Only the size changes, and it changes the same way each time.
+			again, _, _ := through(v.f, all)
+			if !bytes.Equal(corrupt(got), corrupt(all)) || bytes.Equal(got, all) || !bytes.Equal(got, again) {
+				t.Errorf("%+v: got %x, want %x with its second size changed, twice", v.f, got, all)
+			}
+			continue
+		}
+		if !bytes.Equal(got, v.want) {
+			t.Errorf("%+v: got %x, want %x", v.f, got, v.want)
+		}
+		if reads < v.reads {
+			t.Errorf("%+v: read in %d reads, want %d or more", v.f, reads, v.reads)
+		}
+	}
+}
+
+// through writes b to a FaultyConn with faults f, five bytes at a time, and
+// returns what came out, in how many reads, and the first error writing.
+func through(f Faults, b []byte) ([]byte, int, error) {
+	p, p2 := net.Pipe()
+	c := NewFaultyConn(p, f)
+	errc := make(chan error, 1)
+	go func() {
+		var err error
+		for i := 0; i < len(b); i += 5 {
+			j := i + 5
+			if j > len(b) {
+				j = len(b)
+			}
+			if _, err = c.Write(b[i:j]); err != nil {
+				break
+			}
+		}
+		c.Close()
+		errc <- err
+	}()
+	var out []byte
+	reads := 0
+	for {
+		buf := make([]byte, 1024)
+		n, err := p2.Read(buf)
+		if err != nil {
+			break
+		}
+		out = append(out, buf[:n]...)
+		reads++
+	}
+	return out, reads, <-errc
+}
@@ -0,0 +1,700 @@
+// Copyright 2019 The Ninep Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protocoltest
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"sevki.org/q9p/protocol"
+)
+
+// A Script is a test of a NineServer written as the messages a client
+// sends it and the replies it should get, so a transcript from a bug
+// report can be made a test without writing Go:
+//
+//	# Walking through a file fails at the file.
+//	> Tversion 8192 9P2000
+//	< Rversion 8192 9P2000
+//	> Tattach 0 NOFID glenda ""
+//	< Rattach (0x80 * *)
+//	> Twalk 0 1 usr glenda hello x
+//	< Rwalk (0x80 * *) (0x80 * *) (0 * *)
+//	-- usr/glenda/hello --
+//	hello, world
+//
+// The format is like txtar's: the script, then the files it needs, each
+// after a line "-- name --". A name ending in / is an empty directory.
+// The files, and the directories on their paths, are made in the root
+// with Tcreate and Twrite before the script runs, and removed after.
+//
+// In the script, blank lines and lines starting with # are ignored. A
+// line starting with > sends a T message, and one starting with < reads
+// a reply and checks it. The T messages but Tversion, which has NOTAG,
+// have tags 1, 2, 3 and so on, in order; a reply may be to any that has
+// not been answered yet.
+//
+// After the type come the message's fields but the tag, in order, as for
+// the Append functions: numbers in Go syntax, or the constants NOFID,
+// NOTAG, OREAD, OTRUNC, DMDIR, QTDIR and so on, joined by |; strings,
+// quoted if they have spaces or are empty; QIDs as (type version path);
+// and stats as {name value ...}, with the names of Dir's fields in lower
+// case. The names of a Twalk and the QIDs of an Rwalk are the last
+// fields, one after another.
+//
+// In a reply, * matches any field, or part of a QID, and ... at the end
+// matches the rest. An unquoted string is a pattern, as for path.Match. A
+// stat is checked only for the fields it gives; in a Twstat, the fields
+// not given are left alone.
+type Script struct {
+	Name  string
+	lines []line
+	files []file
+}
+
+type line struct {
+	n    int
+	text string
+	send bool
+	typ  string
+	args []arg
+}
+
+type file struct {
+	name string
+	data []byte
+}
+
+// An arg is a word, a quoted string, or a list of args in ( ) or { }.
+type arg struct {
+	s      string
+	quoted bool
+	kind   byte // 0, '(' or '{'
+	list   []arg
+}
+
+func (a arg) String() string {
+	switch a.kind {
+	case '(', '{':
+		s := make([]string, len(a.list))
+		for i, a := range a.list {
+			s[i] = a.String()
+		}
+		if a.kind == '(' {
+			return "(" + strings.Join(s, " ") + ")"
+		}
+		return "{" + strings.Join(s, " ") + "}"
+	}
+	if a.quoted {
+		return strconv.Quote(a.s)
+	}
+	return a.s
+}
+
+func (a arg) is(s string) bool {
+	return a.kind == 0 && !a.quoted && a.s == s
+}
+
+// RunScripts runs each script whose file matches pattern, as subtests of
+// t, on the NineServers ns makes, served by a Listener made with opts.
+func RunScripts(t *testing.T, ns protocol.NsCreator, pattern string, opts ...protocol.ListenerOpt) {
+	files, err := filepath.Glob(pattern)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatalf("no scripts match %v", pattern)
+	}
+	for _, f := range files {
+		f := f
+		t.Run(filepath.Base(f), func(t *testing.T) {
+			RunScript(t, ns, f, opts...)
+		})
+	}
+}
+
+// RunScript runs the script in file on the NineServers ns makes, served
+// by a Listener made with opts.
+func RunScript(t *testing.T, ns protocol.NsCreator, file string, opts ...protocol.ListenerOpt) {
+	t.Helper()
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := ParseScript(file, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Run(t, ns, opts...)
+}
+
+// ParseScript parses the script in b. Name is used in errors.
+func ParseScript(name string, b []byte) (*Script, error) {
+	s := &Script{Name: name}
+	f := -1
+	for i, l := range strings.SplitAfter(string(b), "\n") {
+		if n, ok := marker(l); ok {
+			s.files = append(s.files, file{name: n})
+			f = len(s.files) - 1
+			continue
+		}
+		if f >= 0 {
+			s.files[f].data = append(s.files[f].data, l...)
+			continue
+		}
+		text := strings.TrimSpace(l)
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		ln, err := parseLine(i+1, text)
+		if err != nil {
+			return nil, fmt.Errorf("%v:%d: %v", name, i+1, err)
+		}
+		s.lines = append(s.lines, ln)
+	}
+	return s, nil
+}
+
+// marker returns the name in a file marker line, -- name --.
+func marker(l string) (string, bool) {
+	l = strings.TrimRight(l, "\r\n")
+	if len(l) < 7 || !strings.HasPrefix(l, "-- ") || !strings.HasSuffix(l, " --") {
+		return "", false
+	}
+	return strings.TrimSpace(l[3 : len(l)-3]), true
+}
+
+func parseLine(n int, text string) (line, error) {
+	l := line{n: n, text: text}
+	switch text[0] {
+	case '>':
+		l.send = true
+	case '<':
+	default:
+		return l, fmt.Errorf("want > or <, got %q", text)
+	}
+	args, err := lex(text[1:])
+	if err != nil {
+		return l, err
+	}
+	if len(args) == 0 || args[0].kind != 0 || args[0].quoted {
+		return l, fmt.Errorf("no message type")
+	}
+	l.typ, l.args = args[0].s, args[1:]
+	m := newMsg(l.typ)
+	switch {
+	case m == nil:
+		return l, fmt.Errorf("unknown message type %v", l.typ)
+	case l.send != (l.typ[0] == 'T'):
+		return l, fmt.Errorf("%v is not a message to %v", l.typ, map[bool]string{true: "send", false: "get"}[l.send])
+	case l.send:
+		// Make sure it can be built.
+		_, err = build(l.typ, l.args, 0)
+	}
+	return l, err
+}
+
+// lex splits s into args.
+func lex(s string) ([]arg, error) {
+	args, _, err := lexList(s, 0)
+	return args, err
+}
+
+// lexList lexes args up to the byte end, or the end of s if end is 0,
+// and returns the rest of s.
+func lexList(s string, end byte) ([]arg, string, error) {
+	var args []arg
+	for {
+		s = strings.TrimLeft(s, " \t")
+		if s == "" {
+			if end != 0 {
+				return nil, "", fmt.Errorf("missing %c", end)
+			}
+			return args, "", nil
+		}
+		switch c := s[0]; c {
+		case end:
+			return args, s[1:], nil
+		case ')', '}':
+			return nil, "", fmt.Errorf("unexpected %c", c)
+		case '(', '{':
+			close := byte(')')
+			if c == '{' {
+				close = '}'
+			}
+			l, rest, err := lexList(s[1:], close)
+			if err != nil {
+				return nil, "", err
+			}
+			args = append(args, arg{kind: c, list: l})
+			s = rest
+		case '"', '`':
+			i := 1
+			for ; i < len(s) && s[i] != c; i++ {
+				if s[i] == '\\' && c == '"' {
+					i++
+				}
+			}
+			if i >= len(s) {
+				return nil, "", fmt.Errorf("unterminated string %v", s)
+			}
+			u, err := strconv.Unquote(s[:i+1])
+			if err != nil {
+				return nil, "", fmt.Errorf("%v: %v", s[:i+1], err)
+			}
+			args = append(args, arg{s: u, quoted: true})
+			s = s[i+1:]
+		default:
+			i := strings.IndexAny(s, " \t(){}")
+			if i < 0 {
+				i = len(s)
+			}
+			args = append(args, arg{s: s[:i]})
+			s = s[i:]
+		}
+	}
+}
+
+// messages are the messages scripts know.
+var messages = []protocol.Msg{
+	&protocol.TversionMsg{}, &protocol.RversionMsg{},
+	&protocol.TauthMsg{}, &protocol.RauthMsg{},
+	&protocol.TattachMsg{}, &protocol.RattachMsg{},
+	&protocol.RerrorMsg{},
+	&protocol.TflushMsg{}, &protocol.RflushMsg{},
+	&protocol.TwalkMsg{}, &protocol.RwalkMsg{},
+	&protocol.TopenMsg{}, &protocol.RopenMsg{},
+	&protocol.TcreateMsg{}, &protocol.RcreateMsg{},
+	&protocol.TreadMsg{}, &protocol.RreadMsg{},
+	&protocol.TwriteMsg{}, &protocol.RwriteMsg{},
+	&protocol.TclunkMsg{}, &protocol.RclunkMsg{},
+	&protocol.TremoveMsg{}, &protocol.RremoveMsg{},
+	&protocol.TstatMsg{}, &protocol.RstatMsg{},
+	&protocol.TwstatMsg{}, &protocol.RwstatMsg{},
+}
+
+// newMsg returns a new message of the type named typ, or nil.
+func newMsg(typ string) protocol.Msg {
+	for _, m := range messages {
+		if protocol.RPCNames[m.Type()] == typ {
+			return reflect.New(reflect.TypeOf(m).Elem()).Interface().(protocol.Msg)
+		}
+	}
+	return nil
+}
+
+var (
+	qidType   = reflect.TypeOf(protocol.QID{})
+	bytesType = reflect.TypeOf([]byte(nil))
+)
+
+// constants are the names a number can be given by.
+var constants = map[string]uint64{
+	"NOTAG": uint64(protocol.NOTAG), "NOFID": uint64(protocol.NOFID),
+	"OREAD": protocol.OREAD, "OWRITE": protocol.OWRITE, "ORDWR": protocol.ORDWR, "OEXEC": protocol.OEXEC,
+	"OTRUNC": protocol.OTRUNC, "OCEXEC": protocol.OCEXEC, "ORCLOSE": protocol.ORCLOSE, "OAPPEND": protocol.OAPPEND, "OEXCL": protocol.OEXCL,
+	"DMDIR": protocol.DMDIR, "DMAPPEND": protocol.DMAPPEND, "DMEXCL": protocol.DMEXCL, "DMMOUNT": protocol.DMMOUNT,
+	"DMAUTH": protocol.DMAUTH, "DMTMP": protocol.DMTMP,
+	"QTDIR": protocol.QTDIR, "QTAPPEND": protocol.QTAPPEND, "QTEXCL": protocol.QTEXCL, "QTMOUNT": protocol.QTMOUNT,
+	"QTAUTH": protocol.QTAUTH, "QTTMP": protocol.QTTMP, "QTFILE": protocol.QTFILE,
+}
+
+// number returns the number a is.
+func number(a arg) (uint64, error) {
+	if a.kind != 0 || a.quoted {
+		return 0, fmt.Errorf("want a number, got %v", a)
+	}
+	var n uint64
+	for _, s := range strings.Split(a.s, "|") {
+		if c, ok := constants[s]; ok {
+			n |= c
+			continue
+		}
+		if strings.HasPrefix(s, "-") {
+			i, err := strconv.ParseInt(s, 0, 64)
+			if err != nil {
+				return 0, err
+			}
+			n |= uint64(i)
+			continue
+		}
+		u, err := strconv.ParseUint(s, 0, 64)
+		if err != nil {
+			return 0, err
+		}
+		n |= u
+	}
+	return n, nil
+}
+
+// build returns the T message typ with tag and args.
+func build(typ string, args []arg, tag protocol.Tag) ([]byte, error) {
+	m := newMsg(typ)
+	v := reflect.ValueOf(m).Elem()
+	v.Field(0).SetUint(uint64(tag))
+	for i := 1; i < v.NumField(); i++ {
+		f, name := v.Field(i), v.Type().Field(i).Name
+		if f.Kind() == reflect.Slice && f.Type() != bytesType {
+			// The names of a Twalk.
+			for _, a := range args {
+				if a.kind != 0 {
+					return nil, fmt.Errorf("%v: want a name, got %v", typ, a)
+				}
+				f.Set(reflect.Append(f, reflect.ValueOf(a.s)))
+			}
+			args = nil
+			continue
+		}
+		if len(args) == 0 {
+			return nil, fmt.Errorf("%v: no %v", typ, name)
+		}
+		if err := set(f, name, args[0]); err != nil {
+			return nil, fmt.Errorf("%v %v: %v", typ, name, err)
+		}
+		args = args[1:]
+	}
+	if len(args) > 0 {
+		return nil, fmt.Errorf("%v: too many fields", typ)
+	}
+	var b bytes.Buffer
+	m.Encode(&b)
+	return b.Bytes(), nil
+}
+
+// set sets the field f, named name, to a.
+func set(f reflect.Value, name string, a arg) error {
+	switch {
+	case f.Type() == qidType:
+		if a.kind != '(' || len(a.list) != 3 {
+			return fmt.Errorf("want a QID, got %v", a)
+		}
+		for i, a := range a.list {
+			if err := set(f.Field(i), "", a); err != nil {
+				return err
+			}
+		}
+	case f.Type() == bytesType && name == "B":
+		if a.kind != '{' {
+			return fmt.Errorf("want a stat, got %v", a)
+		}
+		d := NoChange()
+		if err := setDir(&d, a.list); err != nil {
+			return err
+		}
+		var b bytes.Buffer
+		protocol.Marshaldir(&b, d)
+		f.SetBytes(b.Bytes())
+	case f.Type() == bytesType:
+		if a.kind != 0 {
+			return fmt.Errorf("want data, got %v", a)
+		}
+		f.SetBytes([]byte(a.s))
+	case f.Kind() == reflect.String:
+		if a.kind != 0 {
+			return fmt.Errorf("want a string, got %v", a)
+		}
+		f.SetString(a.s)
+	case f.Kind() >= reflect.Uint && f.Kind() <= reflect.Uint64:
+		n, err := number(a)
+		if err != nil {
+			return err
+		}
+		f.SetUint(n)
+	case f.Kind() >= reflect.Int && f.Kind() <= reflect.Int64:
+		n, err := number(a)
+		if err != nil {
+			return err
+		}
+		f.SetInt(int64(n))
+	default:
+		return fmt.Errorf("cannot set a %v", f.Type())
+	}
+	return nil
+}
+
+// dirField returns the field of d named key, in any case.
+func dirField(d reflect.Value, key string) (reflect.Value, error) {
+	for i := 0; i < d.NumField(); i++ {
+		if strings.EqualFold(d.Type().Field(i).Name, key) {
+			return d.Field(i), nil
+		}
+	}
+	return reflect.Value{}, fmt.Errorf("no stat field %v", key)
+}
+
+// setDir sets the fields of d named in args, name value ....
+func setDir(d *protocol.Dir, args []arg) error {
+	if len(args)%2 != 0 {
+		return fmt.Errorf("want name value pairs in a stat")
+	}
+	v := reflect.ValueOf(d).Elem()
+	for i := 0; i < len(args); i += 2 {
+		f, err := dirField(v, args[i].s)
+		if err != nil {
+			return err
+		}
+		if err := set(f, "", args[i+1]); err != nil {
+			return fmt.Errorf("%v: %v", args[i].s, err)
+		}
+	}
+	return nil
+}
+
+// match reports whether the reply m matches args.
+func match(m protocol.Msg, args []arg) (bool, error) {
+	v := reflect.ValueOf(m).Elem()
+	for i := 1; i < v.NumField(); i++ {
+		f, name := v.Field(i), v.Type().Field(i).Name
+		if len(args) > 0 && args[0].is("...") {
+			return true, nil
+		}
+		if f.Kind() == reflect.Slice && f.Type() != bytesType {
+			// The QIDs of an Rwalk.
+			for j := 0; j < f.Len(); j++ {
+				if len(args) > 0 && args[0].is("...") {
+					return true, nil
+				}
+				if len(args) == 0 {
+					return false, nil
+				}
+				if ok, err := matchOne(f.Index(j), "", args[0]); !ok || err != nil {
+					return false, err
+				}
+				args = args[1:]
+			}
+			continue
+		}
+		if len(args) == 0 {
+			return false, nil
+		}
+		if ok, err := matchOne(f, name, args[0]); !ok || err != nil {
+			return false, err
+		}
+		args = args[1:]
+	}
+	return len(args) == 0 || len(args) == 1 && args[0].is("..."), nil
+}
+
+// matchOne reports whether the field f, named name, matches a.
+func matchOne(f reflect.Value, name string, a arg) (bool, error) {
+	if a.is("*") {
+		return true, nil
+	}
+	switch {
+	case f.Type() == qidType:
+		if a.kind != '(' || len(a.list) != 3 {
+			return false, fmt.Errorf("want a QID, got %v", a)
+		}
+		for i, a := range a.list {
+			if ok, err := matchOne(f.Field(i), "", a); !ok || err != nil {
+				return false, err
+			}
+		}
+		return true, nil
+	case f.Type() == bytesType && name == "B":
+		if a.kind != '{' || len(a.list)%2 != 0 {
+			return false, fmt.Errorf("want a stat, got %v", a)
+		}
+		d, err := protocol.Unmarshaldir(bytes.NewBuffer(f.Bytes()))
+		if err != nil {
+			return false, nil
+		}
+		v := reflect.ValueOf(d)
+		for i := 0; i < len(a.list); i += 2 {
+			f, err := dirField(v, a.list[i].s)
+			if err != nil {
+				return false, err
+			}
+			if ok, err := matchOne(f, "", a.list[i+1]); !ok || err != nil {
+				return false, err
+			}
+		}
+		return true, nil
+	case f.Type() == bytesType:
+		return matchString(string(f.Bytes()), a)
+	case f.Kind() == reflect.String:
+		return matchString(f.String(), a)
+	case f.Kind() >= reflect.Uint && f.Kind() <= reflect.Uint64:
+		n, err := number(a)
+		return err == nil && n == f.Uint(), err
+	case f.Kind() >= reflect.Int && f.Kind() <= reflect.Int64:
+		n, err := number(a)
+		return err == nil && int64(n) == f.Int(), err
+	}
+	return false, fmt.Errorf("cannot match a %v", f.Type())
+}
+
+func matchString(s string, a arg) (bool, error) {
+	switch {
+	case a.kind != 0:
+		return false, fmt.Errorf("want a string, got %v", a)
+	case a.quoted:
+		return s == a.s, nil
+	}
+	return path.Match(a.s, s)
+}
+
+// Run runs the script on the NineServers ns makes, served by a Listener
+// made with opts.
+func (s *Script) Run(t *testing.T, ns protocol.NsCreator, opts ...protocol.ListenerOpt) {
+	t.Helper()
+	if len(s.files) > 0 {
+		c := Dial(t, ns, opts...)
+		c.Attach(0)
+		tops := map[string]bool{}
+		for _, f := range s.files {
+			c.mkpath(f.name, f.data)
+			tops[strings.Split(f.name, "/")[0]] = true
+		}
+		defer func() {
+			for n := range tops {
+				c.removeAll(0, n)
+			}
+			c.Close()
+		}()
+	}
+
+	c := listen(t, ns, opts...)
+	defer c.Close()
+	// The pipe has no buffer, so messages are sent from another
+	// goroutine while the replies are read.
+	out := make(chan []byte, len(s.lines))
+	defer close(out)
+	go func() {
+		for b := range out {
+			if _, err := c.c.Write(b); err != nil {
+				return
+			}
+		}
+	}()
+
+	var tag protocol.Tag
+	sent := map[protocol.Tag]string{}
+	for _, l := range s.lines {
+		if l.send {
+			tg := protocol.NOTAG
+			if l.typ != "Tversion" {
+				tag++
+				tg = tag
+			}
+			b, err := build(l.typ, l.args, tg)
+			if err != nil {
+				t.Fatalf("%v:%d: %v", s.Name, l.n, err)
+			}
+			sent[tg] = l.typ
+			out <- b
+			continue
+		}
+		r := c.Recv()
+		typ, ok := sent[r.Tag()]
+		if !ok {
+			t.Fatalf("%v:%d: got %v, to tag %d, which was not sent", s.Name, l.n, Format(r), r.Tag())
+		}
+		delete(sent, r.Tag())
+		if protocol.RPCNames[r.Type()] != l.typ {
+			t.Fatalf("%v:%d: %v tag %d: got %v, want %v", s.Name, l.n, typ, r.Tag(), Format(r), strings.TrimSpace(l.text[1:]))
+		}
+		ok, err := match(r, l.args)
+		if err != nil {
+			t.Fatalf("%v:%d: %v", s.Name, l.n, err)
+		}
+		if !ok {
+			t.Fatalf("%v:%d: %v tag %d: got %v, want %v", s.Name, l.n, typ, r.Tag(), Format(r), strings.TrimSpace(l.text[1:]))
+		}
+	}
+}
+
+// mkpath makes the file name holding data, and the directories on its
+// path, in the root.
+func (c *Conn) mkpath(name string, data []byte) {
+	c.t.Helper()
+	dir := strings.HasSuffix(name, "/")
+	elems := strings.Split(strings.TrimSuffix(name, "/"), "/")
+	for i := range elems {
+		last := i == len(elems)-1
+		if q, err := c.Walk(0, 1, elems[:i+1]...); err == nil && len(q) == i+1 {
+			c.Clunk(1)
+			if last {
+				c.t.Fatalf("making %v: it is there already", name)
+			}
+			continue
+		}
+		c.WalkAll(0, 1, elems[:i]...)
+		perm, mode := protocol.Perm(0644), protocol.Mode(protocol.OWRITE)
+		if !last || dir {
+			perm, mode = protocol.DMDIR|0755, protocol.OREAD
+		}
+		if _, err := c.Create(1, elems[i], perm, mode); err != nil {
+			c.t.Fatalf("making %v: %v", name, err)
+		}
+		for off := 0; last && off < len(data); {
+			n := len(data) - off
+			if n > Msize-protocol.IOHDRSZ {
+				n = Msize - protocol.IOHDRSZ
+			}
+			w, err := c.Write(1, protocol.Offset(off), data[off:off+n])
+			if err != nil || w <= 0 {
+				c.t.Fatalf("writing %v: wrote %d, %v", name, w, err)
+			}
+			off += int(w)
+		}
+		c.Clunk(1)
+	}
+}
+
+// Format formats a message as a script has it.
+func Format(m protocol.Msg) string {
+	s := []string{protocol.RPCNames[m.Type()]}
+	v := reflect.ValueOf(m).Elem()
+	for i := 1; i < v.NumField(); i++ {
+		f, name := v.Field(i), v.Type().Field(i).Name
+		if f.Kind() == reflect.Slice && f.Type() != bytesType {
+			for j := 0; j < f.Len(); j++ {
+				s = append(s, format(f.Index(j), ""))
+			}
+			continue
+		}
+		s = append(s, format(f, name))
+	}
+	return strings.Join(s, " ")
+}
+
+func format(f reflect.Value, name string) string {
+	switch v := f.Interface().(type) {
+	case protocol.QID:
+		return fmt.Sprintf("(%#x %d %d)", v.Type, v.Version, v.Path)
+	case []byte:
+		if name != "B" {
+			return quote(string(v))
+		}
+		d, err := protocol.Unmarshaldir(bytes.NewBuffer(v))
+		if err != nil {
+			return quote(string(v))
+		}
+		return fmt.Sprintf("{type %d dev %d qid %v mode %#o atime %d mtime %d length %d name %v user %v group %v moduser %v}",
+			d.Type, d.Dev, format(reflect.ValueOf(d.QID), ""), d.Mode, d.Atime, d.Mtime, d.Length,
+			quote(d.Name), quote(d.User), quote(d.Group), quote(d.ModUser))
+	case string:
+		return quote(v)
+	case protocol.Mode, protocol.Perm:
+		return fmt.Sprintf("%#o", v)
+	}
+	return fmt.Sprint(f.Interface())
+}
+
+// quote quotes s if it would not be one word in a script.
+func quote(s string) string {
+	if s == "" || s == "..." || strings.ContainsAny(s, " \t(){}*?[\"`\\") || strconv.Quote(s) != `"`+s+`"` {
+		return strconv.Quote(s)
+	}
+	return s
+}
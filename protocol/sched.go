@@ -0,0 +1,296 @@
+// Copyright 2019 The Ninep Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protocol
+
+import (
+	"bytes"
+	"sync"
+	"time"
+)
+
+// A Rate limits a stream of requests: Ops messages a second and Bytes
+// bytes of Tread and Twrite data a second, in bursts of up to a second's
+// worth, or of one op if that is less. Zero means no limit.
+type Rate struct {
+	Ops   float64
+	Bytes float64
+}
+
+// A Scheduler shares a Listener's servers out among its users. Before a
+// message is dispatched it must get tokens from the buckets of its
+// connection and of the user the connection attached as, and waits for
+// them if it cannot, so one user copying a tree does not starve the rest.
+//
+// Metadata messages, i.e. everything but Tread and Twrite, cost an op
+// and go before data messages: a Tread or Twrite waits while any metadata
+// message of the same user is waiting. Tread and Twrite also cost the
+// bytes they move; a bucket may go into debt for a big one, which those
+// after it pay off. Tversion and Tflush are never held up.
+//
+// A message stops waiting, and is refused, when its connection closes or
+// the Listener shuts down. The buckets of a user with nothing waiting are
+// dropped once they are full, as they are then no different from new ones.
+type Scheduler struct {
+	PerUser Rate
+	PerConn Rate
+
+	mu    sync.Mutex
+	users map[string]*limiter
+	waits int64
+	// evictAt is how many users there may be before the idle ones are
+	// dropped.
+	evictAt int
+}
+
+// minEvict is the least evictAt, so that a few users are not swept for
+// every new one.
+const minEvict = 64
+
+// NewScheduler returns a Scheduler with the given limits.
+func NewScheduler(perUser, perConn Rate) *Scheduler {
+	return &Scheduler{
+		PerUser: perUser,
+		PerConn: perConn,
+		users:   make(map[string]*limiter),
+	}
+}
+
+// Schedule gives the Listener a Scheduler with the given limits.
+func Schedule(perUser, perConn Rate) ListenerOpt {
+	return func(l *Listener) error {
+		l.Scheduler = NewScheduler(perUser, perConn)
+		return nil
+	}
+}
+
+// A bucket is a token bucket that fills at rate tokens a second, up to
+// rate tokens, or 1 if rate is less: a bucket that could never hold a
+// whole token would never let an op through.
+type bucket struct {
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+// max is how many tokens the bucket holds.
+func (b *bucket) max() float64 {
+	if b.rate < 1 {
+		return 1
+	}
+	return b.rate
+}
+
+func (b *bucket) fill(now time.Time) {
+	max := b.max()
+	if b.last.IsZero() {
+		b.tokens = max
+	} else {
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+	}
+	if b.tokens > max {
+		b.tokens = max
+	}
+	b.last = now
+}
+
+// need returns how long until the bucket has n tokens, or, if n is 0,
+// is out of debt.
+func (b *bucket) need(n float64) time.Duration {
+	if b.rate == 0 || b.tokens >= n {
+		return 0
+	}
+	return time.Duration((n - b.tokens) / b.rate * float64(time.Second))
+}
+
+// A limiter is the buckets for one connection or user. For a user, meta
+// counts the metadata messages waiting, and wake is closed when it goes
+// to 0; refs counts the messages in acquire, which keep it from being
+// dropped.
+type limiter struct {
+	ops, bytes bucket
+	meta       int
+	wake       chan struct{}
+	refs       int
+}
+
+func newLimiter(r Rate) *limiter {
+	return &limiter{
+		ops:   bucket{rate: r.Ops},
+		bytes: bucket{rate: r.Bytes},
+		wake:  make(chan struct{}),
+	}
+}
+
+// wait returns how long until the limiter can take a message moving n
+// bytes; 0 means it can now.
+func (l *limiter) wait(now time.Time, n int) time.Duration {
+	l.ops.fill(now)
+	l.bytes.fill(now)
+	d := l.ops.need(1)
+	if n > 0 {
+		if b := l.bytes.need(0); b > d {
+			d = b
+		}
+	}
+	return d
+}
+
+func (l *limiter) take(n int) {
+	if l.ops.rate > 0 {
+		l.ops.tokens--
+	}
+	if l.bytes.rate > 0 {
+		l.bytes.tokens -= float64(n)
+	}
+}
+
+// full reports whether the limiter's buckets are full at now.
+func (l *limiter) full(now time.Time) bool {
+	l.ops.fill(now)
+	l.bytes.fill(now)
+	return l.ops.tokens >= l.ops.max() && l.bytes.tokens >= l.bytes.max()
+}
+
+// user returns the limiter for uname. Making a new one may first drop
+// those of idle users.
+func (s *Scheduler) user(now time.Time, uname string) *limiter {
+	u, ok := s.users[uname]
+	if !ok {
+		if len(s.users) >= s.evictAt {
+			s.evict(now)
+		}
+		u = newLimiter(s.PerUser)
+		s.users[uname] = u
+	}
+	return u
+}
+
+// evict drops the limiters of users with nothing waiting and full
+// buckets, and sets evictAt to twice the number left, so that a sweep
+// costs no more than the users made since the last one.
+func (s *Scheduler) evict(now time.Time) {
+	for uname, u := range s.users {
+		if u.refs == 0 && u.full(now) {
+			delete(s.users, uname)
+		}
+	}
+	s.evictAt = 2 * len(s.users)
+	if s.evictAt < minEvict {
+		s.evictAt = minEvict
+	}
+}
+
+// acquire waits until conn, whose user is uname, may send a message of
+// type t that moves n bytes. If it has to wait, it gives up once cancel
+// is closed, and returns false.
+func (s *Scheduler) acquire(clock Clock, conn *limiter, uname string, t MType, n int, cancel <-chan struct{}) bool {
+	if t == Tversion || t == Tflush {
+		return true
+	}
+	bulk := t == Tread || t == Twrite
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u := s.user(clock.Now(), uname)
+	u.refs++
+	defer func() { u.refs-- }()
+	for waited := false; ; waited = true {
+		var d time.Duration
+		var wake chan struct{}
+		if bulk && u.meta > 0 {
+			wake = u.wake
+		} else {
+			now := clock.Now()
+			d = conn.wait(now, n)
+			if ud := u.wait(now, n); ud > d {
+				d = ud
+			}
+			if d == 0 {
+				conn.take(n)
+				u.take(n)
+				if waited {
+					s.waits++
+				}
+				return true
+			}
+		}
+		if !bulk {
+			u.meta++
+		}
+		s.mu.Unlock()
+
+		var timer Timer
+		done := make(chan struct{})
+		if d > 0 {
+			timer = clock.AfterFunc(d, func() { close(done) })
+		}
+		cancelled := false
+		select {
+		case <-done:
+		case <-wake:
+		case <-cancel:
+			cancelled = true
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+
+		s.mu.Lock()
+		if !bulk {
+			if u.meta--; u.meta == 0 {
+				close(u.wake)
+				u.wake = make(chan struct{})
+			}
+		}
+		if cancelled {
+			return false
+		}
+	}
+}
+
+// Waits returns the number of messages that have had to wait their turn.
+func (s *Scheduler) Waits() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.waits
+}
+
+// schedule waits for the Scheduler to let the message in b through. It
+// returns the error the connection was stopped with, if that happens
+// first.
+func (c *conn) schedule(s *Scheduler, b *bytes.Buffer, t MType) error {
+	var n int
+	switch t {
+	case Tread:
+		var m TreadMsg
+		if decodeTread(b.Bytes(), &m) == nil {
+			n = int(m.Len)
+		}
+	case Twrite:
+		// The data is all but the tag, fid, offset and count. A message
+		// too short to have them moves none; Dispatch will say so.
+		if n = b.Len() - 2 - 4 - 8 - 4; n < 0 {
+			n = 0
+		}
+	}
+	if c.limiter == nil {
+		c.limiter = newLimiter(s.PerConn)
+	}
+	c.mu.Lock()
+	uname := c.uname
+	c.mu.Unlock()
+	if !s.acquire(clockOr(c.listener.Clock), c.limiter, uname, t, n, c.gone) {
+		return c.stopped
+	}
+	return nil
+}
+
+// stop closes gone, so that a message waiting for the Scheduler gives up
+// with err, which is why the connection can go no further.
+func (c *conn) stop(err error) {
+	c.stopOnce.Do(func() {
+		c.stopped = err
+		close(c.gone)
+	})
+}
@@ -8,18 +8,30 @@ package protocol
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"net"
+	"runtime/debug"
+	"sort"
 	"sync"
-
-	quic "github.com/lucas-clemente/quic-go"
-
+	"sync/atomic"
 	"time"
 )
 
 const DefaultAddr = ":5640"
 
+// ErrPanic is what a client is told when the NineServer panics answering
+// its message.
+var ErrPanic = errors.New("internal server error")
+
+// ErrShutdown is what a client is told when a message of its would wait
+// for the Scheduler after the Listener has been shut down.
+var ErrShutdown = errors.New("server shutting down")
+
+// An NsCreator makes the NineServer for a new connection. A server with
+// only some of the NineServer methods can embed BaseServer for the rest,
+// or be made a NineServer with Adapt.
 type NsCreator func() NineServer
 
 type Listener struct {
@@ -28,13 +40,72 @@ type Listener struct {
 	// TCP address to listen on, default is DefaultAddr
 	Addr string
 
-	// Trace function for logging
-	Trace Tracer
+	// Observer, if not nil, is told of each Event on the Listener's
+	// connections. Trace, if Observer is nil, gets each as a line.
+	Observer Observer
+	Trace    Tracer
+
+	// Extensions the Listener's servers answer, if any.
+	Extensions *Registry
+
+	// FidTable, if set, gives each connection a FidTable to check
+	// messages against.
+	FidTable bool
+
+	// MaxPanics, if not zero, is how many panics a connection's NineServer
+	// may have before the connection is closed. Each panic is answered
+	// with Rerror either way.
+	MaxPanics int
+
+	// Limits bound what each client can do.
+	Limits Limits
+
+	// ReadTimeout is how long a connection may go without sending a
+	// message, WriteTimeout how long writing a reply may take, and
+	// SessionTimeout how long a connection may last at all. A connection
+	// that goes over one is closed. Zero means no timeout. Clock is the
+	// clock for them; nil means the real one.
+	ReadTimeout    time.Duration
+	WriteTimeout   time.Duration
+	SessionTimeout time.Duration
+	Clock          Clock
+
+	// Scheduler, if not nil, decides when each message is dispatched.
+	Scheduler *Scheduler
+
+	// Metrics, if not nil, counts the messages served.
+	Metrics *Metrics
+
+	// Spans, if not nil, gets a span for each message served.
+	Spans *SpanLog
+
+	// CtlAname, if not empty, is the aname that attaches to the
+	// Listener's control tree, usually DefaultCtlAname. CtlUsers are
+	// the users that may; if there are none, nobody may. See ctlServer.
+	//
+	// The uname of a Tattach is whatever the client says it is, as the
+	// Listener does no authentication, and the tree can kill connections.
+	// So CtlAuth must vouch for the connection as well: it is called with
+	// the connection and the uname, and the attach is refused unless it
+	// returns true. Without CtlAuth, nobody may attach.
+	CtlAname string
+	CtlUsers []string
+	CtlAuth  func(c net.Conn, uname string) bool
+
+	// tracing is set by "trace on" in the control tree; use atomic.
+	tracing int32
+
+	// panics counts the panics on all connections; use atomic.
+	panics   int64
+	counters counters
 
 	// mu guards below
 	mu sync.Mutex
 
 	listeners map[net.Listener]struct{}
+	conns     map[*conn]struct{}
+	hosts     map[string]int // connections per remote host
+	lastID    uint64         // the id of the last connection
 }
 
 // Server is a 9p server.
@@ -43,25 +114,74 @@ type Listener struct {
 type Server struct {
 	NS NineServer
 	D  Dispatcher
+
+	// Extensions is the set of extensions the server knows, and
+	// extensions the ones it agreed on in the last Tversion.
+	Extensions *Registry
+	extensions map[MType]*Extension
+
+	// Fids, if not nil, checks each message before NS sees it.
+	Fids *FidTable
 }
 
 type conn struct {
 	listener *Listener
 
+	// id is the connection's number, unique to the Listener.
+	id uint64
+
 	// server on which the connection arrived.
 	server *Server
 
+	// layers is server.NS and each NineServer it wraps; see Unwrapper.
+	layers []interface{}
+
 	// rwc is the underlying network connection.
 	rwc net.Conn
 
 	// remoteAddr is rwc.RemoteAddr().String(). See note in net/http/server.go.
 	remoteAddr string
 
+	// host is the host part of remoteAddr.
+	host string
+
 	// replies
 	replies chan RPCReply
 
 	// dead is set to true when we finish reading packets.
 	dead bool
+
+	// panics is how many times the server has panicked.
+	panics int
+
+	// uname is the user the connection last attached as, and limiter
+	// its buckets, for the Scheduler. reason is why the connection is
+	// being closed, if something else is closing it. pending is how many
+	// messages have been read and not answered, and buffered how many
+	// bytes they have; freed, if not nil, is closed when some of those
+	// are answered. mu guards uname, which ConnInfo reads, reason,
+	// pending, buffered and freed.
+	mu       sync.Mutex
+	uname    string
+	reason   error
+	pending  int
+	buffered int64
+	freed    chan struct{}
+	limiter  *limiter
+
+	// gone is closed, and stopped set to why, when the connection can go
+	// no further: its reader has stopped or the Listener is shutting
+	// down.
+	gone     chan struct{}
+	stopOnce sync.Once
+	stopped  error
+
+	// meter feeds the Listener's Metrics.
+	meter *meter
+
+	// msize is the message size the last Tversion agreed on, or 0 if
+	// there has not been one.
+	msize MaxSize
 }
 
 func NewListener(nsCreator NsCreator, opts ...ListenerOpt) (*Listener, error) {
@@ -80,13 +200,38 @@ func NewListener(nsCreator NsCreator, opts ...ListenerOpt) (*Listener, error) {
 
 func (l *Listener) newConn(rwc net.Conn) (*conn, error) {
 	ns := l.nsCreator()
-	server := &Server{NS: ns, D: Dispatch}
+	if l.CtlAname != "" {
+		ns = &ctlServer{NineServer: ns, l: l, rwc: rwc, fids: make(map[FID]*ctlFid)}
+	}
+	server := &Server{NS: ns, D: Dispatch, Extensions: l.Extensions}
+	if l.FidTable || l.Limits.MaxFids > 0 || l.Limits.MaxInFlight > 0 {
+		server.Fids = NewFidTable()
+		server.Fids.maxFids = l.Limits.MaxFids
+		server.Fids.maxInFlight = l.Limits.MaxInFlight
+		server.Fids.counters = &l.counters
+	}
 
 	c := &conn{
 		server:   server,
+		layers:   layers(ns),
 		listener: l,
 		rwc:      rwc,
 		replies:  make(chan RPCReply, NumTags),
+		gone:     make(chan struct{}),
+	}
+	if server.Fids != nil {
+		for _, v := range c.layers {
+			if f, ok := v.(FidTableSetter); ok {
+				f.SetFidTable(server.Fids)
+			}
+		}
+	}
+	if rwc != nil {
+		c.remoteAddr = rwc.RemoteAddr().String()
+		c.host = remoteHost(rwc.RemoteAddr())
+		if server.Fids != nil {
+			server.Fids.remote = c.remoteAddr
+		}
 	}
 
 	return c, nil
@@ -122,7 +267,7 @@ func (l *Listener) closeListenersLocked() error {
 
 // Serve accepts incoming connections on the Listener and calls e.Accept on
 // each connection.
-func (l *Listener) Serve(ln quic.Listener) error {
+func (l *Listener) Serve(ln net.Listener) error {
 	defer ln.Close()
 
 	var tempDelay time.Duration // how long to sleep on accept failure
@@ -143,7 +288,7 @@ func (l *Listener) Serve(ln quic.Listener) error {
 				if max := 1 * time.Second; tempDelay > max {
 					tempDelay = max
 				}
-				l.logf("filesystem: Accept error: %v; retrying in %v", err, tempDelay)
+				l.observe(AcceptError{Err: err, Retry: tempDelay})
 				time.Sleep(tempDelay)
 				continue
 			}
@@ -152,50 +297,75 @@ func (l *Listener) Serve(ln quic.Listener) error {
 		tempDelay = 0
 
 		if err := l.Accept(conn); err != nil {
+			if err == ErrTooManyConns || err == ErrTooManyConnsAddr {
+				l.observe(AcceptError{Remote: conn.RemoteAddr().String(), Err: err})
+				continue
+			}
 			return err
 		}
 	}
 }
 
 // Accept a new connection, typically called via Serve but may be called
-// directly if there's a connection from an exotic listener.
+// directly if there's a connection from an exotic listener. A connection
+// over the Limits is closed, and Accept returns ErrTooManyConns or
+// ErrTooManyConnsAddr.
 func (l *Listener) Accept(conn net.Conn) error {
 	c, err := l.newConn(conn)
 	if err != nil {
 		return err
 	}
+	if err := l.trackConn(c); err != nil {
+		c.closeLayers()
+		conn.Close()
+		return err
+	}
 
 	go c.serve()
 	return nil
 }
 
 // Shutdown closes all active listeners. It does not close all active
-// connections but probably should.
+// connections but probably should; it does stop them waiting for the
+// Scheduler: a message that is waiting, or would wait, gets ErrShutdown,
+// and its connection is closed.
 func (l *Listener) Shutdown() error {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
+	for c := range l.conns {
+		c.stop(ErrShutdown)
+	}
 	return l.closeListenersLocked()
 }
 
-func (l *Listener) String() string {
-	// TODO
-	return ""
+// Panics returns the number of panics the Listener's servers have
+// recovered from.
+func (l *Listener) Panics() int64 {
+	return atomic.LoadInt64(&l.panics)
 }
 
-func (l *Listener) logf(format string, args ...interface{}) {
-	if l.Trace != nil {
-		l.Trace(format, args...)
-	}
+// Serving reports whether the Listener is serving on any net.Listener.
+func (l *Listener) Serving() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.listeners) > 0
 }
 
-func (c *conn) String() string {
-	return fmt.Sprintf("Dead %v %d replies pending", c.dead, len(c.replies))
+func (l *Listener) String() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var addrs []string
+	for ln := range l.listeners {
+		addrs = append(addrs, ln.Addr().String())
+	}
+	sort.Strings(addrs)
+	return fmt.Sprintf("Listener on %v, %d conns", addrs, len(l.conns))
 }
 
-func (c *conn) logf(format string, args ...interface{}) {
-	// prepend some info about the conn
-	c.listener.logf("[%v] "+format, append([]interface{}{c.remoteAddr}, args...)...)
+func (c *conn) String() string {
+	i := c.info()
+	return fmt.Sprintf("conn %d from %v as %q: %d fids, %d open, tags %v", i.ID, i.Remote, i.Uname, i.Fids, i.Open, i.Tags)
 }
 
 func (c *conn) serve() {
@@ -204,78 +374,338 @@ func (c *conn) serve() {
 		return
 	}
 
-	c.remoteAddr = c.rwc.RemoteAddr().String()
-
+	var err error
+	defer c.listener.untrackConn(c)
+	defer func() {
+		c.listener.observe(ConnClosed{Conn: c.id, Remote: c.remoteAddr, Err: c.closeReason(err)})
+	}()
 	defer c.rwc.Close()
-
-	c.logf("Starting readNetPackets")
+	defer c.closeLayers()
+
+	c.meter = c.listener.Metrics.meter(c.listener.Clock)
+	defer c.meter.close()
+
+	session := c.deadline("session", c.listener.SessionTimeout)
+	session.start()
+	defer session.stop()
+	idle := c.deadline("read", c.listener.ReadTimeout)
+	write := c.deadline("write", c.listener.WriteTimeout)
+	defer idle.stop()
+	defer write.stop()
+
+	c.listener.Spans.name(c.id, fmt.Sprintf("conn %d %v", c.id, c.remoteAddr))
+	defer c.listener.Spans.unname(c.id)
+	c.listener.observe(ConnOpened{Conn: c.id, Remote: c.remoteAddr})
+	clock := clockOr(c.listener.Clock)
+
+	in := make(chan *incoming, c.readAhead())
+	done := make(chan struct{})
+	defer close(done)
+	idle.start()
+	go c.read(in, idle, done)
 
 	for !c.dead {
+		m := <-in
+		if m.b == nil {
+			err = m.err
+			return
+		}
+		if m.last {
+			// A message that cannot be read in full is refused, and
+			// the rest of the stream with it.
+			errorReply(m.b, m.tag, m.err)
+			write.start()
+			c.rwc.Write(m.b.Bytes())
+			write.stop()
+			err = m.err
+			return
+		}
+
+		req := c.meter.begin(m.t, m.b.Bytes())
+		var e *DispatchError
+		if m.err != nil {
+			errorReply(m.b, m.tag, m.err)
+			e = &DispatchError{Err: m.err}
+		} else {
+			e = c.dispatch(m.b, m.t, m.rpc)
+		}
+		if e != nil {
+			e.Conn, e.Type, e.Tag = c.id, m.t, m.tag
+			c.listener.observe(*e)
+		}
+		// The tag is free once the reply is on its way, and the client
+		// may use it again as soon as it has the reply.
+		if m.tagged {
+			c.server.Fids.end(m.tag)
+		}
+		c.meter.end(req, m.b.Bytes())
+
+		// The reply is observed before it is written, as the client may
+		// answer it, and the next message be observed, straight after.
+		if rb := m.b.Bytes(); len(rb) >= 7 {
+			c.listener.observe(MessageSent{Conn: c.id, Type: MType(rb[4]), Tag: m.tag, Size: len(rb), Duration: clock.Now().Sub(m.start)})
+		}
+		w := m.rpc.Child("write")
+		write.start()
+		_, err = c.rwc.Write(m.b.Bytes())
+		write.stop()
+		w.End()
+		m.rpc.End()
+		c.busy(idle, -1)
+		c.unbuffer(m.size)
+		if err != nil {
+			return
+		}
+	}
+}
+
+// An incoming is a message read from a connection and not yet answered.
+type incoming struct {
+	t     MType
+	tag   Tag
+	b     *bytes.Buffer // the message from the tag on, then the reply
+	size  int64         // the message's size, which is buffered until it is answered
+	start time.Time
+	rpc   *Span
+
+	// err, if not nil, is what the message is answered with instead of
+	// being dispatched. If last is set, the connection is closed once
+	// it has been answered; if b is nil there is no message, and err is
+	// why the connection stopped.
+	err    error
+	last   bool
+	tagged bool // its tag is in flight in the FidTable
+}
+
+// readAhead is how many messages a connection reads ahead of the one
+// being answered: enough for MaxInFlight to be reached, or, without
+// MaxInFlight, one.
+func (c *conn) readAhead() int {
+	if n := c.listener.Limits.MaxInFlight; n > 0 {
+		return n
+	}
+	return 1
+}
+
+// read reads messages from the connection and sends them to in, until it
+// can read no more, when it sends one with last set. It gives up once
+// done is closed. The tag of each message is checked with
+// the FidTable as it arrives, so that it knows which are in flight.
+func (c *conn) read(in chan<- *incoming, idle *deadline, done <-chan struct{}) {
+	send := func(m *incoming) bool {
+		if m.last {
+			c.stop(m.err)
+		}
+		select {
+		case in <- m:
+			return true
+		case <-done:
+			return false
+		}
+	}
+	clock := clockOr(c.listener.Clock)
+	for {
 		l := make([]byte, 7)
-		if n, err := c.rwc.Read(l); err != nil || n < 7 {
-			c.logf("readNetPackets: short read: %v", err)
-			c.dead = true
+		if _, err := io.ReadFull(c.rwc, l); err != nil {
+			send(&incoming{err: err, last: true})
 			return
 		}
 		sz := int64(l[0]) + int64(l[1])<<8 + int64(l[2])<<16 + int64(l[3])<<24
-		t := MType(l[4])
-		b := bytes.NewBuffer(l[5:])
-		r := io.LimitReader(c.rwc, sz-7)
-		if _, err := io.Copy(b, r); err != nil {
-			c.logf("readNetPackets: short read: %v", err)
-			c.dead = true
+		m := &incoming{t: MType(l[4]), tag: Tag(l[5]) | Tag(l[6])<<8, start: clock.Now()}
+		m.rpc = c.listener.Spans.span(c.id, RPCNames[m.t])
+		recv := m.rpc.Child("receive")
+		if max := c.listener.Limits.MaxBuffered; max > 0 && sz > int64(max) {
+			c.listener.counters.add(tooBig)
+			c.setReason(fmt.Errorf("%v is %d bytes, limit is %d", RPCNames[m.t], sz, max))
+			m.b, m.err, m.last = bytes.NewBuffer(l[5:]), ErrTooBig, true
+			send(m)
 			return
 		}
-		c.logf("readNetPackets: got %v, len %d, sending to IO", RPCNames[MType(l[4])], b.Len())
-		//panic(fmt.Sprintf("packet is %v", b.Bytes()[:]))
-		//panic(fmt.Sprintf("s is %v", s))
-		if err := c.server.D(c.server, b, t); err != nil {
-			c.logf("%v: %v", RPCNames[MType(l[4])], err)
+		// A size no message can have means the stream is garbage, or out
+		// of step; there is no finding the next message, so give up.
+		if sz < 7 || sz > MSIZE {
+			send(&incoming{err: fmt.Errorf("bad message size %d", sz), last: true})
+			return
 		}
-		c.logf("readNetPackets: Write %v back", b)
-		amt, err := c.rwc.Write(b.Bytes())
+		if !c.buffer(sz, done) {
+			return
+		}
+		m.size = sz
+		m.b = bytes.NewBuffer(l[5:])
+		if _, err := io.CopyN(m.b, c.rwc, sz-7); err != nil {
+			send(&incoming{err: err, last: true})
+			return
+		}
+		c.busy(idle, 1)
+		m.rpc.setMsg(c.id, m.t, m.b.Bytes())
+		recv.End()
+		c.listener.observe(MessageReceived{Conn: c.id, Type: m.t, Tag: m.tag, Size: int(sz)})
+
+		if f := c.server.Fids; f != nil && m.t != Tversion {
+			m.err = f.begin(m.tag)
+			m.tagged = m.err == nil
+		}
+		if !send(m) {
+			return
+		}
+	}
+}
+
+// busy adds n to the count of messages read and not yet answered. The
+// read timeout runs only while there are none, as a client waiting for
+// its replies is not idle.
+func (c *conn) busy(idle *deadline, n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pending += n
+	if c.pending == 0 {
+		idle.start()
+	} else {
+		idle.stop()
+	}
+}
+
+// closeLayers closes each of the connection's NineServers that is an
+// io.Closer.
+func (c *conn) closeLayers() {
+	for _, v := range c.layers {
+		if cl, ok := v.(io.Closer); ok {
+			cl.Close()
+		}
+	}
+}
+
+// dispatch calls the server's Dispatcher on the message in b. If it
+// answers with an error, dispatch returns it as a DispatchError, for the
+// caller to fill in and observe. If it panics, the panic is answered with
+// Rerror, so that a bug in one file takes down neither the process nor the
+// other connections. Once there have been MaxPanics of them the connection
+// is marked dead.
+func (c *conn) dispatch(b *bytes.Buffer, t MType, rpc *Span) (e *DispatchError) {
+	tag := NOTAG
+	if b.Len() >= 2 {
+		tag = Tag(b.Bytes()[0]) | Tag(b.Bytes()[1])<<8
+	}
+	if t == Tattach {
+		var m TattachMsg
+		if decodeTattach(b.Bytes(), &m) == nil {
+			c.mu.Lock()
+			c.uname = m.Uname
+			c.mu.Unlock()
+		}
+	}
+	if t == Tread {
+		c.clampRead(b)
+	}
+	if s := c.listener.Scheduler; s != nil {
+		q := rpc.Child("queue")
+		err := c.schedule(s, b, t)
+		q.End()
 		if err != nil {
-			c.logf("readNetPackets: write error: %v", err)
+			c.setReason(err)
 			c.dead = true
+			errorReply(b, tag, err)
+			return &DispatchError{Err: err}
+		}
+	}
+	h := rpc.Child("handler")
+	if h != nil {
+		for _, v := range c.layers {
+			if s, ok := v.(SpanSetter); ok {
+				s.SetSpan(h)
+				defer s.SetSpan(nil)
+			}
+		}
+	}
+	defer h.End()
+	defer func() {
+		r := recover()
+		if r == nil {
 			return
 		}
-		c.logf("Returned %v amt %v", b, amt)
+		atomic.AddInt64(&c.listener.panics, 1)
+		c.panics++
+		e = &DispatchError{Err: ErrPanic, Panic: r, Stack: string(debug.Stack())}
+		MarshalRerrorPkt(b, tag, ErrPanic.Error())
+		if max := c.listener.MaxPanics; max > 0 && c.panics >= max {
+			c.setReason(fmt.Errorf("%d panics", c.panics))
+			c.dead = true
+		}
+	}()
+	if err := c.server.D(c.server, b, t); err != nil {
+		return &DispatchError{Err: err}
 	}
+	if t == Tversion {
+		var r RversionMsg
+		if DecodeRversion(b.Bytes(), &r) == nil {
+			c.msize = r.RMsize
+		}
+	}
+	if err := replyError(b.Bytes()); err != nil {
+		return &DispatchError{Err: err}
+	}
+	return nil
 }
 
-// Dispatch dispatches request to different functions.
-// It's also the the first place we try to establish server semantics.
-// We could do this with interface assertions and such a la rsc/fuse
-// but most people I talked do disliked that. So we don't. If you want
-// to make things optional, just define the ones you want to implement in this case.
-func Dispatch(s *Server, b *bytes.Buffer, t MType) error {
-	switch t {
-	case Tversion:
-		return s.SrvRversion(b)
-	case Tattach:
-		return s.SrvRattach(b)
-	case Tflush:
-		return s.SrvRflush(b)
-	case Twalk:
-		return s.SrvRwalk(b)
-	case Topen:
-		return s.SrvRopen(b)
-	case Tcreate:
-		return s.SrvRcreate(b)
-	case Tclunk:
-		return s.SrvRclunk(b)
-	case Tstat:
-		return s.SrvRstat(b)
-	case Twstat:
-		return s.SrvRwstat(b)
-	case Tremove:
-		return s.SrvRremove(b)
-	case Tread:
-		return s.SrvRread(b)
-	case Twrite:
-		return s.SrvRwrite(b)
-	}
-	// This has been tested by removing Attach from the switch.
-	ServerError(b, fmt.Sprintf("Dispatch: %v not supported", RPCNames[t]))
-	return nil
+// ConnInfo describes a connection a Listener is serving. The counts come
+// from the connection's FidTable, so they are zero without one.
+type ConnInfo struct {
+	ID     uint64 `json:"id"`
+	Remote string `json:"remote"`
+	Uname  string `json:"uname"` // the user it last attached as
+	Fids   int    `json:"fids"`
+	Open   int    `json:"open"` // fids open for I/O
+	Tags   []Tag  `json:"tags"` // tags of messages in flight
+}
+
+// Conns describes the connections the Listener is serving, in the order
+// they came.
+func (l *Listener) Conns() []ConnInfo {
+	l.mu.Lock()
+	cs := make([]*conn, 0, len(l.conns))
+	for c := range l.conns {
+		cs = append(cs, c)
+	}
+	l.mu.Unlock()
+	sort.Slice(cs, func(i, j int) bool { return cs[i].id < cs[j].id })
+	infos := make([]ConnInfo, len(cs))
+	for i, c := range cs {
+		infos[i] = c.info()
+	}
+	return infos
+}
+
+// clampRead cuts the count of the Tread in b to what fits in an Rread of
+// the message size the connection agreed on, as lib9p does, so that a
+// client cannot make its NineServer allocate more than that. A count of
+// 2^31 or more, which is negative as a Count, is cut too.
+func (c *conn) clampRead(b *bytes.Buffer) {
+	p := b.Bytes()
+	// The tag, fid and offset come before the count.
+	if len(p) < 2+4+8+4 {
+		return
+	}
+	p = p[2+4+8:]
+	msize := c.msize
+	if msize == 0 || msize > MSIZE {
+		msize = MSIZE
+	}
+	var max Count
+	if msize > IOHDRSZ {
+		max = Count(msize - IOHDRSZ)
+	}
+	if n := Count(p[0]) | Count(p[1])<<8 | Count(p[2])<<16 | Count(p[3])<<24; n >= 0 && n <= max {
+		return
+	}
+	p[0], p[1], p[2], p[3] = byte(max), byte(max>>8), byte(max>>16), byte(max>>24)
+}
+
+func (c *conn) info() ConnInfo {
+	c.mu.Lock()
+	i := ConnInfo{ID: c.id, Remote: c.remoteAddr, Uname: c.uname, Tags: []Tag{}}
+	c.mu.Unlock()
+	if f := c.server.Fids; f != nil {
+		i.Fids, i.Open, i.Tags = f.Len(), f.Open(), f.Tags()
+	}
+	return i
 }
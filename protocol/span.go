@@ -0,0 +1,283 @@
+// Copyright 2019 The Ninep Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protocol
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// A SpanLog writes spans, timed pieces of work, to a file in the Chrome
+// trace event format, which chrome://tracing and Perfetto
+// (ui.perfetto.dev) show as a timeline. Give a Listener one by setting
+// its Spans field, and each message it serves is a span named for its
+// type, e.g. Tread, with conn, tag, fid and type arguments, made of
+//
+//	receive   reading the message after its first bytes arrive
+//	queue     waiting for the Listener's Scheduler, if it has one
+//	handler   the NineServer answering it
+//	write     writing the reply
+//
+// Each connection is a thread in the timeline. A NineServer that
+// implements SpanSetter gets the handler span, and may hang its own
+// spans under it.
+//
+// The file is a JSON array of events, one a line. Once it is bigger than
+// MaxSize it is closed and renamed Path.1, Path.1 is renamed Path.2 and
+// so on up to Path.Keep, and a new one is started, beginning with the
+// names of the threads still open.
+type SpanLog struct {
+	Path    string
+	MaxSize int64 // zero means no limit
+	Keep    int   // old files kept; zero means none
+	Clock   Clock
+
+	mu   sync.Mutex
+	f    *os.File
+	size int64 // bytes in f
+	n    int   // events in f
+	err  error // the first write error
+	pid  int
+	tids map[uint64]string // names of open threads, for each new file
+}
+
+// NewSpanLog returns a SpanLog writing to path, which it creates. A file
+// already at path is rotated away as if it were full.
+func NewSpanLog(path string, maxSize int64, keep int) (*SpanLog, error) {
+	l := &SpanLog{Path: path, MaxSize: maxSize, Keep: keep, pid: os.Getpid(), tids: make(map[uint64]string)}
+	if _, err := os.Stat(path); err == nil {
+		l.shift()
+	}
+	if err := l.create(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *SpanLog) create() error {
+	f, err := os.Create(l.Path)
+	if err != nil {
+		return err
+	}
+	n, err := f.WriteString("[\n")
+	l.f, l.size, l.n = f, int64(n), 0
+	return err
+}
+
+// shift renames Path to Path.1, Path.1 to Path.2 and so on, dropping the
+// file that would go past Path.Keep.
+func (l *SpanLog) shift() {
+	if l.Keep <= 0 {
+		os.Remove(l.Path)
+		return
+	}
+	for i := l.Keep - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%v.%d", l.Path, i), fmt.Sprintf("%v.%d", l.Path, i+1))
+	}
+	os.Rename(l.Path, l.Path+".1")
+}
+
+// rotate closes the current file and starts a new one, naming in it the
+// threads that are still open so its spans are not on nameless threads.
+func (l *SpanLog) rotate() error {
+	l.f.WriteString("\n]\n")
+	l.f.Close()
+	l.shift()
+	if err := l.create(); err != nil {
+		return err
+	}
+	tids := make([]uint64, 0, len(l.tids))
+	for tid := range l.tids {
+		tids = append(tids, tid)
+	}
+	sort.Slice(tids, func(i, j int) bool { return tids[i] < tids[j] })
+	for _, tid := range tids {
+		e := nameEvent(tid, l.tids[tid])
+		e.Pid = l.pid
+		b, _ := json.Marshal(e)
+		if err := l.append(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// A traceEvent is one event in the trace event format. Timestamps and
+// durations are in microseconds.
+type traceEvent struct {
+	Name string                 `json:"name"`
+	Cat  string                 `json:"cat,omitempty"`
+	Ph   string                 `json:"ph"`
+	Ts   float64                `json:"ts"`
+	Dur  float64                `json:"dur"`
+	Pid  int                    `json:"pid"`
+	Tid  uint64                 `json:"tid"`
+	Args map[string]interface{} `json:"args,omitempty"`
+}
+
+func (l *SpanLog) write(e traceEvent) {
+	e.Pid = l.pid
+	b, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil || l.err != nil {
+		return
+	}
+	if l.MaxSize > 0 && l.n > 0 && l.size+int64(len(b))+2 > l.MaxSize {
+		if l.err = l.rotate(); l.err != nil {
+			return
+		}
+	}
+	l.err = l.append(b)
+}
+
+// append adds the marshaled event b to the file.
+func (l *SpanLog) append(b []byte) error {
+	if l.n > 0 {
+		b = append([]byte(",\n"), b...)
+	}
+	n, err := l.f.Write(b)
+	l.size += int64(n)
+	l.n++
+	return err
+}
+
+// nameEvent is the event naming thread tid.
+func nameEvent(tid uint64, name string) traceEvent {
+	return traceEvent{Name: "thread_name", Ph: "M", Tid: tid, Args: map[string]interface{}{"name": name}}
+}
+
+// Close ends the file and closes it. It returns the first error writing
+// it, if any.
+func (l *SpanLog) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return l.err
+	}
+	l.f.WriteString("\n]\n")
+	if err := l.f.Close(); l.err == nil {
+		l.err = err
+	}
+	l.f = nil
+	return l.err
+}
+
+// name names the thread tid in the timeline.
+func (l *SpanLog) name(tid uint64, name string) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	l.tids[tid] = name
+	l.mu.Unlock()
+	l.write(nameEvent(tid, name))
+}
+
+// unname forgets thread tid, which has ended, so later files do not name
+// it.
+func (l *SpanLog) unname(tid uint64) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.tids, tid)
+}
+
+// span starts a span on thread tid, or returns nil if l is nil.
+func (l *SpanLog) span(tid uint64, name string) *Span {
+	if l == nil {
+		return nil
+	}
+	return &Span{Name: name, log: l, tid: tid, start: clockOr(l.Clock).Now()}
+}
+
+// A Span is a timed piece of work in a SpanLog. Its methods do nothing on
+// a nil Span, so code can make and end spans without checking whether
+// spans are being kept at all.
+type Span struct {
+	Name string
+
+	log   *SpanLog
+	tid   uint64
+	start time.Time
+
+	mu   sync.Mutex
+	args map[string]interface{}
+}
+
+// SpanSetter is implemented by a NineServer that wants the spans of the
+// messages it answers. SetSpan is called with the handler span before
+// each of the NineServer's methods is called, and with nil after. It is
+// only called if the Listener has a SpanLog.
+type SpanSetter interface {
+	SetSpan(s *Span)
+}
+
+// Child starts a span within s.
+func (s *Span) Child(name string) *Span {
+	if s == nil {
+		return nil
+	}
+	return s.log.span(s.tid, name)
+}
+
+// SetArg sets an argument of the span, shown with it in the timeline.
+func (s *Span) SetArg(key string, v interface{}) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.args == nil {
+		s.args = make(map[string]interface{})
+	}
+	s.args[key] = v
+}
+
+// End ends the span and writes it to its SpanLog.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	d := clockOr(s.log.Clock).Now().Sub(s.start)
+	s.mu.Lock()
+	args := s.args
+	s.mu.Unlock()
+	s.log.write(traceEvent{
+		Name: s.Name,
+		Cat:  "q9p",
+		Ph:   "X",
+		Ts:   float64(s.start.UnixNano()) / 1e3,
+		Dur:  float64(d.Nanoseconds()) / 1e3,
+		Tid:  s.tid,
+		Args: args,
+	})
+}
+
+// setMsg sets the arguments of the span of the T message in b, from the
+// tag on, which came on connection id.
+func (s *Span) setMsg(id uint64, t MType, b []byte) {
+	if s == nil || len(b) < 2 {
+		return
+	}
+	s.SetArg("conn", id)
+	s.SetArg("type", RPCNames[t])
+	s.SetArg("tag", Tag(b[0])|Tag(b[1])<<8)
+	switch t {
+	case Tauth, Tattach, Twalk, Topen, Tcreate, Tread, Twrite, Tclunk, Tremove, Tstat, Twstat:
+		if len(b) >= 6 {
+			s.SetArg("fid", FID(b[2])|FID(b[3])<<8|FID(b[4])<<16|FID(b[5])<<24)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright 2019 The Ninep Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protocol
+
+import (
+	"fmt"
+	"time"
+)
+
+// A Clock tells the time and runs functions later. Listener and Client
+// use one for their timeouts; nil means the real clock. Tests can use a
+// fake one to make time pass when they like.
+type Clock interface {
+	Now() time.Time
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// A Timer is what Clock.AfterFunc returns. *time.Timer is one.
+type Timer interface {
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+func (realClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+func clockOr(c Clock) Clock {
+	if c == nil {
+		return realClock{}
+	}
+	return c
+}
+
+// A deadline closes a conn unless it is stopped within d of being
+// started. A nil deadline does nothing.
+type deadline struct {
+	t Timer
+	d time.Duration
+}
+
+// deadline returns a stopped deadline for the timeout called what, or nil
+// if d is zero.
+func (c *conn) deadline(what string, d time.Duration) *deadline {
+	if d <= 0 {
+		return nil
+	}
+	t := clockOr(c.listener.Clock).AfterFunc(d, func() {
+		c.listener.counters.add(timeouts)
+		c.setReason(fmt.Errorf("%v timeout after %v", what, d))
+		c.rwc.Close()
+	})
+	t.Stop()
+	return &deadline{t: t, d: d}
+}
+
+func (dl *deadline) start() {
+	if dl != nil {
+		dl.t.Reset(dl.d)
+	}
+}
+
+func (dl *deadline) stop() {
+	if dl != nil {
+		dl.t.Stop()
+	}
+}
+
+// keepAlive sends a Tflush for NOTAG, which every server answers and
+// which costs it nothing, whenever the client has heard nothing from the
+// server for KeepAlive and is waiting for no replies. If that is not
+// answered within another KeepAlive, and nothing else is waiting, the
+// client is marked dead and its connection closed.
+//
+// A request waiting for its reply is taken as a sign of life: the server
+// may just be slow to answer it, and a Tflush sent behind it would be
+// answered no sooner.
+func (c *Client) keepAlive() {
+	clock := clockOr(c.Clock)
+	wait := c.KeepAlive
+	for {
+		if !c.sleep(clock, wait) {
+			return
+		}
+		wait = c.KeepAlive
+		if idle := clock.Now().Sub(c.heard()); idle < c.KeepAlive {
+			wait = c.KeepAlive - idle
+			continue
+		}
+		if c.waiting() > 0 {
+			continue
+		}
+
+		reply := make(chan error, 1)
+		go func() { reply <- c.CallTflush(NOTAG) }()
+		for answered := false; !answered; {
+			timeout := make(chan struct{})
+			t := clock.AfterFunc(c.KeepAlive, func() { close(timeout) })
+			select {
+			case <-reply:
+				t.Stop()
+				answered = true
+			case <-timeout:
+				// The Tflush is waiting; so may be requests sent just
+				// before it, which it must wait behind.
+				if c.waiting() <= 1 {
+					c.die("keepalive unanswered after %v", c.KeepAlive)
+					return
+				}
+			case <-c.done:
+				t.Stop()
+				return
+			}
+		}
+	}
+}
+
+// sleep waits for d, and reports whether the client is still alive
+// after it.
+func (c *Client) sleep(clock Clock, d time.Duration) bool {
+	wake := make(chan struct{})
+	t := clock.AfterFunc(d, func() { close(wake) })
+	select {
+	case <-wake:
+		return true
+	case <-c.done:
+		t.Stop()
+		return false
+	}
+}